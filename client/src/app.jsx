@@ -7,6 +7,7 @@ import { ArticleReader } from './pages/ArticleReader';
 import { Starred } from './pages/Starred';
 import { ErrorBoundary } from './components/ErrorBoundary';
 import { KeyboardShortcutsHelp } from './components/KeyboardShortcutsHelp';
+import { PushToggle } from './components/PushToggle';
 import { connect, disconnect } from './lib/sse';
 import { fetchUser, login, logout } from './lib/auth';
 
@@ -283,6 +284,7 @@ export function App() {
         <nav class="app-nav">
           <a href="#/">Top</a>
           <a href="#/starred">Starred</a>
+          <PushToggle />
           <button class="signout-btn" onClick={logout} title="Sign out">
             <svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round">
               <path d="M9 21H5a2 2 0 0 1-2-2V5a2 2 0 0 1 2-2h4" />
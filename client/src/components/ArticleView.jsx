@@ -35,6 +35,12 @@ export function ArticleView({ story, article, onRetry, retrying }) {
     <div class="article-view">
       {article.title && <h1 class="article-title">{article.title}</h1>}
       {article.byline && <p class="article-byline">{article.byline}</p>}
+      {article.source === 'archive' && (
+        <p class="article-archived">
+          The original page was unavailable; this is an{' '}
+          <a href={article.archive_url} target="_blank" rel="noopener noreferrer">archived copy ↗</a>
+        </p>
+      )}
       <div class="article-content" dangerouslySetInnerHTML={{ __html: article.content }} />
     </div>
   );
@@ -10,7 +10,8 @@ function countReplies(comment) {
 }
 
 export function Comment({ comment, collapsedIds, toggleCollapse, focusedCommentId, storyId }) {
-  const collapsed = collapsedIds.has(comment.id);
+  // Muted comments start collapsed; toggling expands them.
+  const collapsed = collapsedIds.has(comment.id) !== Boolean(comment.muted);
   const replyCount = countReplies(comment);
   const isDeleted = comment.deleted;
   const isFocused = focusedCommentId === comment.id;
@@ -34,6 +35,10 @@ export function Comment({ comment, collapsedIds, toggleCollapse, focusedCommentI
               >
                 {timeAgo(comment.time)}
               </a>
+              {comment.muted && <span class="comment-muted">(muted)</span>}
+              {comment.edited_at && (
+                <span class="comment-edited" title={`Edited ${timeAgo(comment.edited_at)}`}>(edited)</span>
+              )}
             </>
           )}
           {collapsed && replyCount > 0 && (
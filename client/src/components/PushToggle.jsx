@@ -0,0 +1,44 @@
+import { useEffect, useState } from 'preact/hooks';
+import { pushSupported, pushEnabled, enablePush, disablePush } from '../lib/push';
+
+/** Header button that turns push notifications on and off for this browser. */
+export function PushToggle() {
+  const [enabled, setEnabled] = useState(false);
+  const [busy, setBusy] = useState(false);
+
+  useEffect(() => {
+    pushEnabled().then(setEnabled).catch(() => {});
+  }, []);
+
+  if (!pushSupported()) return null;
+
+  async function toggle() {
+    setBusy(true);
+    try {
+      if (enabled) {
+        await disablePush();
+        setEnabled(false);
+      } else {
+        setEnabled(await enablePush());
+      }
+    } catch {
+      // Permission denied or push service unavailable — leave as is
+    } finally {
+      setBusy(false);
+    }
+  }
+
+  return (
+    <button
+      class={`push-btn${enabled ? ' push-btn-on' : ''}`}
+      onClick={toggle}
+      disabled={busy}
+      title={enabled ? 'Turn off notifications' : 'Turn on notifications'}
+    >
+      <svg width="16" height="16" viewBox="0 0 24 24" fill={enabled ? 'currentColor' : 'none'} stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round">
+        <path d="M18 8A6 6 0 0 0 6 8c0 7-3 9-3 9h18s-3-2-3-9" />
+        <path d="M13.73 21a2 2 0 0 1-3.46 0" />
+      </svg>
+    </button>
+  );
+}
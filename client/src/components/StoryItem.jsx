@@ -40,6 +40,10 @@ export function StoryItem({ story, rank, starred, prefetched, selected }) {
               <span class="story-author">{story.by}</span>
               <span class="story-separator">·</span>
               <span class="story-time">{timeAgo(story.time)}</span>
+              {story.article?.reading_time_minutes > 0 && <>
+                <span class="story-separator">·</span>
+                <span class="story-reading-time" title={`${story.article.word_count} words`}>{story.article.reading_time_minutes} min read</span>
+              </>}
               {prefetched && <>
                 <span class="story-separator">·</span>
                 <span class="story-prefetch-indicator" aria-label="Cached for offline" title="Available offline"><svg viewBox="0 0 24 24" width="11" height="11" fill="none" stroke="currentColor" stroke-width="2.5" stroke-linecap="round" stroke-linejoin="round"><path d="M4 14.899A7 7 0 1 1 15.71 8h1.79a4.5 4.5 0 0 1 2.5 8.242"/><path d="M12 12v9"/><path d="m8 17 4 4 4-4"/></svg></span>
@@ -0,0 +1,61 @@
+/**
+ * Web Push subscription management. The server pushes watch alerts, inbox
+ * replies and trending stories; the service worker shows them.
+ */
+
+export function pushSupported() {
+  return 'serviceWorker' in navigator && 'PushManager' in window && 'Notification' in window;
+}
+
+function decodeKey(base64url) {
+  const base64 = base64url.replace(/-/g, '+').replace(/_/g, '/');
+  const raw = atob(base64 + '='.repeat((4 - (base64.length % 4)) % 4));
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+
+/** Whether this browser is currently subscribed. */
+export async function pushEnabled() {
+  if (!pushSupported() || Notification.permission !== 'granted') return false;
+  const reg = await navigator.serviceWorker.ready;
+  return (await reg.pushManager.getSubscription()) !== null;
+}
+
+/**
+ * Ask for notification permission, subscribe with the server's VAPID key
+ * and register the subscription. Returns whether it succeeded.
+ */
+export async function enablePush() {
+  if (!pushSupported()) return false;
+  if ((await Notification.requestPermission()) !== 'granted') return false;
+
+  const keyRes = await fetch('/api/push/key');
+  if (!keyRes.ok) return false;
+  const { public_key } = await keyRes.json();
+
+  const reg = await navigator.serviceWorker.ready;
+  const sub = await reg.pushManager.subscribe({
+    userVisibleOnly: true,
+    applicationServerKey: decodeKey(public_key),
+  });
+
+  const res = await fetch('/api/push/subscribe', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(sub.toJSON()),
+  });
+  return res.ok;
+}
+
+/** Unsubscribe this browser and remove it from the server. */
+export async function disablePush() {
+  if (!pushSupported()) return;
+  const reg = await navigator.serviceWorker.ready;
+  const sub = await reg.pushManager.getSubscription();
+  if (!sub) return;
+  await fetch('/api/push/unsubscribe', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ endpoint: sub.endpoint }),
+  }).catch(() => {});
+  await sub.unsubscribe();
+}
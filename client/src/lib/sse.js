@@ -91,6 +91,10 @@ class SSEClient {
     this.eventSource.addEventListener('sync_required', this._handleEvent);
     this.eventSource.addEventListener('comments_updated', this._handleEvent);
     this.eventSource.addEventListener('story_refreshed', this._handleEvent);
+    this.eventSource.addEventListener('article_updated', this._handleEvent);
+    this.eventSource.addEventListener('story_trending', this._handleEvent);
+    this.eventSource.addEventListener('alert', this._handleEvent);
+    this.eventSource.addEventListener('reply', this._handleEvent);
 
     this.eventSource.onerror = () => {
       // EventSource automatically reconnects. The browser handles this.
@@ -39,3 +39,38 @@ self.addEventListener('activate', (event) => {
     ).then(() => self.clients.claim())
   );
 });
+
+// Web Push: show alerts, replies and trending stories sent by the server
+self.addEventListener('push', (event) => {
+  if (!event.data) return;
+  let msg;
+  try {
+    msg = event.data.json();
+  } catch {
+    return;
+  }
+  event.waitUntil(
+    self.registration.showNotification(msg.title, {
+      body: msg.body,
+      tag: msg.tag,
+      icon: '/icon-192.png',
+      data: { url: msg.url },
+    })
+  );
+});
+
+// Focus an open window on the notification's URL, or open one
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  const url = new URL(event.notification.data?.url || '/', self.location.origin).href;
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+      for (const client of windows) {
+        if ('focus' in client) {
+          return client.navigate(url).then((c) => (c || client).focus());
+        }
+      }
+      return self.clients.openWindow(url);
+    })
+  );
+});
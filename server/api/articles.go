@@ -2,11 +2,15 @@ package api
 
 import (
 	"database/sql"
+	"fmt"
+	"html"
 	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/danielmmetz/hn-client/server/render"
 	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/textdiff"
 	"github.com/danielmmetz/hn-client/server/worker"
 )
 
@@ -20,7 +24,36 @@ func NewArticlesHandler(db *sql.DB, q *store.Queries, fetcher *worker.Fetcher) *
 	return &ArticlesHandler{db: db, q: q, fetcher: fetcher}
 }
 
-// GetArticle handles GET /api/stories/{id}/article
+// maxRenderWidth bounds the width param of text renderings.
+const maxRenderWidth = 500
+
+// renderParams parses the format and width params of endpoints that can
+// render HTML as Markdown or text. format is "" for JSON. It writes a 400 and
+// returns false if they're invalid.
+func renderParams(w http.ResponseWriter, r *http.Request) (format string, width int, ok bool) {
+	format = r.URL.Query().Get("format")
+	if format == "json" {
+		format = ""
+	}
+	if format != "" && render.ContentType(format) == "" {
+		http.Error(w, "invalid format: must be json, markdown or text", http.StatusBadRequest)
+		return "", 0, false
+	}
+	width = render.DefaultWidth
+	if s := r.URL.Query().Get("width"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxRenderWidth {
+			http.Error(w, "invalid width: must be 0-500", http.StatusBadRequest)
+			return "", 0, false
+		}
+		width = n
+	}
+	return format, width, true
+}
+
+// GetArticle handles GET /api/stories/{id}/article?format=json|markdown|text&width=80
+// Markdown and text render the article's content, text wrapped at width
+// columns (0 disables wrapping).
 func (h *ArticlesHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -28,6 +61,10 @@ func (h *ArticlesHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
+	format, width, ok := renderParams(w, r)
+	if !ok {
+		return
+	}
 
 	// Check if story exists (fetch on-demand if needed)
 	story, err := store.Nullable(h.q.GetStoryByID(ctx, h.db, id))
@@ -36,7 +73,7 @@ func (h *ArticlesHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if story == nil {
-		if fetchErr := h.fetcher.FetchStorySingleflight(ctx, id); fetchErr != nil {
+		if fetchErr := h.fetcher.FetchStoryAndWait(ctx, id); fetchErr != nil {
 			slog.Error("on-demand story fetch for article failed", "story_id", id, "error", fetchErr)
 			http.Error(w, "story not found", http.StatusNotFound)
 			return
@@ -66,7 +103,9 @@ func (h *ArticlesHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 	// On-demand article extraction if not in DB
 	if article == nil {
 		slog.Info("on-demand article extraction", "story_id", id)
-		h.fetcher.ExtractArticleSingleflight(ctx, id, *story.URL)
+		if err := h.fetcher.ExtractArticleAndWait(ctx, id, *story.URL); err != nil {
+			slog.Warn("on-demand article extraction failed", "story_id", id, "error", err)
+		}
 
 		article, err = store.Nullable(h.q.GetArticleByStoryID(ctx, h.db, id))
 		if err != nil {
@@ -79,5 +118,189 @@ func (h *ArticlesHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if format != "" {
+		if article.Content == nil || *article.Content == "" {
+			http.Error(w, "article content not available", http.StatusNotFound)
+			return
+		}
+		writeBody(w, r, render.ContentType(format), []byte(renderArticle(story, article, format, width)))
+		return
+	}
+
 	writeJSON(w, r, article)
 }
+
+// renderArticle renders an article below its title, link and byline.
+func renderArticle(story *store.Story, article *store.Article, format string, width int) string {
+	title := story.Title
+	if article.Title != nil && *article.Title != "" {
+		title = *article.Title
+	}
+	url := html.EscapeString(*story.URL)
+	header := fmt.Sprintf(`<h1>%s</h1><p><a href="%s">%s</a></p>`, html.EscapeString(title), url, url)
+	if article.Byline != nil && *article.Byline != "" {
+		header += "<p>By " + html.EscapeString(*article.Byline) + "</p>"
+	}
+	return render.HTML(header+"<hr>"+*article.Content, format, width) + "\n"
+}
+
+// ListArticleVersions handles GET /api/stories/{id}/article/versions
+func (h *ArticlesHandler) ListArticleVersions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := h.q.ListArticleVersions(r.Context(), h.db, id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"story_id": id,
+		"versions": versions,
+	})
+}
+
+// GetArticleVersion handles GET /api/stories/{id}/article/versions/{version}
+func (h *ArticlesHandler) GetArticleVersion(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	versionID, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	version, err := store.Nullable(h.q.GetArticleVersion(r.Context(), h.db, store.GetArticleVersionParams{StoryID: id, ID: versionID}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if version == nil {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, version)
+}
+
+// diffContext is the number of unchanged paragraphs kept around each change.
+const diffContext = 1
+
+// diffEntry is one paragraph-level step of an article diff. Runs of unchanged
+// paragraphs away from any change are collapsed into a single "skip" entry.
+type diffEntry struct {
+	Op    string `json:"op"`
+	Text  string `json:"text,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+// GetArticleDiff handles GET /api/stories/{id}/article/diff?from=&to=
+// Without parameters it compares the two most recent versions.
+func (h *ArticlesHandler) GetArticleDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	fromID, toID := 0, 0
+	if s := r.URL.Query().Get("from"); s != "" {
+		if fromID, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if toID, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if fromID == 0 || toID == 0 {
+		versions, err := h.q.ListArticleVersions(ctx, h.db, id)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if len(versions) < 2 {
+			http.Error(w, "article has fewer than two versions", http.StatusNotFound)
+			return
+		}
+		if toID == 0 {
+			toID = versions[len(versions)-1].ID
+		}
+		if fromID == 0 {
+			// Default to the version immediately preceding "to".
+			for i := len(versions) - 1; i > 0; i-- {
+				if versions[i].ID == toID {
+					fromID = versions[i-1].ID
+					break
+				}
+			}
+		}
+	}
+
+	from, err := store.Nullable(h.q.GetArticleVersion(ctx, h.db, store.GetArticleVersionParams{StoryID: id, ID: fromID}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	to, err := store.Nullable(h.q.GetArticleVersion(ctx, h.db, store.GetArticleVersionParams{StoryID: id, ID: toID}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if from == nil || to == nil {
+		http.Error(w, "version not found", http.StatusNotFound)
+		return
+	}
+
+	edits := textdiff.Diff(textdiff.Paragraphs(from.Content), textdiff.Paragraphs(to.Content))
+	writeJSON(w, r, map[string]interface{}{
+		"story_id": id,
+		"from":     map[string]interface{}{"id": from.ID, "fetched_at": from.FetchedAt, "title": from.Title},
+		"to":       map[string]interface{}{"id": to.ID, "fetched_at": to.FetchedAt, "title": to.Title},
+		"edits":    collapseUnchanged(edits, diffContext),
+	})
+}
+
+// collapseUnchanged keeps changed paragraphs and up to context unchanged
+// paragraphs on either side of them, replacing the rest with skip entries.
+func collapseUnchanged(edits []textdiff.Edit, context int) []diffEntry {
+	keep := make([]bool, len(edits))
+	for i, e := range edits {
+		if e.Op == textdiff.Equal {
+			continue
+		}
+		for j := max(0, i-context); j <= min(len(edits)-1, i+context); j++ {
+			keep[j] = true
+		}
+	}
+
+	out := []diffEntry{}
+	skipped := 0
+	for i, e := range edits {
+		if !keep[i] {
+			skipped++
+			continue
+		}
+		if skipped > 0 {
+			out = append(out, diffEntry{Op: "skip", Count: skipped})
+			skipped = 0
+		}
+		out = append(out, diffEntry{Op: string(e.Op), Text: e.Text})
+	}
+	if skipped > 0 {
+		out = append(out, diffEntry{Op: "skip", Count: skipped})
+	}
+	return out
+}
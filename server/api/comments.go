@@ -1,12 +1,21 @@
 package api
 
 import (
+	"cmp"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/render"
 	"github.com/danielmmetz/hn-client/server/store"
 	"github.com/danielmmetz/hn-client/server/worker"
 )
@@ -22,7 +31,10 @@ func NewCommentsHandler(db *sql.DB, q *store.Queries, fetcher *worker.Fetcher, h
 	return &CommentsHandler{db: db, q: q, fetcher: fetcher, hnClient: hnClient}
 }
 
-// GetComments handles GET /api/stories/{id}/comments
+// GetComments handles GET /api/stories/{id}/comments?format=json|markdown|text&width=80
+// It also takes the depth, limit, cursor and since params of GetComment.
+// Comments by users the reader muted are marked muted. Markdown and text
+// render the thread, with muted comments' text left out.
 func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := strconv.Atoi(r.PathValue("id"))
@@ -30,8 +42,22 @@ func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
+	format, width, ok := renderParams(w, r)
+	if !ok {
+		return
+	}
+	cq, ok := parseCommentQuery(w, r)
+	if !ok {
+		return
+	}
 
-	comments, fetchedAt, err := store.GetCommentTree(ctx, h.db, h.q, id)
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	comments, fetchedAt, err := store.GetCommentTree(ctx, h.db, h.q, id, filter)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -57,10 +83,10 @@ func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if len(kids) > 0 {
-			if fetchErr := h.fetcher.FetchCommentsSingleflight(ctx, id, kids); fetchErr != nil {
+			if fetchErr := h.fetcher.FetchCommentsAndWait(ctx, id, kids); fetchErr != nil {
 				slog.Error("on-demand comment fetch failed", "story_id", id, "error", fetchErr)
 			} else {
-				comments, fetchedAt, err = store.GetCommentTree(ctx, h.db, h.q, id)
+				comments, fetchedAt, err = store.GetCommentTree(ctx, h.db, h.q, id, filter)
 				if err != nil {
 					http.Error(w, "internal error", http.StatusInternalServerError)
 					return
@@ -69,8 +95,22 @@ func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if comments == nil {
-		comments = []*store.CommentNode{}
+	comments, next := cq.apply(comments)
+
+	if format != "" {
+		story, err := store.Nullable(h.q.GetStoryByID(ctx, h.db, id))
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		header := fmt.Sprintf("<h1>Comments on item %d</h1>", id)
+		if story != nil {
+			header = fmt.Sprintf(`<h1>%s</h1><p><a href="https://news.ycombinator.com/item?id=%d">%d comments</a></p>`,
+				html.EscapeString(story.Title), id, story.Descendants)
+		}
+		body := render.HTML(header, format, width) + "\n\n" + render.Comments(renderComments(comments), format, width) + "\n"
+		writeBody(w, r, render.ContentType(format), []byte(body))
+		return
 	}
 
 	resp := map[string]interface{}{
@@ -78,6 +118,282 @@ func (h *CommentsHandler) GetComments(w http.ResponseWriter, r *http.Request) {
 		"fetched_at": fetchedAt,
 		"comments":   comments,
 	}
+	if next != "" {
+		resp["next"] = next
+	}
 
 	writeJSON(w, r, resp)
 }
+
+// GetComment handles GET /api/comments/{id}?depth=&limit=&cursor=&since=
+// It returns a comment with its replies under "comments". depth limits the
+// levels of replies included, with the number cut off below each comment in
+// "more". limit pages the direct replies, with the cursor for the next page
+// in "next". since (unix seconds, YYYY-MM-DD or RFC 3339) instead returns a
+// flat list of the replies posted after it, oldest first, which limit pages.
+func (h *CommentsHandler) GetComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	cq, ok := parseCommentQuery(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := store.Nullable(h.q.GetCommentByID(ctx, h.db, id))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if comment == nil {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	tree, fetchedAt, err := store.GetCommentTree(ctx, h.db, h.q, comment.StoryID, filter)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	node := store.FindComment(tree, id)
+	if node == nil {
+		// Deleted without replies
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+
+	replies, next := cq.apply(node.Children)
+	node.Children = []*store.CommentNode{}
+
+	resp := map[string]interface{}{
+		"story_id":   comment.StoryID,
+		"fetched_at": fetchedAt,
+		"comment":    node,
+		"comments":   replies,
+	}
+	if next != "" {
+		resp["next"] = next
+	}
+
+	writeJSON(w, r, resp)
+}
+
+const maxCommentsLimit = 500
+
+// commentQuery selects part of a comment tree: up to depth levels (0 for
+// all), limit top-level comments (0 for all) after a cursor, or with since,
+// the comments posted after it.
+type commentQuery struct {
+	depth     int
+	limit     int
+	after     commentCursor
+	hasCursor bool
+	since     int64
+	hasSince  bool
+}
+
+// commentCursor is the position of the last comment of a page. Pages are in
+// (time, id) order, so the next page starts after it even if that comment
+// has since been deleted.
+type commentCursor struct {
+	time int64
+	id   int
+}
+
+func (c commentCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d.%d", c.time, c.id))
+}
+
+func parseCommentCursor(s string) (commentCursor, error) {
+	var c commentCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	t, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return c, errors.New("malformed cursor")
+	}
+	if c.time, err = strconv.ParseInt(t, 10, 64); err != nil {
+		return c, err
+	}
+	if c.id, err = strconv.Atoi(id); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// compareCommentPosition orders comments by time, then ID.
+func compareCommentPosition(n *store.CommentNode, c commentCursor) int {
+	return cmp.Or(cmp.Compare(n.Time, c.time), cmp.Compare(n.ID, c.id))
+}
+
+func parseCommentQuery(w http.ResponseWriter, r *http.Request) (commentQuery, bool) {
+	var cq commentQuery
+	query := r.URL.Query()
+	if s := query.Get("depth"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid depth: must be at least 1", http.StatusBadRequest)
+			return cq, false
+		}
+		cq.depth = n
+	}
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return cq, false
+		}
+		cq.limit = min(n, maxCommentsLimit)
+	}
+	if s := query.Get("cursor"); s != "" {
+		after, err := parseCommentCursor(s)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return cq, false
+		}
+		cq.after, cq.hasCursor = after, true
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := parseTimeParam(s)
+		if err != nil {
+			http.Error(w, "invalid since: use unix seconds, YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return cq, false
+		}
+		cq.since, cq.hasSince = since, true
+	}
+	return cq, true
+}
+
+// apply returns the page of nodes cq selects and the cursor of the next
+// page, if any. Nodes must be in (time, id) order and are modified in place.
+func (cq commentQuery) apply(nodes []*store.CommentNode) ([]*store.CommentNode, string) {
+	if cq.hasSince {
+		nodes = newerComments(nodes, cq.since, cq.depth)
+	} else if cq.depth > 0 {
+		limitDepth(nodes, cq.depth)
+	}
+
+	if cq.hasCursor {
+		i := slices.IndexFunc(nodes, func(n *store.CommentNode) bool { return compareCommentPosition(n, cq.after) > 0 })
+		if i < 0 {
+			i = len(nodes)
+		}
+		nodes = nodes[i:]
+	}
+	var next string
+	if cq.limit > 0 && len(nodes) > cq.limit {
+		nodes = nodes[:cq.limit]
+		last := nodes[len(nodes)-1]
+		next = commentCursor{time: last.Time, id: last.ID}.String()
+	}
+	if len(nodes) == 0 {
+		nodes = []*store.CommentNode{}
+	}
+	return nodes, next
+}
+
+// limitDepth cuts the tree below depth levels, counting what was cut in More.
+func limitDepth(nodes []*store.CommentNode, depth int) {
+	for _, n := range nodes {
+		if depth > 1 {
+			limitDepth(n.Children, depth-1)
+			continue
+		}
+		n.More = descendants(n)
+		n.Children = []*store.CommentNode{}
+	}
+}
+
+// newerComments flattens the comments within depth levels (0 for all)
+// posted after since, oldest first.
+func newerComments(nodes []*store.CommentNode, since int64, depth int) []*store.CommentNode {
+	var out []*store.CommentNode
+	var walk func(nodes []*store.CommentNode, level int)
+	walk = func(nodes []*store.CommentNode, level int) {
+		for _, n := range nodes {
+			if n.Time > since {
+				out = append(out, n)
+			}
+			if depth == 0 || level < depth {
+				walk(n.Children, level+1)
+			}
+		}
+	}
+	walk(nodes, 1)
+	for _, n := range out {
+		n.Children = []*store.CommentNode{}
+	}
+	slices.SortStableFunc(out, func(a, b *store.CommentNode) int {
+		return cmp.Or(cmp.Compare(a.Time, b.Time), cmp.Compare(a.ID, b.ID))
+	})
+	return out
+}
+
+const (
+	defaultRevisionsLimit = 100
+	maxRevisionsLimit     = 500
+)
+
+// GetRevisions handles GET /api/stories/{id}/comments/revisions?limit=
+// Revisions are newest first.
+func (h *CommentsHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultRevisionsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxRevisionsLimit)
+	}
+
+	revisions, err := h.q.ListCommentRevisionsByStory(r.Context(), h.db, store.ListCommentRevisionsByStoryParams{StoryID: id, Limit: limit})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"story_id":  id,
+		"revisions": revisions,
+	})
+}
+
+// renderComments converts a comment tree for rendering.
+func renderComments(nodes []*store.CommentNode) []*render.Comment {
+	var out []*render.Comment
+	for _, n := range nodes {
+		c := &render.Comment{Time: time.Unix(n.Time, 0), Children: renderComments(n.Children)}
+		if n.By != nil {
+			c.By = *n.By
+		}
+		switch {
+		case n.Deleted:
+			c.Note = "[deleted]"
+		case n.Dead:
+			c.Note = "[dead]"
+		case n.Muted:
+			c.Note = "[muted]"
+		case n.Text != nil:
+			c.HTML = *n.Text
+		}
+		out = append(out, c)
+	}
+	return out
+}
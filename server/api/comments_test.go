@@ -0,0 +1,86 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+func commentNodes(times ...int64) []*store.CommentNode {
+	var nodes []*store.CommentNode
+	for i, t := range times {
+		nodes = append(nodes, &store.CommentNode{Comment: &store.Comment{ID: i + 1, Time: t}, Children: []*store.CommentNode{}})
+	}
+	return nodes
+}
+
+func nodeIDs(nodes []*store.CommentNode) []int {
+	ids := []int{}
+	for _, n := range nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestCommentQueryPages(t *testing.T) {
+	nodes := commentNodes(100, 100, 200, 300, 400)
+	cq := commentQuery{limit: 2}
+
+	var pages [][]int
+	for {
+		page, next := cq.apply(nodes)
+		pages = append(pages, nodeIDs(page))
+		if next == "" {
+			break
+		}
+		after, err := parseCommentCursor(next)
+		if err != nil {
+			t.Fatalf("parse cursor %q: %v", next, err)
+		}
+		cq.after, cq.hasCursor = after, true
+	}
+
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	if !slices.EqualFunc(pages, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestCommentQueryCursorAfterDeletion(t *testing.T) {
+	nodes := commentNodes(100, 200, 300, 400)
+	page, next := commentQuery{limit: 2}.apply(nodes)
+	if got := nodeIDs(page); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("first page = %v", got)
+	}
+	after, err := parseCommentCursor(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Comment 2, the last one on the first page, is deleted and pruned.
+	remaining := slices.Delete(slices.Clone(nodes), 1, 2)
+	page, next = commentQuery{limit: 2, after: after, hasCursor: true}.apply(remaining)
+	if got := nodeIDs(page); !slices.Equal(got, []int{3, 4}) || next != "" {
+		t.Errorf("second page = %v, next %q; want [3 4] and no next", got, next)
+	}
+
+	// A cursor past the end returns an empty page.
+	page, _ = commentQuery{after: commentCursor{time: 500, id: 9}, hasCursor: true}.apply(nodes)
+	if page == nil || len(page) != 0 {
+		t.Errorf("page past the end = %v, want empty", nodeIDs(page))
+	}
+}
+
+func TestParseCommentCursor(t *testing.T) {
+	c := commentCursor{time: 1700000000, id: 42}
+	got, err := parseCommentCursor(c.String())
+	if err != nil || got != c {
+		t.Errorf("round trip = %+v, %v; want %+v", got, err, c)
+	}
+	for _, s := range []string{"!!", "NDI", "YS5i"} { // invalid base64, "42", "a.b"
+		if _, err := parseCommentCursor(s); err == nil {
+			t.Errorf("parseCommentCursor(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/digest"
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+const (
+	defaultDigestStories = 10
+	maxDigestStories     = 30
+)
+
+type DigestHandler struct {
+	db       *sql.DB
+	q        *store.Queries
+	digester *worker.Digester
+}
+
+func NewDigestHandler(db *sql.DB, q *store.Queries, digester *worker.Digester) *DigestHandler {
+	return &DigestHandler{db: db, q: q, digester: digester}
+}
+
+// digestRequest is the body of digest settings updates. Weekday is used by
+// weekly digests, 0 being Sunday.
+type digestRequest struct {
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+	Hour      int    `json:"hour"`
+	Weekday   int    `json:"weekday"`
+	Timezone  string `json:"timezone"`
+	Count     int    `json:"count"`
+}
+
+// GetSettings handles GET /api/digest
+func (h *DigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	settings, err := store.Nullable(h.q.GetDigestSettings(ctx, h.db, UserSub(ctx)))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if settings == nil {
+		http.Error(w, "not subscribed", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, settings)
+}
+
+// UpdateSettings handles PUT /api/digest with
+// {"email": "...", "frequency": "daily"|"weekly", "hour": 7, "weekday": 1, "timezone": "Europe/Berlin", "count": 10}
+// hour is local to timezone (default UTC), and count defaults to 10.
+func (h *DigestHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req digestRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.Count == 0 {
+		req.Count = defaultDigestStories
+	}
+
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil {
+		http.Error(w, "invalid email", http.StatusBadRequest)
+		return
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		http.Error(w, "invalid timezone: use an IANA name such as America/New_York", http.StatusBadRequest)
+		return
+	}
+	schedule := digest.Schedule{Frequency: req.Frequency, Hour: req.Hour, Weekday: time.Weekday(req.Weekday), Location: loc}
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, "invalid schedule: frequency must be daily or weekly, hour 0-23 and weekday 0-6", http.StatusBadRequest)
+		return
+	}
+	if req.Count < 1 || req.Count > maxDigestStories {
+		http.Error(w, "invalid count: must be 1-30", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.q.UpsertDigestSettings(ctx, h.db, store.UpsertDigestSettingsParams{
+		UserSub: UserSub(ctx), Email: addr.Address, Frequency: req.Frequency,
+		SendHour: req.Hour, Weekday: req.Weekday, Timezone: loc.String(),
+		StoryCount: req.Count, NextSendAt: schedule.Next(time.Now()).Unix(),
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, settings)
+}
+
+// DeleteSettings handles DELETE /api/digest
+func (h *DigestHandler) DeleteSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	n, err := h.q.DeleteDigestSettings(ctx, h.db, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "not subscribed", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview handles GET /api/digest/preview?format=html|text
+// It renders the digest the user would receive now, or a default daily
+// digest if they aren't subscribed.
+func (h *DigestHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "text" {
+		http.Error(w, "invalid format: must be html or text", http.StatusBadRequest)
+		return
+	}
+
+	user := UserSub(ctx)
+	settings, err := store.Nullable(h.q.GetDigestSettings(ctx, h.db, user))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	frequency, count, now := digest.Daily, defaultDigestStories, time.Now().UTC()
+	if settings != nil {
+		frequency, count = settings.Frequency, settings.StoryCount
+		if schedule, err := worker.ScheduleOf(settings); err == nil {
+			now = now.In(schedule.Location)
+		}
+	}
+
+	dg, err := h.digester.Build(ctx, user, frequency, count, now)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	html, text, err := digest.Render(dg)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(text))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/urlnorm"
+)
+
+const (
+	defaultDomainsLimit = 100
+	maxDomainsLimit     = 500
+)
+
+type DomainsHandler struct {
+	db *sql.DB
+	q  *store.Queries
+}
+
+func NewDomainsHandler(db *sql.DB, q *store.Queries) *DomainsHandler {
+	return &DomainsHandler{db: db, q: q}
+}
+
+// domainStats is a site's aggregate over stored stories. ExtractionRate is
+// the fraction of attempted article extractions that succeeded, or nil if
+// none were attempted.
+type domainStats struct {
+	Domain         string   `json:"domain"`
+	StoryCount     int      `json:"story_count"`
+	AvgScore       float64  `json:"avg_score"`
+	Extractions    int      `json:"extractions"`
+	ExtractionRate *float64 `json:"extraction_rate"`
+}
+
+func newDomainStats(domain string, stories int, avgScore float64, attempts, successes int) domainStats {
+	d := domainStats{Domain: domain, StoryCount: stories, AvgScore: avgScore, Extractions: attempts}
+	if attempts > 0 {
+		rate := float64(successes) / float64(attempts)
+		d.ExtractionRate = &rate
+	}
+	return d
+}
+
+// domainParam reads the optional domain= filter, reduced to its registrable
+// domain so that "www.example.com" matches stories on "example.com".
+func domainParam(r *http.Request) *string {
+	d := urlnorm.Domain(r.URL.Query().Get("domain"))
+	if d == "" {
+		return nil
+	}
+	return &d
+}
+
+// ListDomains handles GET /api/domains?limit=
+// Domains are ordered by story count, highest first.
+func (h *DomainsHandler) ListDomains(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDomainsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxDomainsLimit)
+	}
+
+	rows, err := h.q.ListDomainStats(r.Context(), h.db, limit)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	domains := make([]domainStats, 0, len(rows))
+	for _, row := range rows {
+		if row.Domain == nil {
+			continue
+		}
+		domains = append(domains, newDomainStats(*row.Domain, row.StoryCount, row.AvgScore, row.ExtractionAttempts, row.ExtractionSuccesses))
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"domains": domains,
+	})
+}
+
+// ListDomainStories handles GET /api/domains/{domain}/stories?page=N
+// Stories are newest first.
+func (h *DomainsHandler) ListDomainStories(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	domain := urlnorm.Domain(r.PathValue("domain"))
+	if domain == "" {
+		http.Error(w, "invalid domain", http.StatusBadRequest)
+		return
+	}
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			page = n
+		}
+	}
+	pageSize := 30
+
+	row, err := store.Nullable(h.q.GetDomainStats(ctx, h.db, &domain))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if row == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	stories, err := h.q.ListStoriesByDomain(ctx, h.db, store.ListStoriesByDomainParams{
+		Domain: &domain, Limit: pageSize, Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"domain":  newDomainStats(domain, row.StoryCount, row.AvgScore, row.ExtractionAttempts, row.ExtractionSuccesses),
+		"stories": items,
+		"page":    page,
+		"total":   row.StoryCount,
+	})
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/epub"
+	"github.com/danielmmetz/hn-client/server/safehttp"
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+const (
+	defaultExportStories = 10
+	maxExportStories     = 50
+	maxExportThreads     = 10
+	// exportThreadDepth is how many levels of replies follow a top comment.
+	exportThreadDepth = 3
+	// Images over maxImageBytes are left out, as are any once a book holds
+	// maxBookImageBytes of them.
+	maxImageBytes     = 5 << 20
+	maxBookImageBytes = 50 << 20
+	imageTimeout      = 20 * time.Second
+)
+
+var errImageTooLarge = errors.New("image too large")
+
+type ExportHandler struct {
+	db     *sql.DB
+	q      *store.Queries
+	ranker *worker.Ranker
+	client *http.Client
+}
+
+func NewExportHandler(db *sql.DB, q *store.Queries, ranker *worker.Ranker) *ExportHandler {
+	return &ExportHandler{db: db, q: q, ranker: ranker, client: safehttp.NewClient(imageTimeout)}
+}
+
+// EPUB handles GET /api/export/epub?ids=1,2,3 or ?period=day&count=20, with
+// an optional &comments=N
+// It packages the stories' extracted articles, with their images, into an
+// EPUB 3 book with a chapter per story. With comments, each chapter ends
+// with the story's top N comment threads. Stories picked by period skip
+// those hidden by the reader's filters.
+func (h *ExportHandler) EPUB(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	threads := 0
+	if c := query.Get("comments"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 || n > maxExportThreads {
+			http.Error(w, "invalid comments: must be 0-10", http.StatusBadRequest)
+			return
+		}
+		threads = n
+	}
+
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	var stories []*store.Story
+	var title, filename string
+	switch period := query.Get("period"); {
+	case query.Get("ids") != "" && period != "":
+		http.Error(w, "ids cannot be combined with period", http.StatusBadRequest)
+		return
+	case query.Get("ids") != "":
+		var ids []int
+		for _, s := range strings.Split(query.Get("ids"), ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				http.Error(w, "invalid ids: must be comma-separated story IDs", http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maxExportStories {
+			http.Error(w, "too many ids: at most 50", http.StatusBadRequest)
+			return
+		}
+		if stories, err = h.storiesByIDs(ctx, ids); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		title = "Hacker News reading list"
+		filename = "hn-reading-list-" + now.Format(time.DateOnly) + ".epub"
+	case period != "":
+		if _, _, ok := worker.PeriodRange(period, now); !ok {
+			http.Error(w, "invalid period: must be day, yesterday, week, month, or year", http.StatusBadRequest)
+			return
+		}
+		count := defaultExportStories
+		if c := query.Get("count"); c != "" {
+			if count, err = strconv.Atoi(c); err != nil || count < 1 || count > maxExportStories {
+				http.Error(w, "invalid count: must be 1-50", http.StatusBadRequest)
+				return
+			}
+		}
+		ranked, err := h.ranker.Ranking(ctx, period, worker.DefaultAlgo(period), now)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, s := range ranked {
+			if len(stories) == count {
+				break
+			}
+			if !s.Dead && !filter.Hides(s) {
+				stories = append(stories, s)
+			}
+		}
+		title = fmt.Sprintf("Hacker News: top stories of the %s", period)
+		if period == worker.PeriodYesterday {
+			title = "Hacker News: top stories of yesterday"
+		}
+		filename = fmt.Sprintf("hn-%s-%s.epub", period, now.Format(time.DateOnly))
+	default:
+		http.Error(w, "missing ids or period", http.StatusBadRequest)
+		return
+	}
+	if len(stories) == 0 {
+		http.Error(w, "no stories found", http.StatusNotFound)
+		return
+	}
+
+	book := epub.NewBook(title)
+	book.Creator = "Hacker News"
+	var imageBytes atomic.Int64
+	fetch := func(ctx context.Context, src string) ([]byte, error) {
+		data, err := h.fetchImage(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		if imageBytes.Add(int64(len(data))) > maxBookImageBytes {
+			return nil, errImageTooLarge
+		}
+		return data, nil
+	}
+	for _, s := range stories {
+		body, byline, language, err := h.chapter(ctx, s, filter, threads)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if body, err = book.EmbedImages(ctx, body, fetch); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if err := book.AddChapter(s.Title, byline, language, body); err != nil {
+			slog.Error("epub chapter failed", "story_id", s.ID, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		slog.Error("epub export failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// storiesByIDs returns the stored stories with the given IDs, in order.
+func (h *ExportHandler) storiesByIDs(ctx context.Context, ids []int) ([]*store.Story, error) {
+	rows, err := h.q.GetStoriesByIDs(ctx, h.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*store.Story, len(rows))
+	for _, s := range rows {
+		byID[s.ID] = s
+	}
+	var stories []*store.Story
+	for _, id := range ids {
+		if s, ok := byID[id]; ok {
+			stories = append(stories, s)
+		}
+	}
+	return stories, nil
+}
+
+// chapter returns the HTML body of a story's chapter: a line of metadata,
+// the post's text, the extracted article and the top comment threads.
+// Stored text is sanitized already.
+func (h *ExportHandler) chapter(ctx context.Context, s *store.Story, filter *store.StoryFilter, threads int) (body, byline, language string, err error) {
+	article, err := store.Nullable(h.q.GetArticleByStoryID(ctx, h.db, s.ID))
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var b strings.Builder
+	discussion := fmt.Sprintf("https://news.ycombinator.com/item?id=%d", s.ID)
+	b.WriteString(`<p class="meta">`)
+	if s.URL != nil {
+		site := *s.URL
+		if s.Domain != nil {
+			site = *s.Domain
+		}
+		fmt.Fprintf(&b, `<a href="%s">%s</a> · `, html.EscapeString(*s.URL), html.EscapeString(site))
+	}
+	fmt.Fprintf(&b, `%d points by %s · <a href="%s">%d comments</a></p>`,
+		s.Score, html.EscapeString(s.By), discussion, s.Descendants)
+
+	if s.Text != nil {
+		b.WriteString(*s.Text)
+	}
+	switch {
+	case article != nil && article.Content != nil && *article.Content != "":
+		b.WriteString(*article.Content)
+		if article.Byline != nil {
+			byline = *article.Byline
+		}
+		if article.Language != nil {
+			language = *article.Language
+		}
+	case s.URL != nil:
+		fmt.Fprintf(&b, `<p>The article hasn't been extracted. Read it at <a href="%s">%s</a>.</p>`,
+			html.EscapeString(*s.URL), html.EscapeString(*s.URL))
+	}
+
+	if threads > 0 {
+		roots, _, err := store.GetCommentTree(ctx, h.db, h.q, s.ID, filter)
+		if err != nil {
+			return "", "", "", err
+		}
+		roots = slices.DeleteFunc(roots, func(c *store.CommentNode) bool { return c.Dead || c.Deleted || c.Muted })
+		slices.SortStableFunc(roots, func(a, b *store.CommentNode) int { return descendants(b) - descendants(a) })
+		if len(roots) > 0 {
+			b.WriteString(`<section class="comments"><h2>Top comments</h2>`)
+			for _, c := range roots[:min(threads, len(roots))] {
+				writeThread(&b, c, exportThreadDepth)
+			}
+			b.WriteString(`</section>`)
+		}
+	}
+	return b.String(), byline, language, nil
+}
+
+// writeThread writes a comment and up to depth levels of its replies as
+// nested divs. Dead and muted comments are left out with their replies.
+func writeThread(b *strings.Builder, c *store.CommentNode, depth int) {
+	if c.Dead || c.Muted {
+		return
+	}
+	b.WriteString(`<div class="comment"><p class="meta">`)
+	switch {
+	case c.Deleted:
+		b.WriteString("[deleted]")
+	case c.By != nil:
+		b.WriteString(html.EscapeString(*c.By))
+	}
+	b.WriteString(`</p>`)
+	if c.Text != nil && !c.Deleted {
+		b.WriteString(*c.Text)
+	}
+	if depth > 0 {
+		for _, child := range c.Children {
+			writeThread(b, child, depth-1)
+		}
+	}
+	b.WriteString(`</div>`)
+}
+
+func descendants(c *store.CommentNode) int {
+	n := len(c.Children)
+	for _, child := range c.Children {
+		n += descendants(child)
+	}
+	return n
+}
+
+// fetchImage downloads an image for embedding, up to maxImageBytes.
+func (h *ExportHandler) fetchImage(ctx context.Context, src string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image fetch: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, errImageTooLarge
+	}
+	return data, nil
+}
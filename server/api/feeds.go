@@ -0,0 +1,262 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/feed"
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+// feedSize is the number of stories in a feed.
+const feedSize = 30
+
+type FeedsHandler struct {
+	db        *sql.DB
+	q         *store.Queries
+	topList   *store.TopList
+	ranker    *worker.Ranker
+	publicURL string
+}
+
+// NewFeedsHandler creates a FeedsHandler. Links to discussions point at
+// publicURL if set, and at HN otherwise.
+func NewFeedsHandler(db *sql.DB, q *store.Queries, topList *store.TopList, ranker *worker.Ranker, publicURL string) *FeedsHandler {
+	return &FeedsHandler{db: db, q: q, topList: topList, ranker: ranker, publicURL: strings.TrimRight(publicURL, "/")}
+}
+
+// GetFeed handles GET /feeds/{name}?content=full
+// name is top, or a ranking period (day, yesterday, week, month or year),
+// followed by .atom, .rss or .json. With content=full, entries include the
+// extracted article. Stories hidden by the reader's filters are left out.
+func (h *FeedsHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name, format, _ := strings.Cut(r.PathValue("name"), ".")
+	contentType := feed.ContentType(format)
+	if contentType == "" {
+		http.Error(w, "feed not found: use top, day, yesterday, week, month or year with .atom, .rss or .json", http.StatusNotFound)
+		return
+	}
+
+	var full bool
+	switch r.URL.Query().Get("content") {
+	case "", "summary":
+	case "full":
+		full = true
+	default:
+		http.Error(w, "invalid content: must be summary or full", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	var stories []*store.Story
+	var title string
+	if name == "top" {
+		title = "Hacker News: front page"
+		stories, err = h.topStories(ctx)
+	} else if _, _, ok := worker.PeriodRange(name, time.Now()); ok {
+		title = "Hacker News: top stories of the " + name
+		if name == worker.PeriodYesterday {
+			title = "Hacker News: top stories of yesterday"
+		}
+		stories, err = h.ranker.Ranking(ctx, name, worker.DefaultAlgo(name), time.Now())
+	} else {
+		http.Error(w, "feed not found: use top, day, yesterday, week, month or year with .atom, .rss or .json", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	var visible []*store.Story
+	for _, s := range stories {
+		if len(visible) == feedSize {
+			break
+		}
+		if !s.Dead && !filter.Hides(s) {
+			visible = append(visible, s)
+		}
+	}
+
+	articles := make(map[int]string)
+	if full && len(visible) > 0 {
+		ids := make([]int, len(visible))
+		for i, s := range visible {
+			ids[i] = s.ID
+		}
+		rows, err := h.q.GetArticleContents(ctx, h.db, ids)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, row := range rows {
+			articles[row.StoryID] = *row.Content
+		}
+	}
+
+	base := h.baseURL(r)
+	f := &feed.Feed{
+		Title:       title,
+		Description: "Stories from Hacker News",
+		Link:        base + "/",
+		Self:        base + selfURI(r.URL),
+	}
+	for _, s := range visible {
+		comments := h.commentsLink(s.ID)
+		item := feed.Item{
+			ID:          fmt.Sprintf("https://news.ycombinator.com/item?id=%d", s.ID),
+			Title:       s.Title,
+			URL:         comments,
+			CommentsURL: comments,
+			Author:      s.By,
+			Published:   time.Unix(s.Time, 0),
+			Content:     itemContent(s, articles[s.ID], comments),
+		}
+		if s.URL != nil {
+			item.URL = *s.URL
+		}
+		if fetched := time.Unix(s.FetchedAt, 0); fetched.After(f.Updated) {
+			f.Updated = fetched
+		}
+		f.Items = append(f.Items, item)
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+
+	body, err := feed.Marshal(f, format)
+	if err != nil {
+		slog.Error("feed encoding failed", "feed", r.PathValue("name"), "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, contentType, body)
+}
+
+// topStories returns the stored front-page stories in order. Stories not
+// fetched yet are skipped rather than fetched, since feed readers poll.
+func (h *FeedsHandler) topStories(ctx context.Context) ([]*store.Story, error) {
+	if h.topList.Len() == 0 {
+		return h.q.ListStoriesByRank(ctx, h.db, store.ListStoriesByRankParams{MaxStories: feedSize * 2})
+	}
+	ids := h.topList.IDs()
+	rows, err := h.q.GetStoriesByIDs(ctx, h.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*store.Story, len(rows))
+	for _, s := range rows {
+		byID[s.ID] = s
+	}
+	stories := make([]*store.Story, 0, len(rows))
+	for _, id := range ids {
+		if s, ok := byID[id]; ok {
+			stories = append(stories, s)
+		}
+	}
+	return stories, nil
+}
+
+// baseURL is the external URL of this server.
+func (h *FeedsHandler) baseURL(r *http.Request) string {
+	if h.publicURL != "" {
+		return h.publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// selfURI is the request's path and query without the feed token, so the
+// credential isn't published in the feed and rotating it keeps the feed's
+// identity.
+func selfURI(u *url.URL) string {
+	query := u.Query()
+	query.Del("token")
+	self := url.URL{Path: u.Path, RawQuery: query.Encode()}
+	return self.RequestURI()
+}
+
+func (h *FeedsHandler) commentsLink(id int) string {
+	if h.publicURL != "" {
+		return fmt.Sprintf("%s/#/story/%d", h.publicURL, id)
+	}
+	return fmt.Sprintf("https://news.ycombinator.com/item?id=%d", id)
+}
+
+// itemContent is an entry's HTML: the post's text, the article if given,
+// and a line linking to the discussion. Stored text and articles are
+// already sanitized.
+func itemContent(s *store.Story, article, comments string) string {
+	var b strings.Builder
+	if s.Text != nil {
+		b.WriteString(*s.Text)
+	}
+	b.WriteString(article)
+	fmt.Fprintf(&b, `<p>%d points by %s | <a href="%s">%d comments</a></p>`,
+		s.Score, html.EscapeString(s.By), html.EscapeString(comments), s.Descendants)
+	return b.String()
+}
+
+// GetToken handles GET /api/feeds/token
+func (h *FeedsHandler) GetToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token, err := store.Nullable(h.q.GetFeedToken(ctx, h.db, UserSub(ctx)))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if token == nil {
+		http.Error(w, "no feed token", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, token)
+}
+
+// CreateToken handles POST /api/feeds/token
+// It issues a new token for feed URLs, revoking the previous one.
+func (h *FeedsHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token, err := h.q.SetFeedToken(ctx, h.db, store.SetFeedTokenParams{
+		UserSub: UserSub(ctx), Token: randomString(43), CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, token)
+}
+
+// DeleteToken handles DELETE /api/feeds/token
+func (h *FeedsHandler) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	n, err := h.q.DeleteFeedToken(ctx, h.db, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "no feed token", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// maxRequestBody bounds JSON request bodies.
+const maxRequestBody = 64 << 10
+
+type FiltersHandler struct {
+	db *sql.DB
+	q  *store.Queries
+}
+
+func NewFiltersHandler(db *sql.DB, q *store.Queries) *FiltersHandler {
+	return &FiltersHandler{db: db, q: q}
+}
+
+// filterRequest is the body of filter create and update requests.
+type filterRequest struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// decodeJSON reads a JSON request body into v, replying 400 on failure.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// parseFilterRequest decodes and normalizes a filter body, replying 400 on failure.
+func parseFilterRequest(w http.ResponseWriter, r *http.Request) (filterRequest, bool) {
+	var req filterRequest
+	if !decodeJSON(w, r, &req) {
+		return req, false
+	}
+	value, err := store.NormalizeFilter(req.Kind, req.Value)
+	if err != nil {
+		http.Error(w, "invalid filter: kind must be domain, keyword or user, with a non-empty value", http.StatusBadRequest)
+		return req, false
+	}
+	req.Value = value
+	return req, true
+}
+
+// ListFilters handles GET /api/filters
+func (h *FiltersHandler) ListFilters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	filters, err := h.q.ListFilters(ctx, h.db, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"filters": filters,
+	})
+}
+
+// CreateFilter handles POST /api/filters with {"kind": "domain"|"keyword"|"user", "value": "..."}
+// Domain values are reduced to their registrable domain; all values are
+// matched case-insensitively.
+func (h *FiltersHandler) CreateFilter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	req, ok := parseFilterRequest(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := store.Nullable(h.q.CreateFilter(ctx, h.db, store.CreateFilterParams{
+		UserSub: UserSub(ctx), Kind: req.Kind, Value: req.Value, CreatedAt: time.Now().Unix(),
+	}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if filter == nil {
+		http.Error(w, "filter already exists", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(filter)
+}
+
+// UpdateFilter handles PUT /api/filters/{id} with the same body as CreateFilter.
+func (h *FiltersHandler) UpdateFilter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	req, ok := parseFilterRequest(w, r)
+	if !ok {
+		return
+	}
+
+	existing, err := store.Nullable(h.q.GetFilter(ctx, h.db, store.GetFilterParams{ID: id, UserSub: UserSub(ctx)}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	filter, err := store.Nullable(h.q.UpdateFilter(ctx, h.db, store.UpdateFilterParams{
+		Kind: req.Kind, Value: req.Value, ID: id, UserSub: UserSub(ctx),
+	}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if filter == nil {
+		http.Error(w, "filter already exists", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, r, filter)
+}
+
+// DeleteFilter handles DELETE /api/filters/{id}
+func (h *FiltersHandler) DeleteFilter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.q.DeleteFilter(ctx, h.db, store.DeleteFilterParams{ID: id, UserSub: UserSub(ctx)})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+type HiddenHandler struct {
+	db      *sql.DB
+	q       *store.Queries
+	fetcher *worker.Fetcher
+}
+
+func NewHiddenHandler(db *sql.DB, q *store.Queries, fetcher *worker.Fetcher) *HiddenHandler {
+	return &HiddenHandler{db: db, q: q, fetcher: fetcher}
+}
+
+// Hide handles POST /api/stories/{id}/hide
+// Hidden stories are left out of the reader's story lists.
+func (h *HiddenHandler) Hide(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// The story must be stored for its hidden row to reference it.
+	exists, err := h.q.StoryExists(ctx, h.db, id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if exists == 0 {
+		if fetchErr := h.fetcher.FetchStoryAndWait(ctx, id); fetchErr != nil {
+			slog.Error("on-demand fetch failed", "story_id", id, "error", fetchErr)
+		}
+		if exists, err = h.q.StoryExists(ctx, h.db, id); err != nil || exists == 0 {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := h.q.HideStory(ctx, h.db, store.HideStoryParams{
+		UserSub: UserSub(ctx), StoryID: id, HiddenAt: time.Now().Unix(),
+	}); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"story_id": id,
+		"hidden":   true,
+	})
+}
+
+// Unhide handles POST /api/stories/{id}/unhide
+func (h *HiddenHandler) Unhide(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.q.UnhideStory(ctx, h.db, store.UnhideStoryParams{UserSub: UserSub(ctx), StoryID: id}); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"story_id": id,
+		"hidden":   false,
+	})
+}
+
+// ListHidden handles GET /api/hidden?page=N
+// Stories are ordered by when they were hidden, most recent first.
+func (h *HiddenHandler) ListHidden(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			page = n
+		}
+	}
+	pageSize := 30
+	user := UserSub(ctx)
+
+	total, err := h.q.CountHiddenStories(ctx, h.db, user)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	stories, err := h.q.ListHiddenStories(ctx, h.db, store.ListHiddenStoriesParams{
+		UserSub: user, Limit: pageSize, Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"stories": items,
+		"page":    page,
+		"total":   total,
+	})
+}
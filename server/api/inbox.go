@@ -0,0 +1,189 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+type InboxHandler struct {
+	db       *sql.DB
+	q        *store.Queries
+	hnClient *hn.Client
+	tracker  *worker.ReplyTracker
+}
+
+func NewInboxHandler(db *sql.DB, q *store.Queries, hnClient *hn.Client, tracker *worker.ReplyTracker) *InboxHandler {
+	return &InboxHandler{db: db, q: q, hnClient: hnClient, tracker: tracker}
+}
+
+// linkRequest is the body of account link requests.
+type linkRequest struct {
+	Username string `json:"username"`
+}
+
+// GetAccount handles GET /api/account/hn
+func (h *InboxHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	acct, err := store.Nullable(h.q.GetHNAccount(ctx, h.db, UserSub(ctx)))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if acct == nil {
+		http.Error(w, "not linked", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, acct)
+}
+
+// LinkAccount handles PUT /api/account/hn with {"username": "..."}
+// The username must exist on HN. Linking a different username clears the
+// inbox; replies already on its recent comments are recorded as read.
+func (h *InboxHandler) LinkAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req linkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.hnClient.GetUser(ctx, username)
+	if err != nil {
+		slog.Error("HN user lookup failed", "username", username, "error", err)
+		http.Error(w, "error looking up HN user", http.StatusBadGateway)
+		return
+	}
+	if profile == nil {
+		http.Error(w, "unknown HN user", http.StatusBadRequest)
+		return
+	}
+
+	user := UserSub(ctx)
+	acct, err := store.Nullable(h.q.LinkHNAccount(ctx, h.db, store.LinkHNAccountParams{
+		UserSub: user, Username: profile.ID, LinkedAt: time.Now().Unix(),
+	}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if acct == nil {
+		// Already linked to this username.
+		if acct, err = h.q.GetHNAccount(ctx, h.db, user); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		if err := h.q.ClearInbox(ctx, h.db, user); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.tracker.Trigger(ctx); err != nil {
+			slog.Error("error queueing reply check", "error", err)
+		}
+	}
+
+	writeJSON(w, r, acct)
+}
+
+// UnlinkAccount handles DELETE /api/account/hn
+// The inbox is cleared with it.
+func (h *InboxHandler) UnlinkAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := UserSub(ctx)
+	n, err := h.q.UnlinkHNAccount(ctx, h.db, user)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "not linked", http.StatusNotFound)
+		return
+	}
+	if err := h.q.ClearInbox(ctx, h.db, user); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListInbox handles GET /api/inbox?page=N&unread=true
+// Replies are newest first. story_id and story_title are set when the
+// replied-to comment is stored.
+func (h *InboxHandler) ListInbox(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			page = n
+		}
+	}
+	pageSize := 30
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	user := UserSub(ctx)
+
+	counts, err := h.q.CountInbox(ctx, h.db, user)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	replies, err := h.q.ListInbox(ctx, h.db, store.ListInboxParams{
+		UserSub: user, UnreadOnly: unreadOnly, Limit: pageSize, Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	total := counts.Total
+	if unreadOnly {
+		total = counts.Unread
+	}
+	writeJSON(w, r, map[string]interface{}{
+		"replies": replies,
+		"page":    page,
+		"total":   total,
+		"unread":  counts.Unread,
+	})
+}
+
+// MarkInboxRead handles POST /api/inbox/read with {"ids": [...]}
+// Without IDs, every reply is marked read.
+func (h *InboxHandler) MarkInboxRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req markReadRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	now := time.Now().Unix()
+	var n int64
+	var err error
+	if len(req.IDs) == 0 {
+		n, err = h.q.MarkAllInboxRead(ctx, h.db, store.MarkAllInboxReadParams{ReadAt: &now, UserSub: UserSub(ctx)})
+	} else {
+		n, err = h.q.MarkInboxRead(ctx, h.db, store.MarkInboxReadParams{ReadAt: &now, UserSub: UserSub(ctx), Ids: req.IDs})
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"marked": n,
+	})
+}
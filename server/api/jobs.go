@@ -0,0 +1,63 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+const (
+	defaultJobsLimit = 100
+	maxJobsLimit     = 500
+)
+
+type JobsHandler struct {
+	db *sql.DB
+	q  *store.Queries
+}
+
+func NewJobsHandler(db *sql.DB, q *store.Queries) *JobsHandler {
+	return &JobsHandler{db: db, q: q}
+}
+
+// ListJobs handles GET /api/admin/jobs?status=&limit=
+// Running jobs come first, then pending jobs by priority, then finished ones.
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", "pending", "running", "done", "failed":
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultJobsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxJobsLimit)
+	}
+
+	counts, err := h.q.CountJobsByStatus(ctx, h.db)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	list, err := h.q.ListJobs(ctx, h.db, store.ListJobsParams{Status: status, MaxJobs: limit})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"counts": counts,
+		"jobs":   list,
+	})
+}
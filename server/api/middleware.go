@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"time"
@@ -8,7 +9,23 @@ import (
 	"github.com/danielmmetz/hn-client/server/store"
 )
 
-// RequireAuth wraps an http.Handler and returns 401 if no valid session cookie is present.
+// AnonymousUser owns per-user data such as filters when authentication is
+// disabled, so all clients of an open server share it.
+const AnonymousUser = "anonymous"
+
+type userSubKey struct{}
+
+// UserSub returns the authenticated user's subject from the request
+// context, or AnonymousUser.
+func UserSub(ctx context.Context) string {
+	if sub, ok := ctx.Value(userSubKey{}).(string); ok {
+		return sub
+	}
+	return AnonymousUser
+}
+
+// RequireAuth wraps an http.Handler and returns 401 if no valid session cookie
+// is present. The session's user is available to next through UserSub.
 func RequireAuth(db *sql.DB, q *store.Queries, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie(sessionCookieName)
@@ -25,7 +42,7 @@ func RequireAuth(db *sql.DB, q *store.Queries, next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userSubKey{}, sess.UserSub)))
 	})
 }
 
@@ -33,3 +50,38 @@ func RequireAuth(db *sql.DB, q *store.Queries, next http.Handler) http.Handler {
 func RequireAuthFunc(db *sql.DB, q *store.Queries, next http.HandlerFunc) http.Handler {
 	return RequireAuth(db, q, http.HandlerFunc(next))
 }
+
+// RequireFeedToken authenticates feed requests by their token query
+// parameter, since feed readers can't sign in. Requests without one fall
+// back to the session cookie.
+func RequireFeedToken(db *sql.DB, q *store.Queries, next http.HandlerFunc) http.Handler {
+	withSession := RequireAuthFunc(db, q, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			withSession.ServeHTTP(w, r)
+			return
+		}
+
+		sub, err := q.GetFeedTokenUser(r.Context(), db, token)
+		if err != nil {
+			http.Error(w, "invalid feed token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userSubKey{}, sub)))
+	})
+}
+
+// RequireAdmin wraps an http.Handler and returns 403 unless the request's
+// user is one of admins. It must run inside RequireAuth, or with
+// AnonymousUser listed when authentication is disabled.
+func RequireAdmin(admins map[string]bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !admins[UserSub(r.Context())] {
+			http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/webpush"
+)
+
+type PushHandler struct {
+	db   *sql.DB
+	q    *store.Queries
+	keys *webpush.VAPIDKeys
+}
+
+func NewPushHandler(db *sql.DB, q *store.Queries, keys *webpush.VAPIDKeys) *PushHandler {
+	return &PushHandler{db: db, q: q, keys: keys}
+}
+
+// subscriptionRequest is a browser PushSubscription as serialized by its
+// toJSON method.
+type subscriptionRequest struct {
+	Endpoint       string `json:"endpoint"`
+	ExpirationTime *int64 `json:"expirationTime"`
+	Keys           struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// GetKey handles GET /api/push/key
+// The public key is the applicationServerKey to subscribe with.
+func (h *PushHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, map[string]interface{}{
+		"public_key": h.keys.PublicKey(),
+	})
+}
+
+// Subscribe handles POST /api/push/subscribe with a PushSubscription
+// Watch alerts, inbox replies and trending stories are pushed to it.
+func (h *PushHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req subscriptionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	sub := webpush.Subscription{Endpoint: req.Endpoint, P256dh: req.Keys.P256dh, Auth: req.Keys.Auth}
+	if err := sub.Validate(); err != nil {
+		http.Error(w, "invalid subscription", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.q.UpsertPushSubscription(ctx, h.db, store.UpsertPushSubscriptionParams{
+		UserSub: UserSub(ctx), Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth, CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       stored.ID,
+		"endpoint": stored.Endpoint,
+	})
+}
+
+// Unsubscribe handles POST /api/push/unsubscribe with {"endpoint": "..."}
+func (h *PushHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	n, err := h.q.DeletePushSubscriptionByEndpoint(ctx, h.db, store.DeletePushSubscriptionByEndpointParams{
+		Endpoint: req.Endpoint, UserSub: UserSub(ctx),
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -11,7 +12,7 @@ import (
 	"time"
 
 	"github.com/danielmmetz/hn-client/server/hn"
-	"github.com/danielmmetz/hn-client/server/readability"
+	"github.com/danielmmetz/hn-client/server/jobs"
 	"github.com/danielmmetz/hn-client/server/sse"
 	"github.com/danielmmetz/hn-client/server/store"
 	"github.com/danielmmetz/hn-client/server/worker"
@@ -23,8 +24,17 @@ const (
 	rateLimitSweepAge = 60 * time.Second
 )
 
+// jobRefresh refetches a story on a user's request and announces the result.
+const jobRefresh = "refresh"
+
+type refreshJob struct {
+	StoryID   int  `json:"story_id"`
+	ReExtract bool `json:"re_extract"`
+}
+
 type RefreshHandler struct {
 	fetcher  *worker.Fetcher
+	jobs     *jobs.Queue
 	hnClient *hn.Client
 	db       *sql.DB
 	q        *store.Queries
@@ -34,15 +44,19 @@ type RefreshHandler struct {
 	lastFetch map[int]time.Time
 }
 
-func NewRefreshHandler(fetcher *worker.Fetcher, hnClient *hn.Client, db *sql.DB, q *store.Queries, broker *sse.Broker) *RefreshHandler {
-	return &RefreshHandler{
+// NewRefreshHandler creates a RefreshHandler and registers its job handler with queue.
+func NewRefreshHandler(fetcher *worker.Fetcher, queue *jobs.Queue, hnClient *hn.Client, db *sql.DB, q *store.Queries, broker *sse.Broker) *RefreshHandler {
+	h := &RefreshHandler{
 		fetcher:   fetcher,
+		jobs:      queue,
 		hnClient:  hnClient,
 		db:        db,
 		q:         q,
 		broker:    broker,
 		lastFetch: make(map[int]time.Time),
 	}
+	queue.Handle(jobRefresh, h.runRefresh)
+	return h
 }
 
 // Refresh handles POST /api/stories/{id}/refresh
@@ -70,14 +84,22 @@ func (h *RefreshHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 	reExtract := r.URL.Query().Get("article") == "true"
 
+	key := fmt.Sprintf("%s:%d", jobRefresh, id)
+	if reExtract {
+		key += ":article"
+	}
+	if _, err := h.jobs.Enqueue(r.Context(), jobRefresh, key, refreshJob{StoryID: id, ReExtract: reExtract}, jobs.PriorityUser); err != nil {
+		slog.Error("refresh: error queueing job", "story_id", id, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":   "accepted",
 		"story_id": id,
 	})
-
-	go h.doRefresh(context.Background(), id, reExtract)
 }
 
 func (h *RefreshHandler) sweepLocked(now time.Time) {
@@ -88,18 +110,26 @@ func (h *RefreshHandler) sweepLocked(now time.Time) {
 	}
 }
 
-func (h *RefreshHandler) doRefresh(ctx context.Context, id int, reExtract bool) {
+func (h *RefreshHandler) runRefresh(ctx context.Context, payload json.RawMessage) error {
+	var p refreshJob
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return jobs.Permanent(fmt.Errorf("decode payload: %w", err))
+	}
+	id := p.StoryID
+
 	if err := h.fetcher.FetchStoryWithComments(ctx, id, nil); err != nil {
 		slog.Error("refresh: error fetching story", "story_id", id, "error", err)
-		return
+		return err
 	}
 
-	if reExtract {
+	if p.ReExtract {
 		story, err := store.Nullable(h.q.GetStoryByID(ctx, h.db, id))
 		if err != nil || story == nil {
 			slog.Warn("refresh: cannot find story for article extraction", "story_id", id)
 		} else if story.URL != nil {
-			h.extractArticle(ctx, id, *story.URL)
+			// Already on a worker: extract inline so the events below
+			// go out after the new article is stored.
+			h.fetcher.ExtractArticle(ctx, id, *story.URL)
 		}
 	}
 
@@ -115,28 +145,5 @@ func (h *RefreshHandler) doRefresh(ctx context.Context, id int, reExtract bool)
 		"timestamp": now,
 	})
 	h.broker.Publish("comments_updated", string(commentsData))
-}
-
-func (h *RefreshHandler) extractArticle(ctx context.Context, storyID int, url string) {
-	now := time.Now().Unix()
-	article, err := readability.Extract(ctx, url)
-	if err != nil {
-		slog.Error("refresh: article extraction failed", "story_id", storyID, "error", err)
-		h.q.UpsertArticle(ctx, h.db, store.UpsertArticleParams{
-			StoryID:          storyID,
-			ExtractionFailed: true,
-			FetchedAt:        now,
-		})
-		return
-	}
-
-	h.q.UpsertArticle(ctx, h.db, store.UpsertArticleParams{
-		StoryID:          storyID,
-		Content:          &article.Content,
-		Title:            &article.Title,
-		Excerpt:          &article.Excerpt,
-		Byline:           &article.Byline,
-		ExtractionFailed: false,
-		FetchedAt:        now,
-	})
+	return nil
 }
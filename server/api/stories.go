@@ -1,13 +1,17 @@
 package api
 
 import (
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/danielmmetz/hn-client/server/store"
 	"github.com/danielmmetz/hn-client/server/worker"
@@ -18,13 +22,16 @@ type StoriesHandler struct {
 	q       *store.Queries
 	topList *store.TopList
 	fetcher *worker.Fetcher
+	ranker  *worker.Ranker
 }
 
-func NewStoriesHandler(db *sql.DB, q *store.Queries, topList *store.TopList, fetcher *worker.Fetcher) *StoriesHandler {
-	return &StoriesHandler{db: db, q: q, topList: topList, fetcher: fetcher}
+func NewStoriesHandler(db *sql.DB, q *store.Queries, topList *store.TopList, fetcher *worker.Fetcher, ranker *worker.Ranker) *StoriesHandler {
+	return &StoriesHandler{db: db, q: q, topList: topList, fetcher: fetcher, ranker: ranker}
 }
 
-// ListStories handles GET /api/stories?page=N
+// ListStories handles GET /api/stories?page=N&domain=
+// With domain, only front-page stories from that site are listed. Stories
+// hidden by the reader's filters are dropped before paginating.
 func (h *StoriesHandler) ListStories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	page := 1
@@ -33,47 +40,103 @@ func (h *StoriesHandler) ListStories(w http.ResponseWriter, r *http.Request) {
 			page = n
 		}
 	}
+	domain := domainParam(r)
 
 	pageSize := 30
+	offset := (page - 1) * pageSize
 
-	// Try TopList first
-	pageIDs, total := h.topList.Page(page, pageSize)
-	if total > 0 {
-		h.serveFromTopList(w, r, page, pageIDs, total)
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Try TopList first. Filtering by domain uses the stored ranks instead,
+	// since only stored stories have a known domain.
+	if domain == nil && h.topList.Len() > 0 {
+		if filter.Empty() {
+			pageIDs, total := h.topList.Page(page, pageSize)
+			h.serveFromTopList(w, r, page, pageIDs, total, filter)
+			return
+		}
+		ids, err := h.visibleIDs(ctx, h.topList.IDs(), filter)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		h.serveFromTopList(w, r, page, pageOf(ids, offset, pageSize), len(ids), filter)
 		return
 	}
 
 	// Fallback: TopList not yet populated, use rank-based query
-	totalCount, err := h.q.CountRankedStories(ctx, h.db)
+	totalCount, err := h.q.CountRankedStories(ctx, h.db, domain)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	stories, err := h.q.ListStoriesByRank(ctx, h.db, store.ListStoriesByRankParams{
-		Limit: pageSize, Offset: (page - 1) * pageSize,
-	})
+	var stories []*store.Story
+	if filter.Empty() {
+		stories, err = h.q.ListStoriesByRank(ctx, h.db, store.ListStoriesByRankParams{
+			Domain: domain, MaxStories: pageSize, Skip: offset,
+		})
+	} else {
+		stories, err = h.q.ListStoriesByRank(ctx, h.db, store.ListStoriesByRankParams{
+			Domain: domain, MaxStories: totalCount, Skip: 0,
+		})
+		stories = slices.DeleteFunc(stories, filter.Hides)
+		totalCount = len(stories)
+		stories = pageOf(stories, offset, pageSize)
+	}
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	if stories == nil {
-		stories = []*store.Story{}
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
 	}
 
 	resp := map[string]interface{}{
-		"stories":  stories,
+		"stories":  items,
 		"page":     page,
 		"total":    totalCount,
 		"complete": true,
 	}
+	if domain != nil {
+		resp["domain"] = *domain
+	}
 
 	writeJSON(w, r, resp)
 }
 
+// visibleIDs drops the IDs of stored stories that filter hides. Stories not
+// stored yet are kept; they're fetched on demand and filtered when their page
+// is served.
+func (h *StoriesHandler) visibleIDs(ctx context.Context, ids []int, filter *store.StoryFilter) ([]int, error) {
+	rows, err := h.q.GetStoriesByIDs(ctx, h.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[int]bool)
+	for _, st := range rows {
+		if filter.Hides(st) {
+			hidden[st.ID] = true
+		}
+	}
+	return slices.DeleteFunc(ids, func(id int) bool { return hidden[id] }), nil
+}
+
+// pageOf returns the items of a page starting at offset.
+func pageOf[T any](items []T, offset, pageSize int) []T {
+	return items[min(offset, len(items)):min(offset+pageSize, len(items))]
+}
+
 // serveFromTopList loads stories for the given page IDs from DB, fetching missing ones on-demand.
-func (h *StoriesHandler) serveFromTopList(w http.ResponseWriter, r *http.Request, page int, pageIDs []int, total int) {
+// Fetched stories that filter hides are left out of the page and the total.
+func (h *StoriesHandler) serveFromTopList(w http.ResponseWriter, r *http.Request, page int, pageIDs []int, total int, filter *store.StoryFilter) {
 	ctx := r.Context()
 
 	// Batch-load from DB
@@ -91,13 +154,19 @@ func (h *StoriesHandler) serveFromTopList(w http.ResponseWriter, r *http.Request
 	// Find missing IDs and fetch on-demand (metadata only)
 	for _, id := range pageIDs {
 		if _, ok := storyMap[id]; !ok {
-			if fetchErr := h.fetcher.FetchStorySingleflight(ctx, id); fetchErr != nil {
+			if fetchErr := h.fetcher.FetchStoryAndWait(ctx, id); fetchErr != nil {
 				slog.Error("on-demand fetch failed", "story_id", id, "error", fetchErr)
 				continue
 			}
-			if st, getErr := store.Nullable(h.q.GetStoryByID(ctx, h.db, id)); getErr == nil && st != nil {
-				storyMap[st.ID] = st
+			st, getErr := store.Nullable(h.q.GetStoryByID(ctx, h.db, id))
+			if getErr != nil || st == nil {
+				continue
+			}
+			if filter.Hides(st) {
+				total--
+				continue
 			}
+			storyMap[st.ID] = st
 		}
 	}
 
@@ -112,8 +181,14 @@ func (h *StoriesHandler) serveFromTopList(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
 	resp := map[string]interface{}{
-		"stories":  stories,
+		"stories":  items,
 		"page":     page,
 		"total":    total,
 		"complete": true,
@@ -138,7 +213,7 @@ func (h *StoriesHandler) GetStory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if story == nil {
-		if fetchErr := h.fetcher.FetchStorySingleflight(ctx, id); fetchErr != nil {
+		if fetchErr := h.fetcher.FetchStoryAndWait(ctx, id); fetchErr != nil {
 			slog.Error("on-demand fetch failed", "story_id", id, "error", fetchErr)
 			http.Error(w, "not found", http.StatusNotFound)
 			return
@@ -157,61 +232,220 @@ func (h *StoriesHandler) GetStory(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, r, story)
 }
 
-// TopStories handles GET /api/stories/top?period=day|yesterday|week&page=1
+const (
+	maxSameURLStories    = 50
+	maxSameDomainStories = 20
+)
+
+// Related handles GET /api/stories/{id}/related
+// It returns other submissions of the story's canonical URL and recent
+// stories from the same domain, newest first.
+func (h *StoriesHandler) Related(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	story, err := store.Nullable(h.q.GetStoryByID(ctx, h.db, id))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if story == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	sameURL, sameDomain := []*store.Story{}, []*store.Story{}
+	if story.CanonicalURL != nil {
+		canonical := *story.CanonicalURL
+		sameURL, err = h.q.ListStoriesByCanonicalURL(ctx, h.db, store.ListStoriesByCanonicalURLParams{
+			CanonicalURL: story.CanonicalURL, ID: id, MaxStories: maxSameURLStories,
+		})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		if story.Domain != nil {
+			sameDomain, err = h.q.ListRelatedStoriesByDomain(ctx, h.db, store.ListRelatedStoriesByDomainParams{
+				Domain: *story.Domain, ExcludeURL: canonical, MaxStories: maxSameDomainStories,
+			})
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"story_id":      id,
+		"canonical_url": story.CanonicalURL,
+		"same_url":      sameURL,
+		"same_domain":   sameDomain,
+	})
+}
+
+// TopStories handles GET /api/stories/top?period=&algo=&from=&to=&domain=&page=1
+// period is day, yesterday, week, month or year; passing from (and optionally
+// to) as unix seconds, YYYY-MM-DD or RFC 3339 ranks a custom range within the
+// last 366 days instead.
+// algo names a registered ranking strategy and defaults per period; domain
+// limits the ranking to one site.
 func (h *StoriesHandler) TopStories(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	period := r.URL.Query().Get("period")
-	switch period {
-	case "day", "yesterday", "week":
-	default:
-		http.Error(w, "invalid period: must be day, yesterday, or week", http.StatusBadRequest)
+	query := r.URL.Query()
+	now := time.Now()
+
+	period := query.Get("period")
+	var from, to int64
+	if query.Get("from") != "" || query.Get("to") != "" {
+		if period != "" && period != worker.PeriodCustom {
+			http.Error(w, "from/to cannot be combined with a named period", http.StatusBadRequest)
+			return
+		}
+		period = worker.PeriodCustom
+		var err error
+		if from, err = parseTimeParam(query.Get("from")); err != nil {
+			http.Error(w, "invalid from: use unix seconds, YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		to = now.Unix()
+		if query.Get("to") != "" {
+			if to, err = parseTimeParam(query.Get("to")); err != nil {
+				http.Error(w, "invalid to: use unix seconds, YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+				return
+			}
+		}
+		if from >= to || from < now.Add(-worker.MaxRankingAge).Unix() {
+			http.Error(w, "invalid range: from must precede to and fall within the last 366 days", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var ok bool
+		if from, to, ok = worker.PeriodRange(period, now); !ok {
+			http.Error(w, "invalid period: must be day, yesterday, week, month, or year", http.StatusBadRequest)
+			return
+		}
+	}
+
+	algo := query.Get("algo")
+	if algo == "" {
+		algo = worker.DefaultAlgo(period)
+	}
+	if _, ok := h.ranker.Strategy(algo); !ok {
+		http.Error(w, fmt.Sprintf("invalid algo: must be one of %s", strings.Join(h.ranker.Algos(), ", ")), http.StatusBadRequest)
 		return
 	}
 
 	page := 1
-	if p := r.URL.Query().Get("page"); p != "" {
+	if p := query.Get("page"); p != "" {
 		if n, err := strconv.Atoi(p); err == nil && n > 0 {
 			page = n
 		}
 	}
 
 	pageSize := 30
+	offset := (page - 1) * pageSize
+	domain := domainParam(r)
 
-	total, err := h.q.CountRankingsByPeriod(ctx, h.db, period)
+	filter, err := store.LoadStoryFilter(ctx, h.db, h.q, UserSub(ctx))
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	stories, err := h.q.GetStoriesByPeriod(ctx, h.db, store.GetStoriesByPeriodParams{
-		Period: period, Limit: pageSize, Offset: (page - 1) * pageSize,
-	})
+	var total int
+	var stories []*store.Story
+	if worker.IsMaterializedPeriod(period) {
+		total, err = h.q.CountRankingsByPeriod(ctx, h.db, store.CountRankingsByPeriodParams{Period: period, Algo: algo, Domain: domain})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		// With filters, the whole ranking is loaded and filtered before
+		// paginating so pages stay full.
+		params := store.GetStoriesByPeriodParams{Period: period, Algo: algo, Domain: domain, MaxStories: pageSize, Skip: offset}
+		if !filter.Empty() {
+			params.MaxStories, params.Skip = total, 0
+		}
+		stories, err = h.q.GetStoriesByPeriod(ctx, h.db, params)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !filter.Empty() {
+			stories = slices.DeleteFunc(stories, filter.Hides)
+			total = len(stories)
+			stories = pageOf(stories, offset, pageSize)
+		}
+	} else {
+		ranked, err := h.ranker.Rank(ctx, algo, from, to)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		ranked = slices.DeleteFunc(ranked, func(s *store.Story) bool {
+			return domain != nil && (s.Domain == nil || *s.Domain != *domain) || filter.Hides(s)
+		})
+		total = len(ranked)
+		stories = pageOf(ranked, offset, pageSize)
+	}
+
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	if stories == nil {
-		stories = []*store.Story{}
-	}
-
 	resp := map[string]interface{}{
-		"stories": stories,
+		"stories": items,
 		"page":    page,
 		"total":   total,
 		"period":  period,
+		"algo":    algo,
+	}
+	if period == worker.PeriodCustom {
+		resp["from"] = from
+		resp["to"] = to
+	}
+	if domain != nil {
+		resp["domain"] = *domain
 	}
 
 	writeJSON(w, r, resp)
 }
 
+// parseTimeParam parses a query time as unix seconds, a date (midnight UTC)
+// or an RFC 3339 timestamp.
+func parseTimeParam(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
 func writeJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
 	body, err := json.Marshal(data)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	writeBody(w, r, "application/json", body)
+}
 
+// writeBody writes body with an ETag, or 304 if the client has it already.
+func writeBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
 	etag := fmt.Sprintf(`"%x"`, md5.Sum(body))
 
 	if match := r.Header.Get("If-None-Match"); match == etag {
@@ -219,7 +453,7 @@ func writeJSON(w http.ResponseWriter, r *http.Request, data interface{}) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("ETag", etag)
 	w.Write(body)
 }
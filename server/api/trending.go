@@ -0,0 +1,69 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+type TrendingHandler struct {
+	db       *sql.DB
+	q        *store.Queries
+	trending *worker.Trending
+}
+
+func NewTrendingHandler(db *sql.DB, q *store.Queries, trending *worker.Trending) *TrendingHandler {
+	return &TrendingHandler{db: db, q: q, trending: trending}
+}
+
+type trendingItem struct {
+	*store.StoryListItem
+	Trend worker.TrendingStory `json:"trend"`
+}
+
+// ListTrending handles GET /api/stories/trending
+// Stories are ordered by velocity, fastest first.
+func (h *TrendingHandler) ListTrending(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	trends := h.trending.Stories()
+
+	ids := make([]int, len(trends))
+	for i, t := range trends {
+		ids[i] = t.StoryID
+	}
+	rows, err := h.q.GetStoriesByIDs(ctx, h.db, ids)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[int]*store.Story, len(rows))
+	for _, st := range rows {
+		byID[st.ID] = st
+	}
+
+	// Stories removed by cleanup since they were observed are skipped.
+	stories := make([]*store.Story, 0, len(trends))
+	kept := make([]worker.TrendingStory, 0, len(trends))
+	for _, t := range trends {
+		if st, ok := byID[t.StoryID]; ok {
+			stories = append(stories, st)
+			kept = append(kept, t)
+		}
+	}
+	items, err := store.WithArticleMeta(ctx, h.db, h.q, stories)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]trendingItem, len(items))
+	for i, item := range items {
+		result[i] = trendingItem{StoryListItem: item, Trend: kept[i]}
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"stories": result,
+	})
+}
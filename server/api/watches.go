@@ -0,0 +1,165 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/worker"
+)
+
+type WatchesHandler struct {
+	db      *sql.DB
+	q       *store.Queries
+	watcher *worker.Watcher
+}
+
+func NewWatchesHandler(db *sql.DB, q *store.Queries, watcher *worker.Watcher) *WatchesHandler {
+	return &WatchesHandler{db: db, q: q, watcher: watcher}
+}
+
+// ListWatches handles GET /api/watches
+func (h *WatchesHandler) ListWatches(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	watches, err := h.q.ListWatches(ctx, h.db, UserSub(ctx))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"watches": watches,
+	})
+}
+
+// CreateWatch handles POST /api/watches with {"kind": "domain"|"keyword"|"user", "value": "..."}
+// Values are normalized as for filters. Keyword and domain watches match new
+// stories; user watches match new stories and comments by that user.
+func (h *WatchesHandler) CreateWatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req filterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	value, err := store.NormalizeFilter(req.Kind, req.Value)
+	if err != nil {
+		http.Error(w, "invalid watch: kind must be domain, keyword or user, with a non-empty value", http.StatusBadRequest)
+		return
+	}
+
+	watch, err := store.Nullable(h.q.CreateWatch(ctx, h.db, store.CreateWatchParams{
+		UserSub: UserSub(ctx), Kind: req.Kind, Value: value, CreatedAt: time.Now().Unix(),
+	}))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if watch == nil {
+		http.Error(w, "watch already exists", http.StatusConflict)
+		return
+	}
+	h.watcher.Invalidate()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(watch)
+}
+
+// DeleteWatch handles DELETE /api/watches/{id}
+// The watch's alerts are deleted with it.
+func (h *WatchesHandler) DeleteWatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.q.DeleteWatch(ctx, h.db, store.DeleteWatchParams{ID: id, UserSub: UserSub(ctx)})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	h.watcher.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlerts handles GET /api/alerts?page=N&unread=true
+// Alerts are newest first.
+func (h *WatchesHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			page = n
+		}
+	}
+	pageSize := 30
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	user := UserSub(ctx)
+
+	counts, err := h.q.CountAlerts(ctx, h.db, user)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	alerts, err := h.q.ListAlerts(ctx, h.db, store.ListAlertsParams{
+		UserSub: user, UnreadOnly: unreadOnly, Limit: pageSize, Offset: (page - 1) * pageSize,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	total := counts.Total
+	if unreadOnly {
+		total = counts.Unread
+	}
+	writeJSON(w, r, map[string]interface{}{
+		"alerts": alerts,
+		"page":   page,
+		"total":  total,
+		"unread": counts.Unread,
+	})
+}
+
+// markReadRequest is the body of mark-read requests. Without IDs, all of the
+// user's alerts are marked read.
+type markReadRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// MarkAlertsRead handles POST /api/alerts/read with {"ids": [...]}
+func (h *WatchesHandler) MarkAlertsRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req markReadRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	now := time.Now().Unix()
+	var n int64
+	var err error
+	if len(req.IDs) == 0 {
+		n, err = h.q.MarkAllAlertsRead(ctx, h.db, store.MarkAllAlertsReadParams{ReadAt: &now, UserSub: UserSub(ctx)})
+	} else {
+		n, err = h.q.MarkAlertsRead(ctx, h.db, store.MarkAlertsReadParams{ReadAt: &now, UserSub: UserSub(ctx), Ids: req.IDs})
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"marked": n,
+	})
+}
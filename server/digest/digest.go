@@ -0,0 +1,95 @@
+// Package digest renders ranked stories into HTML and plain-text email and
+// delivers them over SMTP on a per-user schedule.
+package digest
+
+import (
+	"errors"
+	"time"
+)
+
+// Digest frequencies.
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// Digest is one email's worth of stories.
+type Digest struct {
+	Title   string
+	Date    time.Time // in the recipient's time zone
+	Stories []Story
+}
+
+// Story is a ranked story with the article excerpt and top comment, where
+// available.
+type Story struct {
+	Rank        int
+	Title       string
+	URL         string // the link, or the discussion for text posts
+	Domain      string
+	By          string
+	Score       int
+	Comments    int
+	CommentsURL string
+	Excerpt     string
+	TopComment  *Comment
+}
+
+// Comment is a comment quoted in a digest, as plain text.
+type Comment struct {
+	By   string
+	Text string
+}
+
+// Schedule is when a user's digest goes out: daily or weekly at a local hour.
+type Schedule struct {
+	Frequency string
+	Hour      int
+	Weekday   time.Weekday // weekly only
+	Location  *time.Location
+}
+
+// ErrInvalidSchedule is returned by Validate.
+var ErrInvalidSchedule = errors.New("invalid digest schedule")
+
+// Validate checks the frequency, hour and weekday.
+func (s Schedule) Validate() error {
+	if s.Frequency != Daily && s.Frequency != Weekly {
+		return ErrInvalidSchedule
+	}
+	if s.Hour < 0 || s.Hour > 23 || s.Weekday < time.Sunday || s.Weekday > time.Saturday {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
+// Next returns the first send time strictly after after. Days that skip the
+// hour for daylight saving send as soon as the clocks change.
+func (s Schedule) Next(after time.Time) time.Time {
+	local := after.In(s.Location)
+	y, m, d := local.Date()
+	step := 1
+	if s.Frequency == Weekly {
+		d += (int(s.Weekday) - int(local.Weekday()) + 7) % 7
+		step = 7
+	}
+	next := s.sendTime(y, m, d)
+	for !next.After(after) {
+		d += step
+		next = s.sendTime(y, m, d)
+	}
+	return next
+}
+
+// sendTime returns the scheduled hour on the given day. time.Date places a
+// wall time that daylight saving skips before the gap, so that case is moved
+// to the end of the gap.
+func (s Schedule) sendTime(y int, m time.Month, d int) time.Time {
+	t := time.Date(y, m, d, s.Hour, 0, 0, 0, s.Location)
+	if t.Hour() != s.Hour {
+		if _, end := t.ZoneBounds(); !end.IsZero() && end.Sub(t) <= time.Hour {
+			t = end
+		}
+	}
+	return t
+}
@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestScheduleNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, ny)
+	}
+	daily := func(hour int) Schedule { return Schedule{Frequency: Daily, Hour: hour, Location: ny} }
+	weekly := func(day time.Weekday, hour int) Schedule {
+		return Schedule{Frequency: Weekly, Hour: hour, Weekday: day, Location: ny}
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		after    time.Time
+		want     time.Time
+	}{
+		{"daily later today", daily(8), at(2024, 6, 10, 7, 0), at(2024, 6, 10, 8, 0)},
+		{"daily at the hour is strictly after", daily(8), at(2024, 6, 10, 8, 0), at(2024, 6, 11, 8, 0)},
+		{"daily tomorrow", daily(8), at(2024, 6, 10, 9, 0), at(2024, 6, 11, 8, 0)},
+		{"daily month wrap", daily(8), at(2024, 6, 30, 9, 0), at(2024, 7, 1, 8, 0)},
+		{"daily from another zone", daily(8), time.Date(2024, 6, 10, 13, 0, 0, 0, time.UTC), at(2024, 6, 11, 8, 0)},
+
+		// DST starts 2024-03-10 at 02:00 (clocks skip to 03:00).
+		{"daily across spring forward", daily(8), at(2024, 3, 9, 9, 0), at(2024, 3, 10, 8, 0)},
+		{"daily skipped hour", daily(2), at(2024, 3, 9, 3, 0), at(2024, 3, 10, 3, 0)},
+		{"daily after skipped hour", daily(2), at(2024, 3, 10, 3, 0), at(2024, 3, 11, 2, 0)},
+		// DST ends 2024-11-03 at 02:00 (01:00-02:00 repeats).
+		{"daily across fall back", daily(8), at(2024, 11, 2, 9, 0), at(2024, 11, 3, 8, 0)},
+		{"daily repeated hour", daily(1), at(2024, 11, 2, 9, 0), at(2024, 11, 3, 1, 0)},
+		{"daily repeated hour sends once", daily(1), at(2024, 11, 3, 1, 0), at(2024, 11, 4, 1, 0)},
+		{"daily during repeated hour", daily(1), at(2024, 11, 3, 1, 30).Add(time.Hour), at(2024, 11, 4, 1, 0)},
+
+		// 2024-06-10 is a Monday.
+		{"weekly later today", weekly(time.Monday, 9), at(2024, 6, 10, 8, 59), at(2024, 6, 10, 9, 0)},
+		{"weekly at the hour is strictly after", weekly(time.Monday, 9), at(2024, 6, 10, 9, 0), at(2024, 6, 17, 9, 0)},
+		{"weekly later this week", weekly(time.Friday, 9), at(2024, 6, 10, 10, 0), at(2024, 6, 14, 9, 0)},
+		{"weekly wraps past saturday", weekly(time.Monday, 9), at(2024, 6, 15, 10, 0), at(2024, 6, 17, 9, 0)},
+		{"weekly sunday from saturday", weekly(time.Sunday, 9), at(2024, 6, 15, 10, 0), at(2024, 6, 16, 9, 0)},
+		{"weekly saturday from sunday", weekly(time.Saturday, 9), at(2024, 6, 16, 10, 0), at(2024, 6, 22, 9, 0)},
+		{"weekly year wrap", weekly(time.Monday, 9), at(2024, 12, 30, 10, 0), at(2025, 1, 6, 9, 0)},
+		{"weekly across spring forward", weekly(time.Monday, 9), at(2024, 3, 8, 10, 0), at(2024, 3, 11, 9, 0)},
+		{"weekly skipped hour", weekly(time.Sunday, 2), at(2024, 3, 8, 10, 0), at(2024, 3, 10, 3, 0)},
+		{"weekly from a skipped-hour day", weekly(time.Wednesday, 2), at(2024, 3, 10, 0, 30), at(2024, 3, 13, 2, 0)},
+		{"weekly across fall back", weekly(time.Monday, 9), at(2024, 11, 1, 10, 0), at(2024, 11, 4, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got.In(ny), tt.want)
+			}
+		})
+	}
+
+	// Santiago skips midnight: 2024-09-08 starts at 01:00.
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	midnight := Schedule{Frequency: Daily, Hour: 0, Location: santiago}
+	after := time.Date(2024, 9, 7, 12, 0, 0, 0, santiago)
+	if got, want := midnight.Next(after), time.Date(2024, 9, 8, 1, 0, 0, 0, santiago); !got.Equal(want) {
+		t.Errorf("skipped midnight: Next(%v) = %v, want %v", after, got.In(santiago), want)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		ok       bool
+	}{
+		{Schedule{Frequency: Daily, Hour: 0}, true},
+		{Schedule{Frequency: Weekly, Hour: 23, Weekday: time.Saturday}, true},
+		{Schedule{Frequency: "hourly", Hour: 7}, false},
+		{Schedule{Frequency: Daily, Hour: 24}, false},
+		{Schedule{Frequency: Daily, Hour: -1}, false},
+		{Schedule{Frequency: Weekly, Hour: 7, Weekday: 7}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.schedule.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v.Validate() = %v, want ok %v", tt.schedule, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package digest
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// sinkMessage is an envelope and message received by smtpSink.
+type sinkMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpSink accepts one SMTP session on 127.0.0.1 and reports the message it
+// receives.
+func smtpSink(t *testing.T) (addr string, received <-chan sinkMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan sinkMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+		var msg sinkMessage
+		reply("220 sink ready")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 sink")
+			case "MAIL":
+				msg.from = cmd
+				reply("250 ok")
+			case "RCPT":
+				msg.to = append(msg.to, cmd)
+				reply("250 ok")
+			case "DATA":
+				reply("354 send data")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				msg.data = data.String()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				ch <- msg
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestMailerSend(t *testing.T) {
+	addr, received := smtpSink(t)
+	m, err := NewMailer(addr, "", "", "HN Digest <digest@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("word ", 30) + "end"
+	text := "Café naïve = " + long + "\n"
+	html := `<p class="x">Café naïve</p>` + "\n<p>" + long + "</p>\n"
+	if err := m.Send("Reader <reader@example.org>", "Your digest — Café", html, text); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got := <-received
+
+	if got.from != "MAIL FROM:<digest@example.com>" && !strings.HasPrefix(got.from, "MAIL FROM:<digest@example.com> ") {
+		t.Errorf("envelope sender = %q", got.from)
+	}
+	if len(got.to) != 1 || got.to[0] != "RCPT TO:<reader@example.org>" {
+		t.Errorf("envelope recipients = %q", got.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	h := msg.Header
+	if from, err := h.AddressList("From"); err != nil || len(from) != 1 || from[0].Address != "digest@example.com" || from[0].Name != "HN Digest" {
+		t.Errorf("From = %q", h.Get("From"))
+	}
+	if to, err := h.AddressList("To"); err != nil || len(to) != 1 || to[0].Address != "reader@example.org" {
+		t.Errorf("To = %q", h.Get("To"))
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(h.Get("Subject")); err != nil || subject != "Your digest — Café" {
+		t.Errorf("Subject = %q (decoded %q, %v)", h.Get("Subject"), subject, err)
+	}
+	if _, err := h.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+	if id := h.Get("Message-Id"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+	if h.Get("Mime-Version") != "1.0" {
+		t.Errorf("MIME-Version = %q", h.Get("Mime-Version"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" || params["boundary"] == "" {
+		t.Fatalf("Content-Type = %q", h.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatalf("%s part: %v", want.contentType, err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", ct, want.contentType)
+		}
+		if cte := part.Header.Get("Content-Transfer-Encoding"); cte != "quoted-printable" {
+			t.Errorf("%s Content-Transfer-Encoding = %q", want.contentType, cte)
+		}
+		raw, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(string(raw), "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("%s has a %d-character line", want.contentType, len(line))
+			}
+			for _, c := range []byte(line) {
+				if c >= 0x80 {
+					t.Errorf("%s has unencoded 8-bit data: %q", want.contentType, line)
+					break
+				}
+			}
+		}
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+		if err != nil {
+			t.Fatalf("decode %s: %v", want.contentType, err)
+		}
+		if got := strings.ReplaceAll(string(decoded), "\r\n", "\n"); got != want.body {
+			t.Errorf("%s body = %q, want %q", want.contentType, got, want.body)
+		}
+	}
+	if _, err := parts.NextRawPart(); err != io.EOF {
+		t.Errorf("extra part after HTML: %v", err)
+	}
+}
+
+func TestNewMailerErrors(t *testing.T) {
+	if _, err := NewMailer("no-port", "", "", "a@example.com"); err == nil {
+		t.Error("NewMailer accepted an address without a port")
+	}
+	if _, err := NewMailer("localhost:25", "", "", "not an address"); err == nil {
+		t.Error("NewMailer accepted an invalid from address")
+	}
+	m, err := NewMailer("localhost:25", "", "", "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("not an address", "s", "h", "t"); err == nil {
+		t.Error("Send accepted an invalid recipient")
+	}
+}
@@ -0,0 +1,28 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/digest.html.tmpl"))
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templates, "templates/digest.txt.tmpl"))
+)
+
+// Render returns the HTML and plain-text bodies of a digest.
+func Render(d *Digest) (html, text string, err error) {
+	var hb, tb bytes.Buffer
+	if err := htmlTemplate.Execute(&hb, d); err != nil {
+		return "", "", err
+	}
+	if err := textTemplate.Execute(&tb, d); err != nil {
+		return "", "", err
+	}
+	return hb.String(), tb.String(), nil
+}
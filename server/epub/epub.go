@@ -0,0 +1,198 @@
+// Package epub packages HTML articles into EPUB 3 books with a table of
+// contents and embedded images.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"x":   escape,
+	"inc": func(i int) int { return i + 1 },
+}).ParseFS(templates, "templates/*.tmpl"))
+
+// mediaTypes are the image types embedded in books, with their file
+// extensions. Other images are left out.
+var mediaTypes = map[string]string{
+	"image/gif":  ".gif",
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// Book is an EPUB under construction.
+type Book struct {
+	Title    string
+	Creator  string
+	Language string // BCP 47; defaults to en
+	Modified time.Time
+
+	chapters []*chapter
+	images   []*image
+	srcs     map[string]string // remote URL to local href
+}
+
+type chapter struct {
+	ID, Href string
+	Title    string
+	Byline   string
+	Language string
+	Body     string // XHTML
+}
+
+type image struct {
+	ID, Href  string
+	MediaType string
+	data      []byte
+}
+
+// NewBook creates an empty book.
+func NewBook(title string) *Book {
+	return &Book{Title: title, Language: "en", Modified: time.Now(), srcs: make(map[string]string)}
+}
+
+// AddChapter appends a chapter. body is an HTML fragment; it's converted to
+// XHTML, and images in it must have been embedded with EmbedImages first.
+// language defaults to the book's.
+func (b *Book) AddChapter(title, byline, language, body string) error {
+	xhtml, err := toXHTML(body)
+	if err != nil {
+		return err
+	}
+	if language == "" {
+		language = b.Language
+	}
+	n := len(b.chapters) + 1
+	b.chapters = append(b.chapters, &chapter{
+		ID: fmt.Sprintf("ch%d", n), Href: fmt.Sprintf("chapter-%d.xhtml", n),
+		Title: title, Byline: byline, Language: language, Body: xhtml,
+	})
+	return nil
+}
+
+// AddImage adds image data and returns its href, or false if its type
+// isn't supported.
+func (b *Book) AddImage(data []byte) (string, bool) {
+	mediaType := http.DetectContentType(data)
+	ext, ok := mediaTypes[mediaType]
+	if !ok {
+		return "", false
+	}
+	n := len(b.images) + 1
+	img := &image{ID: fmt.Sprintf("img%d", n), Href: fmt.Sprintf("images/%d%s", n, ext), MediaType: mediaType, data: data}
+	b.images = append(b.images, img)
+	return img.Href, true
+}
+
+// Len returns the number of chapters.
+func (b *Book) Len() int {
+	return len(b.chapters)
+}
+
+// Write writes the book as an EPUB (zip) archive.
+func (b *Book) Write(w io.Writer) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	pkg := struct {
+		Title, Creator, Language string
+		Identifier, Modified     string
+		Chapters                 []*chapter
+		Images                   []*image
+	}{
+		Title: b.Title, Creator: b.Creator, Language: b.Language,
+		Identifier: fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Modified:   b.Modified.UTC().Format(time.RFC3339),
+		Chapters:   b.chapters,
+		Images:     b.images,
+	}
+
+	zw := zip.NewWriter(w)
+	// The mimetype file comes first, uncompressed and without extra fields
+	// or a data descriptor, so readers can sniff it at a fixed offset.
+	mimetype := []byte("application/epub+zip")
+	mw, err := zw.CreateRaw(&zip.FileHeader{
+		Name: "mimetype", Method: zip.Store, CRC32: crc32.ChecksumIEEE(mimetype),
+		CompressedSize64: uint64(len(mimetype)), UncompressedSize64: uint64(len(mimetype)),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(mimetype); err != nil {
+		return err
+	}
+	for path, name := range map[string]string{
+		"META-INF/container.xml": "templates/container.xml",
+		"EPUB/style.css":         "templates/style.css",
+	} {
+		content, err := templates.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := b.writeFile(zw, path, zip.Deflate, content); err != nil {
+			return err
+		}
+	}
+
+	type document struct {
+		path, tmpl string
+		data       any
+	}
+	docs := []document{
+		{"EPUB/package.opf", "package.opf.tmpl", pkg},
+		{"EPUB/nav.xhtml", "nav.xhtml.tmpl", pkg},
+		{"EPUB/toc.ncx", "toc.ncx.tmpl", pkg},
+	}
+	for _, c := range b.chapters {
+		docs = append(docs, document{"EPUB/" + c.Href, "chapter.xhtml.tmpl", c})
+	}
+	for _, d := range docs {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, d.tmpl, d.data); err != nil {
+			return err
+		}
+		if err := b.writeFile(zw, d.path, zip.Deflate, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// Images are compressed already.
+	for _, img := range b.images {
+		if err := b.writeFile(zw, "EPUB/"+img.Href, zip.Store, img.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (b *Book) writeFile(zw *zip.Writer, name string, method uint16, content []byte) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: b.Modified})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(content)
+	return err
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return strings.ReplaceAll(buf.String(), "&#xA;", " ")
+}
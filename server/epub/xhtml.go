@@ -0,0 +1,170 @@
+package epub
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentFetches bounds parallel image downloads per chapter.
+const maxConcurrentFetches = 4
+
+// Fetcher downloads an image.
+type Fetcher func(ctx context.Context, url string) ([]byte, error)
+
+// EmbedImages downloads the images in an HTML fragment with fetch, adds them
+// to the book and points their src at the copies. Images that can't be
+// fetched, or aren't GIF, JPEG, PNG or WebP, are replaced by their alt text.
+// An image used more than once is stored once.
+func (b *Book) EmbedImages(ctx context.Context, body string, fetch Fetcher) (string, error) {
+	root, err := parseFragment(body)
+	if err != nil {
+		return "", err
+	}
+
+	var imgs []*html.Node
+	walk(root, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+			imgs = append(imgs, n)
+		}
+	})
+
+	var mu sync.Mutex
+	fetched := make(map[string][]byte)
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentFetches)
+	seen := make(map[string]bool)
+	for _, img := range imgs {
+		src := attr(img, "src")
+		if _, ok := b.srcs[src]; ok || src == "" || seen[src] {
+			continue
+		}
+		seen[src] = true
+		g.Go(func() error {
+			data, err := fetch(gctx, src)
+			if err != nil {
+				return nil // dropped below
+			}
+			mu.Lock()
+			fetched[src] = data
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// Images are added in document order so numbering is stable.
+	for _, img := range imgs {
+		src := attr(img, "src")
+		if _, ok := b.srcs[src]; !ok {
+			b.srcs[src] = ""
+			if data, ok := fetched[src]; ok {
+				if href, ok := b.AddImage(data); ok {
+					b.srcs[src] = href
+				}
+			}
+		}
+		if href := b.srcs[src]; href != "" {
+			setAttr(img, "src", href)
+			continue
+		}
+		if alt := attr(img, "alt"); alt != "" {
+			img.Parent.InsertBefore(&html.Node{Type: html.TextNode, Data: alt}, img)
+		}
+		img.Parent.RemoveChild(img)
+	}
+	return renderChildren(root)
+}
+
+// toXHTML re-serializes an HTML fragment as well-formed XHTML: void
+// elements are self-closed, attributes quoted, and characters XML forbids
+// removed.
+func toXHTML(body string) (string, error) {
+	root, err := parseFragment(body)
+	if err != nil {
+		return "", err
+	}
+	walk(root, func(n *html.Node) {
+		n.Data = xmlChars(n.Data)
+		for i := range n.Attr {
+			n.Attr[i].Val = xmlChars(n.Attr[i].Val)
+		}
+	})
+	return renderChildren(root)
+}
+
+// parseFragment parses an HTML fragment into the children of a detached
+// root, so that every node has a parent.
+func parseFragment(body string) (*html.Node, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(body), context)
+	if err != nil {
+		return nil, err
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
+func renderChildren(root *html.Node) (string, error) {
+	var out strings.Builder
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&out, c); err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+// walk calls fn for n and its descendants. fn may remove the node it's
+// given, but not others.
+func walk(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		walk(c, fn)
+		c = next
+	}
+	fn(n)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// xmlChars drops characters that aren't allowed in XML 1.0 documents.
+func xmlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t', r == '\n', r == '\r',
+			r >= 0x20 && r <= 0xd7ff,
+			r >= 0xe000 && r <= 0xfffd,
+			r >= 0x10000 && r <= 0x10ffff:
+			return r
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,267 @@
+// Package feed encodes story lists as Atom, RSS 2.0 and JSON Feed documents
+// for feed readers.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"time"
+)
+
+// Feed formats, named by their file extension.
+const (
+	Atom = "atom"
+	RSS  = "rss"
+	JSON = "json"
+)
+
+// ErrUnknownFormat is returned by Marshal for unsupported formats.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Feed is a format-independent feed.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // the page the feed mirrors
+	Self        string // the feed's own URL, which also identifies it
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is a feed entry. Content is HTML.
+type Item struct {
+	ID          string
+	Title       string
+	URL         string
+	CommentsURL string
+	Author      string
+	Published   time.Time
+	Content     string
+}
+
+// ContentType returns the media type of a format, or "" if it's unknown.
+func ContentType(format string) string {
+	switch format {
+	case Atom:
+		return "application/atom+xml; charset=utf-8"
+	case RSS:
+		return "application/rss+xml; charset=utf-8"
+	case JSON:
+		return "application/feed+json; charset=utf-8"
+	}
+	return ""
+}
+
+// Marshal encodes f in format.
+func Marshal(f *Feed, format string) ([]byte, error) {
+	switch format {
+	case Atom:
+		return marshalXML(atomFeedOf(f))
+	case RSS:
+		return marshalXML(rssOf(f))
+	case JSON:
+		return json.MarshalIndent(jsonFeedOf(f), "", "  ")
+	}
+	return nil, ErrUnknownFormat
+}
+
+func marshalXML(v any) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Atom, RFC 4287.
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Links     []atomLink  `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func atomFeedOf(f *Feed) *atomFeed {
+	out := &atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+	}
+	for _, it := range f.Items {
+		published := it.Published.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			Title:     it.Title,
+			ID:        it.ID,
+			Links:     []atomLink{{Rel: "alternate", Href: it.URL}},
+			Published: published,
+			Updated:   published,
+			Content:   atomContent{Type: "html", Body: it.Content},
+		}
+		if it.CommentsURL != "" && it.CommentsURL != it.URL {
+			entry.Links = append(entry.Links, atomLink{Rel: "replies", Type: "text/html", Href: it.CommentsURL})
+		}
+		if it.Author != "" {
+			entry.Author = &atomAuthor{Name: it.Author}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+	return out
+}
+
+// RSS 2.0, with content:encoded and dc:creator since RSS authors must be
+// email addresses.
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssSelf   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title    string  `xml:"title"`
+	Link     string  `xml:"link"`
+	GUID     rssGUID `xml:"guid"`
+	Comments string  `xml:"comments,omitempty"`
+	PubDate  string  `xml:"pubDate"`
+	Creator  string  `xml:"dc:creator,omitempty"`
+	Content  string  `xml:"content:encoded"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func rssOf(f *Feed) *rss {
+	out := &rss{
+		Version: "2.0",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Self:          rssSelf{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+		},
+	}
+	for _, it := range f.Items {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:    it.Title,
+			Link:     it.URL,
+			GUID:     rssGUID{Value: it.ID},
+			Comments: it.CommentsURL,
+			PubDate:  it.Published.UTC().Format(time.RFC1123Z),
+			Creator:  it.Author,
+			Content:  it.Content,
+		})
+	}
+	return out
+}
+
+// JSON Feed 1.1.
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	ExternalURL   string       `json:"external_url,omitempty"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	DatePublished string       `json:"date_published"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+func jsonFeedOf(f *Feed) *jsonFeed {
+	out := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	for _, it := range f.Items {
+		// url is the item's own page; the discussion is the closest thing
+		// to one, with the story's link as external_url.
+		item := jsonItem{
+			ID:            it.ID,
+			URL:           it.URL,
+			Title:         it.Title,
+			ContentHTML:   it.Content,
+			DatePublished: it.Published.UTC().Format(time.RFC3339),
+		}
+		if it.CommentsURL != "" && it.CommentsURL != it.URL {
+			item.URL, item.ExternalURL = it.CommentsURL, it.URL
+		}
+		if it.Author != "" {
+			item.Authors = []jsonAuthor{{Name: it.Author}}
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
@@ -84,6 +85,32 @@ func (c *Client) GetItem(ctx context.Context, id int) (*Item, error) {
 	return &item, nil
 }
 
+// GetUser fetches a user profile by username. It returns nil if the user
+// doesn't exist. Submitted item IDs are newest first.
+func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/user/%s.json", baseURL, url.PathEscape(username)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request for user %s: %w", username, err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch user %s: %w", username, err)
+	}
+	defer resp.Body.Close()
+
+	var user *User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("decode user %s: %w", username, err)
+	}
+	return user, nil
+}
+
 // GetItems fetches multiple items concurrently and returns them in order.
 // Errors for individual items are logged but don't fail the batch.
 func (c *Client) GetItems(ctx context.Context, ids []int) []*Item {
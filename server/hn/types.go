@@ -16,3 +16,12 @@ type Item struct {
 	Dead        bool   `json:"dead"`
 	Deleted     bool   `json:"deleted"`
 }
+
+// User represents a Hacker News user profile.
+type User struct {
+	ID        string `json:"id"`
+	Created   int64  `json:"created"`
+	Karma     int    `json:"karma"`
+	About     string `json:"about"`
+	Submitted []int  `json:"submitted"`
+}
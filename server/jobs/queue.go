@@ -0,0 +1,287 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// Priority orders pending jobs; higher priorities are claimed first.
+type Priority int
+
+const (
+	PriorityRetry Priority = 0   // failed attempts waiting out their backoff
+	PriorityLazy  Priority = 100 // background maintenance
+	PriorityEager Priority = 200 // front-page prefetching
+	PriorityUser  Priority = 300 // a user is waiting on the result
+)
+
+// DefaultWorkers is the number of jobs run concurrently.
+const DefaultWorkers = 4
+
+const (
+	maxAttempts = 3
+	jobTimeout  = 2 * time.Minute
+	retryBase   = 30 * time.Second
+	idleWait    = 30 * time.Second
+)
+
+// Handler runs a single job. A returned error schedules a retry, unless it
+// is wrapped with Permanent or the job has used up its attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Queue is a persistent job queue backed by the jobs table. Jobs are
+// deduplicated by key: enqueueing a key that already has a pending job merges
+// into it, raising its priority if needed and replacing its payload, instead
+// of adding a second one. A key whose job is already running with a different
+// payload gets that payload queued as a follow-up, which runs once the
+// current attempt finishes and supersedes any retry of it.
+type Queue struct {
+	db      *sql.DB
+	q       *store.Queries
+	workers int
+	wake    chan struct{}
+
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	waiters   map[int][]chan error // by job ID
+	followers map[int][]chan error // by job ID, waiting on its follow-up payload
+}
+
+func NewQueue(db *sql.DB, q *store.Queries, workers int) *Queue {
+	return &Queue{
+		db:        db,
+		q:         q,
+		workers:   workers,
+		wake:      make(chan struct{}, workers),
+		handlers:  make(map[string]Handler),
+		waiters:   make(map[int][]chan error),
+		followers: make(map[int][]chan error),
+	}
+}
+
+// Handle registers the handler for jobs of the given kind.
+func (qu *Queue) Handle(kind string, h Handler) {
+	qu.mu.Lock()
+	qu.handlers[kind] = h
+	qu.mu.Unlock()
+}
+
+// Enqueue schedules a job and returns its ID without waiting for it to run.
+func (qu *Queue) Enqueue(ctx context.Context, kind, key string, payload any, priority Priority) (int, error) {
+	return qu.enqueue(ctx, kind, key, payload, priority, nil)
+}
+
+// Run schedules a job and waits for its next attempt to finish, returning
+// that attempt's error. Callers waiting on the same key and payload share
+// one run.
+func (qu *Queue) Run(ctx context.Context, kind, key string, payload any, priority Priority) error {
+	done := make(chan error, 1)
+	if _, err := qu.enqueue(ctx, kind, key, payload, priority, done); err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (qu *Queue) enqueue(ctx context.Context, kind, key string, payload any, priority Priority, done chan error) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("encode %s payload: %w", kind, err)
+	}
+
+	now := time.Now().Unix()
+	// Finishing workers update the job and notify its waiters under the same
+	// lock, so the row's status here tells which attempt will use this payload.
+	qu.mu.Lock()
+	row, err := qu.q.EnqueueJob(ctx, qu.db, store.EnqueueJobParams{
+		Kind: kind, Key: key, Payload: string(body),
+		Priority: int(priority), MaxAttempts: maxAttempts,
+		RunAt: now, CreatedAt: now, UpdatedAt: now,
+	})
+	if err == nil && done != nil {
+		if row.Status == "running" && row.NextPayload != nil && *row.NextPayload == string(body) {
+			qu.followers[row.ID] = append(qu.followers[row.ID], done)
+		} else {
+			qu.waiters[row.ID] = append(qu.waiters[row.ID], done)
+		}
+	}
+	qu.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("enqueue %s: %w", key, err)
+	}
+	id := row.ID
+
+	select {
+	case qu.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Start requeues jobs left running by a previous process and starts the
+// worker pool. Workers run until the context is cancelled.
+func (qu *Queue) Start(ctx context.Context) {
+	if n, err := qu.q.ResetRunningJobs(ctx, qu.db, time.Now().Unix()); err != nil {
+		slog.Error("jobs: error resetting interrupted jobs", "error", err)
+	} else if n > 0 {
+		slog.Info("jobs: requeued interrupted jobs", "count", n)
+	}
+
+	for i := 0; i < qu.workers; i++ {
+		go qu.work(ctx)
+	}
+}
+
+func (qu *Queue) work(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		job, err := store.Nullable(qu.q.ClaimJob(ctx, qu.db, time.Now().Unix()))
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("jobs: error claiming job", "error", err)
+			}
+			qu.wait(ctx, idleWait)
+			continue
+		}
+		if job == nil {
+			qu.wait(ctx, qu.untilNext(ctx))
+			continue
+		}
+		qu.run(ctx, job)
+	}
+}
+
+// untilNext returns how long to sleep before the next pending job is due.
+func (qu *Queue) untilNext(ctx context.Context) time.Duration {
+	next, err := qu.q.NextJobRunAt(ctx, qu.db)
+	if err != nil || next == 0 {
+		return idleWait
+	}
+	return min(idleWait, max(time.Until(time.Unix(int64(next), 0)), time.Second))
+}
+
+func (qu *Queue) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-qu.wake:
+	case <-timer.C:
+	}
+}
+
+func (qu *Queue) run(ctx context.Context, job *store.Job) {
+	qu.mu.Lock()
+	h := qu.handlers[job.Kind]
+	qu.mu.Unlock()
+
+	var err error
+	if h == nil {
+		err = Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	} else {
+		jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+		err = h(jobCtx, json.RawMessage(job.Payload))
+		cancel()
+	}
+
+	// Bookkeeping must land even if the worker context was just cancelled.
+	bg := context.WithoutCancel(ctx)
+	now := time.Now()
+	qu.mu.Lock()
+	defer qu.mu.Unlock()
+
+	// A follow-up payload enqueued during this attempt replaces the job's
+	// payload and runs next, whatever this attempt's outcome.
+	promoted, dbErr := qu.q.PromoteNextPayload(bg, qu.db, store.PromoteNextPayloadParams{
+		RunAt: now.Unix(), UpdatedAt: now.Unix(), ID: job.ID,
+	})
+	if dbErr != nil {
+		slog.Error("jobs: error promoting follow-up payload", "job_id", job.ID, "error", dbErr)
+	}
+
+	var permanent *permanentError
+	switch {
+	case promoted > 0:
+		if err != nil {
+			slog.Warn("jobs: job failed before its follow-up", "job_id", job.ID, "key", job.Key, "error", err)
+		}
+		select {
+		case qu.wake <- struct{}{}:
+		default:
+		}
+	case err == nil:
+		err = qu.q.CompleteJob(bg, qu.db, store.CompleteJobParams{UpdatedAt: now.Unix(), ID: job.ID})
+		if err != nil {
+			slog.Error("jobs: error completing job", "job_id", job.ID, "error", err)
+			err = nil
+		}
+	case ctx.Err() != nil:
+		// Shutting down: leave the job for the next process, handing back
+		// the attempt ClaimJob charged it.
+		msg := err.Error()
+		if dbErr := qu.q.RequeueJob(bg, qu.db, store.RequeueJobParams{
+			LastError: &msg, UpdatedAt: now.Unix(), ID: job.ID,
+		}); dbErr != nil {
+			slog.Error("jobs: error requeueing job", "job_id", job.ID, "error", dbErr)
+		}
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		slog.Warn("jobs: job failed", "job_id", job.ID, "key", job.Key, "attempts", job.Attempts, "error", err)
+		msg := err.Error()
+		if dbErr := qu.q.FailJob(bg, qu.db, store.FailJobParams{LastError: &msg, UpdatedAt: now.Unix(), ID: job.ID}); dbErr != nil {
+			slog.Error("jobs: error failing job", "job_id", job.ID, "error", dbErr)
+		}
+	default:
+		backoff := retryBase << (2 * (job.Attempts - 1))
+		slog.Info("jobs: job will retry", "job_id", job.ID, "key", job.Key, "attempts", job.Attempts, "backoff", backoff, "error", err)
+		msg := err.Error()
+		if dbErr := qu.q.RetryJob(bg, qu.db, store.RetryJobParams{
+			Priority: int(PriorityRetry), RunAt: now.Add(backoff).Unix(), LastError: &msg,
+			UpdatedAt: now.Unix(), ID: job.ID,
+		}); dbErr != nil {
+			slog.Error("jobs: error scheduling retry", "job_id", job.ID, "error", dbErr)
+		}
+	}
+
+	waiters := qu.waiters[job.ID]
+	delete(qu.waiters, job.ID)
+	if followers := qu.followers[job.ID]; promoted > 0 {
+		if len(followers) > 0 {
+			qu.waiters[job.ID] = followers
+		}
+	} else {
+		// Without a follow-up run, give its waiters this attempt's result
+		// rather than leave them waiting.
+		waiters = append(waiters, followers...)
+	}
+	delete(qu.followers, job.ID)
+	for _, ch := range waiters {
+		ch <- err
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	_ "time/tzdata"
@@ -17,9 +18,14 @@ import (
 	"github.com/peterbourgon/ff/v3"
 
 	"github.com/danielmmetz/hn-client/server/api"
+	"github.com/danielmmetz/hn-client/server/digest"
 	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/readability"
+	"github.com/danielmmetz/hn-client/server/safehttp"
 	"github.com/danielmmetz/hn-client/server/sse"
 	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/webpush"
 	"github.com/danielmmetz/hn-client/server/worker"
 )
 
@@ -39,7 +45,21 @@ func main() {
 		oidcClientID     string
 		oidcClientSecret string
 		oidcRedirectURI  string
+		archiveEndpoint  string
+		articleMaxBytes  int64
+		sseRingSize      int
+		retention        time.Duration
+		nightHours       string
+		trendThreshold   float64
+		pushSubject      string
+		publicURL        string
+		smtpAddr         string
+		smtpUsername     string
+		smtpPassword     string
+		smtpFrom         string
+		adminSubs        string
 	)
+	pollerCfg := worker.DefaultPollerConfig()
 	flagSet.StringVar(&addr, "addr", "localhost", "Address to listen on")
 	flagSet.IntVar(&port, "port", 8080, "Port to listen on")
 	flagSet.StringVar(&staticDir, "static-dir", "", "Path to static files directory (default: use embedded files)")
@@ -49,12 +69,41 @@ func main() {
 	flagSet.StringVar(&oidcClientID, "oidc-client-id", "", "OIDC client ID")
 	flagSet.StringVar(&oidcClientSecret, "oidc-client-secret", "", "OIDC client secret")
 	flagSet.StringVar(&oidcRedirectURI, "oidc-redirect-uri", "", "OIDC redirect URI")
+	flagSet.StringVar(&archiveEndpoint, "archive-endpoint", readability.DefaultWaybackEndpoint, "Wayback-style availability API used when an article is dead or blocked (empty disables)")
+	flagSet.Int64Var(&articleMaxBytes, "article-max-bytes", readability.DefaultMaxBodySize, "Maximum decoded size of an article page in bytes")
+	flagSet.IntVar(&sseRingSize, "sse-ring-size", 1000, "Number of recent SSE events kept for reconnecting clients")
+	flagSet.DurationVar(&retention, "retention", worker.DefaultRetention, "How long stories are kept after leaving the top list")
+	flagSet.IntVar(&pollerCfg.EagerCount, "eager-count", pollerCfg.EagerCount, "Number of top stories fetched with comments and articles each poll")
+	flagSet.DurationVar(&pollerCfg.Interval, "poll-interval", pollerCfg.Interval, "Time between polls of HN top stories")
+	flagSet.BoolVar(&pollerCfg.Adaptive, "adaptive-poll", false, "Poll less often when no clients are connected, and at night")
+	flagSet.DurationVar(&pollerCfg.IdleInterval, "idle-poll-interval", pollerCfg.IdleInterval, "Poll interval with -adaptive-poll while no clients are connected")
+	flagSet.DurationVar(&pollerCfg.NightInterval, "night-poll-interval", pollerCfg.NightInterval, "Poll interval with -adaptive-poll during night hours while no clients are connected")
+	flagSet.Float64Var(&trendThreshold, "trending-threshold", worker.DefaultTrendingThreshold, "Points per hour at which a story is reported as trending")
+	flagSet.StringVar(&pushSubject, "push-subject", "", "Contact URI (mailto: or https:) sent to Web Push services with each message")
+	flagSet.StringVar(&publicURL, "public-url", "", "External URL of this app, used for links in email digests and feeds (default: link to HN)")
+	flagSet.StringVar(&smtpAddr, "smtp-addr", "", "SMTP relay host:port for email digests (empty disables sending)")
+	flagSet.StringVar(&smtpUsername, "smtp-username", "", "SMTP username (empty skips authentication)")
+	flagSet.StringVar(&smtpPassword, "smtp-password", "", "SMTP password")
+	flagSet.StringVar(&smtpFrom, "smtp-from", "", "From address for email digests")
+	flagSet.StringVar(&adminSubs, "admin-subs", "", "Comma-separated user subjects allowed to use /api/admin routes (\"anonymous\" when auth is disabled; empty disables them)")
+	flagSet.StringVar(&nightHours, "night-hours", fmt.Sprintf("%d-%d", pollerCfg.NightStart, pollerCfg.NightEnd), "Local hours START-END treated as night by -adaptive-poll (empty disables)")
 
 	if err := ff.Parse(flagSet, os.Args[1:], ff.WithEnvVars()); err != nil {
 		slog.Error("failed to parse flags", "error", err)
 		os.Exit(1)
 	}
 
+	nightStart, nightEnd, err := worker.ParseHourRange(nightHours)
+	if err != nil {
+		slog.Error("invalid -night-hours", "error", err)
+		os.Exit(1)
+	}
+	pollerCfg.NightStart, pollerCfg.NightEnd = nightStart, nightEnd
+	if pollerCfg.Interval <= 0 || pollerCfg.EagerCount < 0 || articleMaxBytes <= 0 || sseRingSize <= 0 || retention <= 0 || trendThreshold <= 0 {
+		slog.Error("-poll-interval, -article-max-bytes, -sse-ring-size, -retention and -trending-threshold must be positive, and -eager-count non-negative")
+		os.Exit(1)
+	}
+
 	// Database
 	db, err := store.Open(dbPath)
 	if err != nil {
@@ -96,31 +145,94 @@ func main() {
 	hnClient := hn.NewClient()
 
 	// SSE broker
-	broker := sse.NewBroker(1000)
+	broker := sse.NewBroker(sseRingSize)
+	broker.SetUserFunc(func(r *http.Request) string { return api.UserSub(r.Context()) })
 
 	// Shared TopList for pagination
 	topList := store.NewTopList()
 
+	// Article extractor, with archive fallback for dead or blocked links
+	var archive readability.ArchiveProvider
+	if archiveEndpoint != "" {
+		archive = readability.NewWaybackProvider(archiveEndpoint)
+	}
+	extractor := readability.NewExtractor(safehttp.NewClient(readability.FetchTimeout), archive, articleMaxBytes)
+
+	// Persistent job queue for fetch and extraction work
+	queue := jobs.NewQueue(db, q, jobs.DefaultWorkers)
+
+	// Web Push, signed with keys generated on first start
+	vapidKeys, err := worker.LoadVAPIDKeys(context.Background(), db, q)
+	if err != nil {
+		slog.Error("failed to load VAPID keys", "error", err)
+		os.Exit(1)
+	}
+	pushSender := webpush.NewSender(safehttp.NewClient(worker.PushTimeout), vapidKeys, pushSubject)
+	pusher := worker.NewPusher(pushSender, queue, db, q)
+
 	// Fetcher
-	fetcher := worker.NewFetcher(hnClient, db, q)
+	fetcher := worker.NewFetcher(hnClient, extractor, db, q, queue)
+
+	// Alerts for new stories and comments matching users' watches
+	watcher := worker.NewWatcher(db, q, broker, pusher)
+	fetcher.AddObserver(watcher)
 
 	// Background worker context
 	workerCtx, workerCancel := context.WithCancel(context.Background())
 
 	// Background poller
-	poller := worker.NewPoller(hnClient, fetcher, db, q, broker, topList)
+	ranker := worker.NewRanker(db, q, worker.DefaultStrategies())
+	trending := worker.NewTrending(broker, pusher, trendThreshold)
+	poller := worker.NewPoller(hnClient, fetcher, ranker, trending, db, q, broker, topList, pollerCfg)
 	poller.Start(workerCtx)
 
+	// Email digests of the day's or week's top stories
+	var mailer *digest.Mailer
+	if smtpAddr != "" {
+		if mailer, err = digest.NewMailer(smtpAddr, smtpUsername, smtpPassword, smtpFrom); err != nil {
+			slog.Error("invalid SMTP configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	digester := worker.NewDigester(ranker, mailer, publicURL, queue, db, q)
+	digester.Start(workerCtx)
+
 	// Daily cleanup
-	cleaner := worker.NewCleaner(db, q)
+	cleaner := worker.NewCleaner(db, q, retention)
 	cleaner.Start(workerCtx)
 
+	// Periodic re-extraction of front-page articles to catch edits
+	reextractor := worker.NewReextractor(fetcher, queue, db, q, broker)
+	reextractor.Start(workerCtx)
+
+	// Tiered re-checks of comments on stories outside the eager set
+	commentRefresher := worker.NewCommentRefresher(fetcher, queue, db, q, broker, pollerCfg.EagerCount)
+	commentRefresher.Start(workerCtx)
+
+	// Replies to linked HN accounts' recent comments
+	replyTracker := worker.NewReplyTracker(hnClient, queue, db, q, broker, pusher)
+	replyTracker.Start(workerCtx)
+
 	// API handlers
-	storiesHandler := api.NewStoriesHandler(db, q, topList, fetcher)
+	storiesHandler := api.NewStoriesHandler(db, q, topList, fetcher, ranker)
 	commentsHandler := api.NewCommentsHandler(db, q, fetcher, hnClient)
 	articlesHandler := api.NewArticlesHandler(db, q, fetcher)
-	refreshHandler := api.NewRefreshHandler(fetcher, hnClient, db, q, broker)
+	refreshHandler := api.NewRefreshHandler(fetcher, queue, hnClient, db, q, broker)
 	healthHandler := api.NewHealthHandler(db, q)
+	jobsHandler := api.NewJobsHandler(db, q)
+	trendingHandler := api.NewTrendingHandler(db, q, trending)
+	domainsHandler := api.NewDomainsHandler(db, q)
+	filtersHandler := api.NewFiltersHandler(db, q)
+	hiddenHandler := api.NewHiddenHandler(db, q, fetcher)
+	watchesHandler := api.NewWatchesHandler(db, q, watcher)
+	inboxHandler := api.NewInboxHandler(db, q, hnClient, replyTracker)
+	pushHandler := api.NewPushHandler(db, q, vapidKeys)
+	digestHandler := api.NewDigestHandler(db, q, digester)
+	feedsHandler := api.NewFeedsHandler(db, q, topList, ranker, publicURL)
+	exportHandler := api.NewExportHandler(db, q, ranker)
+
+	// Start workers once every job handler is registered
+	queue.Start(workerCtx)
 	// Auth helper — wraps handlers in auth check when enabled, otherwise passes through
 	var requireAuth func(http.HandlerFunc) http.Handler
 	var requireAuthHandler func(http.Handler) http.Handler
@@ -159,15 +271,64 @@ func main() {
 
 	// API routes
 	mux.Handle("GET /api/stories/top", requireAuth(storiesHandler.TopStories))
+	mux.Handle("GET /api/stories/trending", requireAuth(trendingHandler.ListTrending))
 	mux.Handle("GET /api/stories/{id}/article", requireAuth(articlesHandler.GetArticle))
+	mux.Handle("GET /api/stories/{id}/article/versions", requireAuth(articlesHandler.ListArticleVersions))
+	mux.Handle("GET /api/stories/{id}/article/versions/{version}", requireAuth(articlesHandler.GetArticleVersion))
+	mux.Handle("GET /api/stories/{id}/article/diff", requireAuth(articlesHandler.GetArticleDiff))
 	mux.Handle("GET /api/stories/{id}/comments", requireAuth(commentsHandler.GetComments))
+	mux.Handle("GET /api/stories/{id}/comments/revisions", requireAuth(commentsHandler.GetRevisions))
+	mux.Handle("GET /api/comments/{id}", requireAuth(commentsHandler.GetComment))
+	mux.Handle("POST /api/stories/{id}/hide", requireAuth(hiddenHandler.Hide))
+	mux.Handle("POST /api/stories/{id}/unhide", requireAuth(hiddenHandler.Unhide))
+	mux.Handle("GET /api/stories/{id}/related", requireAuth(storiesHandler.Related))
 	mux.Handle("GET /api/stories/{id}/refresh", requireAuth(refreshHandler.Refresh))
 	mux.Handle("POST /api/stories/{id}/refresh", requireAuth(refreshHandler.Refresh))
 	mux.Handle("GET /api/stories/{id}", requireAuth(storiesHandler.GetStory))
 	mux.Handle("GET /api/stories", requireAuth(storiesHandler.ListStories))
+	mux.Handle("GET /api/domains", requireAuth(domainsHandler.ListDomains))
+	mux.Handle("GET /api/domains/{domain}/stories", requireAuth(domainsHandler.ListDomainStories))
+	mux.Handle("GET /api/hidden", requireAuth(hiddenHandler.ListHidden))
+	mux.Handle("GET /api/filters", requireAuth(filtersHandler.ListFilters))
+	mux.Handle("POST /api/filters", requireAuth(filtersHandler.CreateFilter))
+	mux.Handle("PUT /api/filters/{id}", requireAuth(filtersHandler.UpdateFilter))
+	mux.Handle("DELETE /api/filters/{id}", requireAuth(filtersHandler.DeleteFilter))
+	mux.Handle("GET /api/watches", requireAuth(watchesHandler.ListWatches))
+	mux.Handle("POST /api/watches", requireAuth(watchesHandler.CreateWatch))
+	mux.Handle("DELETE /api/watches/{id}", requireAuth(watchesHandler.DeleteWatch))
+	mux.Handle("GET /api/alerts", requireAuth(watchesHandler.ListAlerts))
+	mux.Handle("POST /api/alerts/read", requireAuth(watchesHandler.MarkAlertsRead))
+	mux.Handle("GET /api/account/hn", requireAuth(inboxHandler.GetAccount))
+	mux.Handle("PUT /api/account/hn", requireAuth(inboxHandler.LinkAccount))
+	mux.Handle("DELETE /api/account/hn", requireAuth(inboxHandler.UnlinkAccount))
+	mux.Handle("GET /api/inbox", requireAuth(inboxHandler.ListInbox))
+	mux.Handle("POST /api/inbox/read", requireAuth(inboxHandler.MarkInboxRead))
+	mux.Handle("GET /api/push/key", requireAuth(pushHandler.GetKey))
+	mux.Handle("POST /api/push/subscribe", requireAuth(pushHandler.Subscribe))
+	mux.Handle("POST /api/push/unsubscribe", requireAuth(pushHandler.Unsubscribe))
+	mux.Handle("GET /api/digest", requireAuth(digestHandler.GetSettings))
+	mux.Handle("PUT /api/digest", requireAuth(digestHandler.UpdateSettings))
+	mux.Handle("DELETE /api/digest", requireAuth(digestHandler.DeleteSettings))
+	mux.Handle("GET /api/digest/preview", requireAuth(digestHandler.Preview))
+	mux.Handle("GET /api/feeds/token", requireAuth(feedsHandler.GetToken))
+	mux.Handle("POST /api/feeds/token", requireAuth(feedsHandler.CreateToken))
+	mux.Handle("DELETE /api/feeds/token", requireAuth(feedsHandler.DeleteToken))
+	mux.Handle("GET /api/export/epub", requireAuth(exportHandler.EPUB))
+	// Admin routes expose every user's job payloads, so they're only
+	// registered for an explicit list of operators.
+	if admins := parseAdminSubs(adminSubs); len(admins) > 0 {
+		mux.Handle("GET /api/admin/jobs", requireAuthHandler(api.RequireAdmin(admins, http.HandlerFunc(jobsHandler.ListJobs))))
+	}
 	mux.Handle("GET /api/health", requireAuthHandler(healthHandler))
 	mux.Handle("GET /api/events", requireAuthHandler(broker))
 
+	// Feeds authenticate by token so feed readers can subscribe
+	if requireAuthFlag {
+		mux.Handle("GET /feeds/{name}", api.RequireFeedToken(db, q, feedsHandler.GetFeed))
+	} else {
+		mux.HandleFunc("GET /feeds/{name}", feedsHandler.GetFeed)
+	}
+
 	// Static file serving
 	var staticFS fs.FS
 	if staticDir != "" {
@@ -216,3 +377,14 @@ func main() {
 
 	slog.Info("server stopped")
 }
+
+// parseAdminSubs parses the -admin-subs flag into a set of user subjects.
+func parseAdminSubs(s string) map[string]bool {
+	admins := make(map[string]bool)
+	for _, sub := range strings.Split(s, ",") {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			admins[sub] = true
+		}
+	}
+	return admins
+}
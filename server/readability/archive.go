@@ -0,0 +1,135 @@
+package readability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/safehttp"
+)
+
+// DefaultWaybackEndpoint is the Wayback Machine availability API.
+const DefaultWaybackEndpoint = "https://archive.org/wayback/available"
+
+const archiveLookupTimeout = 10 * time.Second
+
+// ErrNoSnapshot is returned when the archive has no usable copy of a page.
+var ErrNoSnapshot = errors.New("no archived snapshot")
+
+// ArchiveProvider locates archived copies of pages.
+type ArchiveProvider interface {
+	// Snapshot returns the URL of an archived copy of rawURL, or ErrNoSnapshot.
+	Snapshot(ctx context.Context, rawURL string) (string, error)
+}
+
+// WaybackProvider looks up snapshots using the Wayback Machine availability
+// API format:
+//
+//	GET {endpoint}?url={page}
+//	{"archived_snapshots": {"closest": {"available": true, "status": "200", "url": "..."}}}
+type WaybackProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewWaybackProvider creates a provider that queries endpoint. The endpoint
+// is operator configuration rather than user input, so lookups use a plain
+// client; the snapshots themselves are still fetched through the guarded one.
+func NewWaybackProvider(endpoint string) *WaybackProvider {
+	return &WaybackProvider{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: archiveLookupTimeout},
+	}
+}
+
+type waybackResponse struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			Status    string `json:"status"`
+			URL       string `json:"url"`
+			Timestamp string `json:"timestamp"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// Snapshot implements ArchiveProvider.
+func (p *WaybackProvider) Snapshot(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(p.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse archive endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("url", rawURL)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("create archive request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("archive lookup: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("archive lookup returned status %d", resp.StatusCode)
+	}
+
+	var body waybackResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode archive response: %w", err)
+	}
+
+	closest := body.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.URL == "" {
+		return "", ErrNoSnapshot
+	}
+	if closest.Status != "" && closest.Status != "200" {
+		return "", fmt.Errorf("%w: closest snapshot has status %s", ErrNoSnapshot, closest.Status)
+	}
+	return rawSnapshotURL(closest.URL), nil
+}
+
+var waybackTimestamp = regexp.MustCompile(`/web/(\d{1,14})/`)
+
+// rawSnapshotURL rewrites a Wayback snapshot URL to its "id_" form, which
+// serves the original page without the archive toolbar or rewritten links.
+func rawSnapshotURL(snapshot string) string {
+	return waybackTimestamp.ReplaceAllString(snapshot, "/web/${1}id_/")
+}
+
+// shouldFallback reports whether a failed fetch looks like a dead or blocked
+// page that an archived copy could stand in for.
+func shouldFallback(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized, code == http.StatusForbidden,
+			code == http.StatusNotFound, code == http.StatusGone,
+			code == http.StatusUnavailableForLegalReasons, code == http.StatusTooManyRequests:
+			return true
+		default:
+			return code >= 500
+		}
+	}
+	if errors.Is(err, safehttp.ErrBlockedAddress) || errors.Is(err, safehttp.ErrBlockedScheme) {
+		return false
+	}
+	if errors.Is(err, safehttp.ErrTooManyRedirects) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr)
+}
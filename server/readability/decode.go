@@ -0,0 +1,149 @@
+package readability
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gogs/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+const (
+	acceptHeader         = "text/html,application/xhtml+xml;q=0.9,text/plain;q=0.8"
+	acceptEncodingHeader = "gzip, deflate, br"
+
+	// minDetectConfidence is the chardet confidence (0-100) required before a
+	// statistically detected charset is trusted over the windows-1252 default.
+	minDetectConfidence = 50
+)
+
+// ErrUnsupportedContentType is returned for responses that are not HTML or plain text.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+type contentKind int
+
+const (
+	contentHTML contentKind = iota
+	contentText
+)
+
+// readBody reads and decompresses the response body. Setting Accept-Encoding on
+// the request disables the transport's transparent gzip handling, so every
+// encoding we advertise is handled here. The size limit applies to the decoded
+// bytes, which also bounds decompression bombs.
+func readBody(resp *http.Response, limit int64) ([]byte, error) {
+	var r io.Reader = resp.Body
+	switch enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case "deflate":
+		// "deflate" is specified as zlib-wrapped, but some servers send raw DEFLATE.
+		br := bufio.NewReader(resp.Body)
+		if hdr, err := br.Peek(2); err == nil && isZlibHeader(hdr) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("zlib reader: %w", err)
+			}
+			defer zr.Close()
+			r = zr
+		} else {
+			fr := flate.NewReader(br)
+			defer fr.Close()
+			r = fr
+		}
+	case "br":
+		r = brotli.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", enc)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return body, nil
+}
+
+// classifyContent determines whether a response is HTML or plain text, falling
+// back to content sniffing when the server omits or garbles Content-Type.
+// The returned media type string retains any charset parameter.
+func classifyContent(header string, body []byte) (contentKind, string, error) {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		header = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(header)
+	}
+
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return contentHTML, header, nil
+	case "text/plain":
+		return contentText, header, nil
+	default:
+		return 0, "", fmt.Errorf("%w: %s", ErrUnsupportedContentType, mediaType)
+	}
+}
+
+// toUTF8 transcodes body to UTF-8. Declared encodings (BOM, Content-Type
+// charset, <meta charset>) take precedence; otherwise valid UTF-8 is kept as-is
+// and anything else goes through statistical detection.
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	enc := detectEncoding(body, contentType)
+	if enc == encoding.Nop {
+		return body, nil
+	}
+	out, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("decode charset: %w", err)
+	}
+	return out, nil
+}
+
+func detectEncoding(body []byte, contentType string) encoding.Encoding {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	// DetermineEncoding only looks at the first 1024 bytes and reports
+	// windows-1252 or utf-8 as an uncertain guess when nothing was declared.
+	if certain || (name != "windows-1252" && name != "utf-8") {
+		return enc
+	}
+	if utf8.Valid(body) {
+		return encoding.Nop
+	}
+	if res, err := chardet.NewHtmlDetector().DetectBest(body); err == nil && res.Confidence >= minDetectConfidence {
+		if detected, _ := charset.Lookup(res.Charset); detected != nil {
+			return detected
+		}
+	}
+	if name == "utf-8" {
+		// The prefix looked like UTF-8 but the full body isn't; fall back to the
+		// web's default legacy encoding rather than emitting replacement runes.
+		detected, _ := charset.Lookup("windows-1252")
+		return detected
+	}
+	return enc
+}
+
+// isZlibHeader reports whether hdr is a valid RFC 1950 header using DEFLATE.
+func isZlibHeader(hdr []byte) bool {
+	return hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0
+}
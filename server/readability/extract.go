@@ -4,36 +4,26 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	goreadability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+
+	"github.com/danielmmetz/hn-client/server/safehttp"
+	"github.com/danielmmetz/hn-client/server/sanitize"
 )
 
 const (
-	fetchTimeout = 30 * time.Second
-	maxBodySize  = 1 << 20 // 1 MiB
-	userAgent    = "HNReader/1.0"
+	// DefaultMaxBodySize is the default cap on a decoded article response.
+	DefaultMaxBodySize = 1 << 20 // 1 MiB
+	// FetchTimeout bounds each page fetch, including the archive fallback.
+	FetchTimeout = 30 * time.Second
 )
 
-// httpClient is a dedicated client for article fetching with transport-level controls.
-var httpClient = &http.Client{
-	Timeout: fetchTimeout,
-	Transport: &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 15 * time.Second,
-		MaxIdleConns:          20,
-		MaxIdleConnsPerHost:   5,
-		IdleConnTimeout:       90 * time.Second,
-	},
-}
+const userAgent = "HNReader/1.0"
 
 // Article holds extracted reader-mode content.
 type Article struct {
@@ -41,45 +31,146 @@ type Article struct {
 	Byline  string
 	Content string // cleaned HTML
 	Excerpt string
+
+	WordCount    int
+	ReadingTime  int    // estimated minutes
+	Language     string // ISO 639 code, declared or detected; empty if unknown
+	LeadImageURL string
+	SiteName     string
+	PublishedAt  *time.Time
+
+	Source     string // SourceOrigin or SourceArchive
+	ArchiveURL string // snapshot the content came from, when Source is SourceArchive
 }
 
-// Extract fetches a URL and extracts reader-mode content.
-// The provided context is used as a parent; a 30-second timeout is applied on top.
-func Extract(ctx context.Context, rawURL string) (*Article, error) {
-	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
-	defer cancel()
+// Article sources.
+const (
+	SourceOrigin  = "origin"
+	SourceArchive = "archive"
+)
+
+// StatusError is returned when a page responds with a non-200 status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetch returned status %d", e.StatusCode)
+}
+
+// Extractor fetches pages and extracts reader-mode content.
+type Extractor struct {
+	client      *http.Client
+	archive     ArchiveProvider
+	maxBodySize int64
+}
+
+// NewExtractor creates an Extractor that fetches pages and snapshots with
+// client and rejects pages larger than maxBodySize bytes once decoded. Story
+// URLs are user-submitted, so client should be a safehttp client outside of
+// tests. If archive is non-nil, pages that are dead or blocked are extracted
+// from an archived snapshot instead.
+func NewExtractor(client *http.Client, archive ArchiveProvider, maxBodySize int64) *Extractor {
+	return &Extractor{
+		client:      client,
+		archive:     archive,
+		maxBodySize: maxBodySize,
+	}
+}
 
+// Extract fetches a URL and extracts reader-mode content, falling back to an
+// archived snapshot when the page is unreachable.
+// The provided context is used as a parent; a 30-second timeout is applied to each attempt.
+func (e *Extractor) Extract(ctx context.Context, rawURL string) (*Article, error) {
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("parse url: %w", err)
 	}
+	if err := safehttp.CheckURL(parsedURL); err != nil {
+		return nil, err
+	}
+
+	article, err := e.extract(ctx, parsedURL, parsedURL)
+	if err == nil {
+		article.Source = SourceOrigin
+		return article, nil
+	}
+	if e.archive == nil || ctx.Err() != nil || !shouldFallback(err) {
+		return nil, err
+	}
+
+	snapshot, archiveErr := e.archive.Snapshot(ctx, rawURL)
+	if archiveErr != nil {
+		return nil, fmt.Errorf("%w (archive fallback: %v)", err, archiveErr)
+	}
+	snapshotURL, archiveErr := url.Parse(snapshot)
+	if archiveErr == nil {
+		archiveErr = safehttp.CheckURL(snapshotURL)
+	}
+	if archiveErr != nil {
+		return nil, fmt.Errorf("%w (archive fallback: invalid snapshot url: %v)", err, archiveErr)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	article, archiveErr = e.extract(ctx, snapshotURL, parsedURL)
+	if archiveErr != nil {
+		return nil, fmt.Errorf("%w (archive fallback: %v)", err, archiveErr)
+	}
+	article.Source = SourceArchive
+	article.ArchiveURL = snapshot
+	return article, nil
+}
+
+// extract fetches fetchURL and extracts it. Relative links resolve against
+// fetchURL; pageURL is the story's own URL, used for the site name fallback.
+func (e *Extractor) extract(ctx context.Context, fetchURL, pageURL *url.URL) (*Article, error) {
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fetchURL.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("Accept-Encoding", acceptEncodingHeader)
 
-	resp, err := httpClient.Do(req)
+	resp, err := e.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("fetch returned status %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := readBody(resp, e.maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, contentType, err := classifyContent(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
 	}
 
-	// Limit response body
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	body, err = toUTF8(body, contentType)
 	if err != nil {
-		return nil, fmt.Errorf("read body: %w", err)
+		return nil, err
+	}
+
+	if kind == contentText {
+		return plainTextArticle(string(body), pageURL), nil
 	}
-	if len(body) > maxBodySize {
-		return nil, fmt.Errorf("response exceeds %d bytes", maxBodySize)
+
+	// Parse the already-transcoded document ourselves: FromReader would re-run
+	// charset detection on the UTF-8 bytes and can misjudge mostly-ASCII pages.
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse html: %w", err)
 	}
 
-	article, err := goreadability.FromReader(bytes.NewReader(body), parsedURL)
+	article, err := goreadability.FromDocument(doc, fetchURL)
 	if err != nil {
 		return nil, fmt.Errorf("readability extract: %w", err)
 	}
@@ -88,10 +179,47 @@ func Extract(ctx context.Context, rawURL string) (*Article, error) {
 		return nil, fmt.Errorf("no content extracted")
 	}
 
+	words, cjkChars := textStats(article.TextContent)
+	language := normalizeLanguage(article.Language)
+	if language == "" {
+		language = detectLanguage(article.TextContent)
+	}
+	siteName := strings.TrimSpace(article.SiteName)
+	if siteName == "" {
+		siteName = strings.TrimPrefix(pageURL.Hostname(), "www.")
+	}
+
 	return &Article{
-		Title:   article.Title,
-		Byline:  article.Byline,
-		Content: article.Content,
-		Excerpt: article.Excerpt,
+		Title:        article.Title,
+		Byline:       article.Byline,
+		Content:      sanitize.HTML(article.Content, fetchURL),
+		Excerpt:      article.Excerpt,
+		WordCount:    words + cjkChars,
+		ReadingTime:  readingMinutes(words, cjkChars),
+		Language:     language,
+		LeadImageURL: absoluteURL(fetchURL, article.Image),
+		SiteName:     siteName,
+		PublishedAt:  article.PublishedTime,
 	}, nil
 }
+
+// plainTextArticle wraps a text/plain document so it renders like extracted HTML.
+func plainTextArticle(text string, pageURL *url.URL) *Article {
+	text = strings.TrimSpace(text)
+	excerpt := text
+	if i := strings.Index(excerpt, "\n\n"); i >= 0 {
+		excerpt = excerpt[:i]
+	}
+	if r := []rune(excerpt); len(r) > 200 {
+		excerpt = string(r[:200]) + "…"
+	}
+	words, cjkChars := textStats(text)
+	return &Article{
+		Content:     "<pre>" + html.EscapeString(text) + "</pre>",
+		Excerpt:     excerpt,
+		WordCount:   words + cjkChars,
+		ReadingTime: readingMinutes(words, cjkChars),
+		Language:    detectLanguage(text),
+		SiteName:    strings.TrimPrefix(pageURL.Hostname(), "www."),
+	}
+}
@@ -0,0 +1,110 @@
+package readability
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const archivedPage = `<!DOCTYPE html>
+<html lang="en"><head><title>Archived Story</title></head>
+<body><article>
+<h1>Archived Story</h1>
+<p>This page survives only in the archive. It has enough prose for readability to treat it as the main content of the document rather than boilerplate.</p>
+<p>A second paragraph adds more words, so the extractor has a clear candidate and does not discard the article as too short to be worth reading.</p>
+<p>A third paragraph, with a <a href="/next">relative link</a>, rounds it out.</p>
+</article></body></html>`
+
+func TestExtractArchiveFallback(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "gone", status)
+			}))
+			defer origin.Close()
+			pageURL := origin.URL + "/story"
+
+			// archive stands in for both the availability API and the
+			// snapshot host.
+			var archive *httptest.Server
+			archive = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/available":
+					if got := r.URL.Query().Get("url"); got != pageURL {
+						t.Errorf("availability lookup for %q, want %q", got, pageURL)
+					}
+					var resp waybackResponse
+					resp.ArchivedSnapshots.Closest = &struct {
+						Available bool   `json:"available"`
+						Status    string `json:"status"`
+						URL       string `json:"url"`
+						Timestamp string `json:"timestamp"`
+					}{Available: true, Status: "200", URL: archive.URL + "/web/20240102030405/" + pageURL}
+					json.NewEncoder(w).Encode(resp)
+				case "/web/20240102030405id_/" + pageURL:
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					w.Write([]byte(archivedPage))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer archive.Close()
+
+			e := NewExtractor(http.DefaultClient, NewWaybackProvider(archive.URL+"/available"), DefaultMaxBodySize)
+			article, err := e.Extract(context.Background(), pageURL)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if article.Source != SourceArchive {
+				t.Errorf("Source = %q, want %q", article.Source, SourceArchive)
+			}
+			if want := archive.URL + "/web/20240102030405id_/" + pageURL; article.ArchiveURL != want {
+				t.Errorf("ArchiveURL = %q, want %q", article.ArchiveURL, want)
+			}
+			if !strings.Contains(article.Content, "survives only in the archive") {
+				t.Errorf("Content missing archived text: %q", article.Content)
+			}
+		})
+	}
+}
+
+func TestExtractNoFallbackOnSuccess(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(archivedPage))
+	}))
+	defer origin.Close()
+
+	e := NewExtractor(http.DefaultClient, archiveFunc(func(context.Context, string) (string, error) {
+		t.Error("archive consulted for a live page")
+		return "", ErrNoSnapshot
+	}), DefaultMaxBodySize)
+	article, err := e.Extract(context.Background(), origin.URL+"/story")
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if article.Source != SourceOrigin || article.ArchiveURL != "" {
+		t.Errorf("Source, ArchiveURL = %q, %q; want %q, empty", article.Source, article.ArchiveURL, SourceOrigin)
+	}
+}
+
+func TestExtractNoSnapshot(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	defer origin.Close()
+
+	e := NewExtractor(http.DefaultClient, archiveFunc(func(context.Context, string) (string, error) {
+		return "", ErrNoSnapshot
+	}), DefaultMaxBodySize)
+	if _, err := e.Extract(context.Background(), origin.URL+"/story"); err == nil {
+		t.Fatal("Extract succeeded without a snapshot")
+	}
+}
+
+type archiveFunc func(ctx context.Context, rawURL string) (string, error)
+
+func (f archiveFunc) Snapshot(ctx context.Context, rawURL string) (string, error) {
+	return f(ctx, rawURL)
+}
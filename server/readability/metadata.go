@@ -0,0 +1,180 @@
+package readability
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const (
+	wordsPerMinute    = 230 // typical adult silent reading speed for alphabetic scripts
+	cjkCharsPerMinute = 500 // CJK text is read per character rather than per word
+)
+
+// textStats counts words in text. Runs of Han, Hiragana and Katakana are
+// counted per character, since those scripts don't separate words with spaces.
+func textStats(text string) (words, cjkChars int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjkChars++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case r == '\'' || r == '’' || r == '-':
+			// Keep contractions and hyphenated compounds as one word.
+		default:
+			inWord = false
+		}
+	}
+	return words, cjkChars
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// readingMinutes estimates reading time, rounding up to at least one minute
+// for any non-empty text.
+func readingMinutes(words, cjkChars int) int {
+	if words == 0 && cjkChars == 0 {
+		return 0
+	}
+	minutes := float64(words)/wordsPerMinute + float64(cjkChars)/cjkCharsPerMinute
+	return max(1, int(math.Ceil(minutes)))
+}
+
+// normalizeLanguage reduces a BCP 47 tag like "en-US" to its primary subtag.
+func normalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// scriptLanguages maps scripts used by essentially one language to that language.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Armenian, "hy"},
+	{unicode.Georgian, "ka"},
+}
+
+// stopwords are high-frequency function words that distinguish common
+// Latin-script languages from each other.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "with", "for", "this", "are"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "ein", "ich", "auf"},
+	"fr": {"le", "les", "et", "est", "des", "une", "pour", "dans", "que", "pas"},
+	"es": {"el", "los", "las", "y", "es", "una", "para", "por", "que", "del"},
+	"it": {"il", "di", "che", "è", "della", "per", "una", "sono", "non", "gli"},
+	"pt": {"o", "os", "e", "é", "uma", "para", "não", "com", "que", "dos"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "voor", "met"},
+}
+
+// stopwordLangs inverts stopwords for per-word lookups.
+var stopwordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// minStopwordHits is the number of stopword matches required before a
+// Latin-script guess is reported; below this the text is too short to tell.
+const minStopwordHits = 5
+
+// detectLanguage guesses the language of text when the page doesn't declare
+// one. It returns "" when there isn't enough signal.
+func detectLanguage(text string) string {
+	var han, kana, letters int
+	scriptCounts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		default:
+			for i, s := range scriptLanguages {
+				if unicode.Is(s.table, r) {
+					scriptCounts[i]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	if kana > 0 && (han+kana)*2 > letters {
+		return "ja"
+	}
+	if han*2 > letters {
+		return "zh"
+	}
+	for i, s := range scriptLanguages {
+		if scriptCounts[i]*2 > letters {
+			return s.lang
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range stopwordLangs[w] {
+			counts[lang]++
+		}
+	}
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	if bestCount < minStopwordHits {
+		return ""
+	}
+	return best
+}
+
+// absoluteURL resolves ref against base, returning "" for unparseable or
+// non-http(s) references.
+func absoluteURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"strings"
+	"time"
+)
+
+// Comment is a comment to render, with its replies.
+type Comment struct {
+	By       string
+	Time     time.Time
+	HTML     string
+	Note     string // shown instead of the text, e.g. "[deleted]"
+	Children []*Comment
+}
+
+// Comments renders a comment tree in format. In Markdown, replies are
+// nested list items; in text, they're indented under their parent.
+func Comments(comments []*Comment, format string, width int) string {
+	var blocks []string
+	for _, c := range comments {
+		blocks = append(blocks, comment(c, format, width))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func comment(c *Comment, format string, width int) string {
+	header := c.By
+	if format == Markdown {
+		header = "**" + escapeInline(c.By) + "**"
+	}
+	if !c.Time.IsZero() {
+		header += " · " + c.Time.UTC().Format("2006-01-02 15:04 UTC")
+	}
+
+	if format == Markdown {
+		parts := []string{header}
+		if c.Note != "" {
+			parts = append(parts, "*"+escapeInline(c.Note)+"*")
+		} else if body := HTML(c.HTML, Markdown, 0); body != "" {
+			parts = append(parts, body)
+		}
+		for _, child := range c.Children {
+			parts = append(parts, comment(child, format, 0))
+		}
+		return prefixLines(strings.Join(parts, "\n\n"), "- ", "  ")
+	}
+
+	self := header
+	if c.Note != "" {
+		self += "\n" + c.Note
+	} else if body := HTML(c.HTML, Text, width); body != "" {
+		self += "\n" + body
+	}
+	parts := []string{self}
+	childWidth := width
+	if width > 0 {
+		childWidth = max(width-2, minWidth)
+	}
+	for _, child := range c.Children {
+		parts = append(parts, prefixLines(comment(child, format, childWidth), "  ", "  "))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,543 @@
+// Package render converts sanitized article and comment HTML into Markdown
+// or wrapped plain text, for notes and terminals.
+package render
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Output formats.
+const (
+	Markdown = "markdown"
+	Text     = "text"
+)
+
+// DefaultWidth is the usual line width of wrapped text.
+const DefaultWidth = 80
+
+// minWidth keeps nested content readable when prefixes eat into the width.
+const minWidth = 20
+
+// lineBreak stands in for <br> until whitespace has been collapsed.
+const lineBreak = "\x00"
+
+// ContentType returns the media type of a format, or "" if it's unknown.
+func ContentType(format string) string {
+	switch format {
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	case Text:
+		return "text/plain; charset=utf-8"
+	}
+	return ""
+}
+
+// HTML converts an HTML fragment to format. Text is wrapped at width
+// columns, or not at all if width is 0; Markdown is never wrapped.
+func HTML(src, format string, width int) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		return src
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	r := &renderer{markdown: format == Markdown, width: width}
+	if r.markdown {
+		r.width = 0
+	}
+	return strings.Join(r.blocks(root), "\n\n")
+}
+
+type renderer struct {
+	markdown bool
+	width    int
+}
+
+// indented renders the block children of n at a narrower width.
+func (r *renderer) indented(n *html.Node, by int) []string {
+	saved := r.width
+	if r.width > 0 {
+		r.width = max(r.width-by, minWidth)
+	}
+	defer func() { r.width = saved }()
+	return r.blocks(n)
+}
+
+// blocks renders the children of n as blocks, which are separated by blank
+// lines. Runs of inline content form paragraphs.
+func (r *renderer) blocks(n *html.Node) []string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if p := r.paragraph(inline.String()); p != "" {
+			blocks = append(blocks, p)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isBlock(c) {
+			r.inline(&inline, c)
+			continue
+		}
+		flush()
+		blocks = append(blocks, r.block(c)...)
+	}
+	flush()
+	return blocks
+}
+
+func (r *renderer) block(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		var b strings.Builder
+		r.inlineChildren(&b, n)
+		text := strings.ReplaceAll(collapse(b.String()), lineBreak, " ")
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		if r.markdown {
+			return []string{strings.Repeat("#", level) + " " + text}
+		}
+		underline, length := "-", utf8.RuneCountInString(text)
+		if level == 1 {
+			underline = "="
+		}
+		if r.width > 0 {
+			length = min(length, r.width)
+		}
+		return []string{text + "\n" + strings.Repeat(underline, length)}
+
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		if code == "" {
+			return nil
+		}
+		if r.markdown {
+			fence := "```"
+			for strings.Contains(code, fence) {
+				fence += "`"
+			}
+			return []string{fence + "\n" + code + "\n" + fence}
+		}
+		return []string{prefixLines(code, "    ", "    ")}
+
+	case atom.Blockquote:
+		inner := strings.Join(r.indented(n, 2), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "> ", "> ")}
+
+	case atom.Ul, atom.Ol:
+		return r.list(n)
+
+	case atom.Hr:
+		if r.markdown {
+			return []string{"---"}
+		}
+		return []string{"* * *"}
+
+	case atom.Table:
+		return r.table(n)
+
+	case atom.Dt:
+		var b strings.Builder
+		r.inlineChildren(&b, n)
+		text := collapse(b.String())
+		if text != "" && r.markdown {
+			text = "**" + text + "**"
+		}
+		if p := r.paragraph(text); p != "" {
+			return []string{p}
+		}
+		return nil
+
+	case atom.Dd:
+		inner := strings.Join(r.indented(n, 4), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "    ", "    ")}
+
+	case atom.Figcaption:
+		var b strings.Builder
+		r.inlineChildren(&b, n)
+		text := collapse(b.String())
+		if text != "" && r.markdown {
+			text = "*" + text + "*"
+		}
+		if p := r.paragraph(text); p != "" {
+			return []string{p}
+		}
+		return nil
+	}
+	// Other containers: div, p, section, figure, li outside a list...
+	return r.blocks(n)
+}
+
+func (r *renderer) list(n *html.Node) []string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if s, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		number = s
+	}
+	var items []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			if v, err := strconv.Atoi(attr(c, "value")); err == nil {
+				number = v
+			}
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		indent := strings.Repeat(" ", len(marker))
+		inner := strings.Join(r.indented(c, len(marker)), "\n\n")
+		items = append(items, prefixLines(inner, marker, indent))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []string{strings.Join(items, "\n")}
+}
+
+func (r *renderer) table(n *html.Node) []string {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom != atom.Tr {
+				walk(c)
+				continue
+			}
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom != atom.Td && cell.DataAtom != atom.Th {
+					continue
+				}
+				var b strings.Builder
+				r.inlineChildren(&b, cell)
+				text := strings.ReplaceAll(collapse(b.String()), lineBreak, " ")
+				if r.markdown {
+					text = strings.ReplaceAll(text, "|", `\|`)
+				}
+				row = append(row, text)
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var lines []string
+	if !r.markdown {
+		for _, row := range rows {
+			lines = append(lines, strings.Join(row, " | "))
+		}
+		return []string{strings.Join(lines, "\n")}
+	}
+	// GFM tables need a header row; the first row serves.
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+		}
+	}
+	return []string{strings.Join(lines, "\n")}
+}
+
+func (r *renderer) inlineChildren(b *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.inline(b, c)
+	}
+}
+
+func (r *renderer) inline(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if r.markdown {
+			text = escapeInline(text)
+		}
+		b.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		b.WriteString(lineBreak)
+	case atom.Strong, atom.B:
+		r.emphasis(b, n, "**")
+	case atom.Em, atom.I, atom.Cite, atom.Dfn, atom.Var:
+		r.emphasis(b, n, "*")
+	case atom.Del, atom.S:
+		r.emphasis(b, n, "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		code := strings.Join(strings.Fields(textContent(n)), " ")
+		if r.markdown && code != "" {
+			fence := "`"
+			for strings.Contains(code, fence) {
+				fence += "`"
+			}
+			if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+				code = " " + code + " "
+			}
+			code = fence + code + fence
+		}
+		b.WriteString(code)
+	case atom.A:
+		var inner strings.Builder
+		r.inlineChildren(&inner, n)
+		text := collapse(inner.String())
+		href := attr(n, "href")
+		switch {
+		case href == "":
+			b.WriteString(inner.String())
+		case r.markdown && (text == "" || text == escapeInline(href)):
+			b.WriteString("<" + href + ">")
+		case r.markdown:
+			b.WriteString("[" + text + "](" + escapeURL(href) + ")")
+		case text == "" || text == href || strings.HasPrefix(href, "mailto:"):
+			b.WriteString(cmp.Or(text, href))
+		default:
+			b.WriteString(text + " (" + href + ")")
+		}
+	case atom.Img:
+		alt := strings.Join(strings.Fields(attr(n, "alt")), " ")
+		switch {
+		case r.markdown && attr(n, "src") != "":
+			b.WriteString("![" + escapeInline(alt) + "](" + escapeURL(attr(n, "src")) + ")")
+		case alt != "":
+			b.WriteString("[image: " + alt + "]")
+		default:
+			b.WriteString("[image]")
+		}
+	case atom.Q:
+		b.WriteString("“")
+		r.inlineChildren(b, n)
+		b.WriteString("”")
+	default:
+		if isBlock(n) {
+			b.WriteByte(' ')
+			r.inlineChildren(b, n)
+			b.WriteByte(' ')
+			return
+		}
+		r.inlineChildren(b, n)
+	}
+}
+
+// emphasis wraps n's content in marker, in Markdown, keeping surrounding
+// spaces outside the markers as Markdown requires.
+func (r *renderer) emphasis(b *strings.Builder, n *html.Node, marker string) {
+	var inner strings.Builder
+	r.inlineChildren(&inner, n)
+	s := inner.String()
+	text := strings.TrimSpace(s)
+	if !r.markdown || text == "" {
+		b.WriteString(s)
+		return
+	}
+	if strings.TrimLeftFunc(s, isSpace) != s {
+		b.WriteByte(' ')
+	}
+	b.WriteString(marker + text + marker)
+	if strings.TrimRightFunc(s, isSpace) != s {
+		b.WriteByte(' ')
+	}
+}
+
+// paragraph collapses inline content into a paragraph, breaking lines at
+// <br> and, for text, wrapping them.
+func (r *renderer) paragraph(inline string) string {
+	text := collapse(inline)
+	if strings.ReplaceAll(text, lineBreak, "") == "" {
+		return ""
+	}
+	var lines []string
+	for _, line := range strings.Split(text, lineBreak) {
+		line = strings.TrimSpace(line)
+		if r.markdown {
+			lines = append(lines, escapeLineStart(line))
+			continue
+		}
+		lines = append(lines, wrap(line, r.width)...)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	sep := "\n"
+	if r.markdown {
+		// A backslash at the end of a line is a hard break in CommonMark.
+		sep = "\\\n"
+	}
+	return strings.Join(lines, sep)
+}
+
+// wrap breaks a line into lines of at most width runes at spaces. Words
+// longer than width, such as URLs, get lines of their own.
+func wrap(line string, width int) []string {
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+	var lines []string
+	var cur strings.Builder
+	curLen := 0
+	for _, word := range strings.Fields(line) {
+		n := utf8.RuneCountInString(word)
+		if curLen > 0 && curLen+1+n > width {
+			lines = append(lines, cur.String())
+			cur.Reset()
+			curLen = 0
+		}
+		if curLen > 0 {
+			cur.WriteByte(' ')
+			curLen++
+		}
+		cur.WriteString(word)
+		curLen += n
+	}
+	if curLen > 0 {
+		lines = append(lines, cur.String())
+	}
+	return lines
+}
+
+// collapse turns runs of whitespace into single spaces and trims the ends,
+// including around line breaks.
+func collapse(s string) string {
+	var b strings.Builder
+	space, last := false, rune(0)
+	for _, r := range s {
+		switch {
+		case isSpace(r):
+			space = true
+			continue
+		case space && b.Len() > 0 && string(r) != lineBreak && string(last) != lineBreak:
+			b.WriteByte(' ')
+		}
+		space, last = false, r
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+var inlineEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`,
+)
+
+func escapeInline(s string) string {
+	return inlineEscaper.Replace(s)
+}
+
+// escapeLineStart escapes characters that would start a block at the
+// beginning of a Markdown line: headings, quotes, list items, rules.
+func escapeLineStart(line string) string {
+	if line == "" {
+		return line
+	}
+	switch line[0] {
+	case '#', '>', '-', '+', '=', '|', '~':
+		return `\` + line
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	if digits > 0 && digits < len(line) && (line[digits] == '.' || line[digits] == ')') {
+		return line[:digits] + `\` + line[digits:]
+	}
+	return line
+}
+
+func escapeURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+// prefixLines prefixes the first line of s with first and the rest with
+// rest. Blank lines get the prefix without trailing spaces.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// blockElements start a new block when they appear among inline content.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Details: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Ul: true,
+}
+
+func isBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && blockElements[n.DataAtom]
+}
@@ -0,0 +1,140 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewClientBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached loopback listener")
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(5 * time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get(%s) error = %v, want ErrBlockedAddress", srv.URL, err)
+	}
+}
+
+// allowOnly returns a dial policy that treats srv as the only public host.
+func allowOnly(t *testing.T, srv *httptest.Server) func(netip.AddrPort) bool {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := netip.MustParseAddrPort(u.Host)
+	return func(addr netip.AddrPort) bool { return addr == public }
+}
+
+func TestRedirectToPrivateRefused(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect reached private listener")
+	}))
+	defer private.Close()
+	public := httptest.NewServer(http.RedirectHandler(private.URL+"/latest/meta-data", http.StatusFound))
+	defer public.Close()
+
+	_, err := newClient(5*time.Second, allowOnly(t, public)).Get(public.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get error = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	// /hops/n redirects n more times before answering.
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := len(strings.TrimPrefix(r.URL.Path, "/"))
+		if n == 0 {
+			w.Write([]byte("ok"))
+			return
+		}
+		http.Redirect(w, r, srv.URL+"/"+strings.Repeat("x", n-1), http.StatusFound)
+	}))
+	defer srv.Close()
+	client := newClient(5*time.Second, allowOnly(t, srv))
+
+	resp, err := client.Get(srv.URL + "/" + strings.Repeat("x", MaxRedirects))
+	if err != nil {
+		t.Fatalf("%d redirects: %v", MaxRedirects, err)
+	}
+	resp.Body.Close()
+
+	_, err = client.Get(srv.URL + "/" + strings.Repeat("x", MaxRedirects+1))
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Fatalf("%d redirects: error = %v, want ErrTooManyRedirects", MaxRedirects+1, err)
+	}
+}
+
+func TestRedirectToBlockedScheme(t *testing.T) {
+	srv := httptest.NewServer(http.RedirectHandler("file:///etc/passwd", http.StatusFound))
+	defer srv.Close()
+
+	_, err := newClient(5*time.Second, allowOnly(t, srv)).Get(srv.URL)
+	if !errors.Is(err, ErrBlockedScheme) {
+		t.Fatalf("Get error = %v, want ErrBlockedScheme", err)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"http://example.com/", nil},
+		{"https://example.com/a?b=c", nil},
+		{"file:///etc/passwd", ErrBlockedScheme},
+		{"gopher://example.com/", ErrBlockedScheme},
+		{"ftp://example.com/file", ErrBlockedScheme},
+		{"javascript:alert(1)", ErrBlockedScheme},
+		{"data:text/html,hi", ErrBlockedScheme},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.url, err)
+		}
+		if err := CheckURL(u); !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+
+	if err := CheckURL(&url.URL{Scheme: "http"}); err == nil {
+		t.Error("CheckURL accepted a url without a host")
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HNBase is the base URL for resolving relative links in HN story and comment text.
+var HNBase = &url.URL{Scheme: "https", Host: "news.ycombinator.com", Path: "/"}
+
+// allowedElements maps each permitted element to its permitted attributes,
+// in addition to globalAttrs. Elements not listed here are unwrapped (their
+// children are kept) unless they appear in droppedElements.
+var allowedElements = map[atom.Atom][]string{
+	atom.A:          {"href"},
+	atom.Abbr:       nil,
+	atom.Article:    nil,
+	atom.B:          nil,
+	atom.Blockquote: {"cite"},
+	atom.Br:         nil,
+	atom.Caption:    nil,
+	atom.Cite:       nil,
+	atom.Code:       nil,
+	atom.Dd:         nil,
+	atom.Del:        {"cite", "datetime"},
+	atom.Details:    nil,
+	atom.Dfn:        nil,
+	atom.Div:        nil,
+	atom.Dl:         nil,
+	atom.Dt:         nil,
+	atom.Em:         nil,
+	atom.Figcaption: nil,
+	atom.Figure:     nil,
+	atom.H1:         nil,
+	atom.H2:         nil,
+	atom.H3:         nil,
+	atom.H4:         nil,
+	atom.H5:         nil,
+	atom.H6:         nil,
+	atom.Hr:         nil,
+	atom.I:          nil,
+	atom.Img:        {"src", "alt", "width", "height"},
+	atom.Ins:        {"cite", "datetime"},
+	atom.Kbd:        nil,
+	atom.Li:         {"value"},
+	atom.Mark:       nil,
+	atom.Ol:         {"start", "reversed"},
+	atom.P:          nil,
+	atom.Pre:        nil,
+	atom.Q:          {"cite"},
+	atom.S:          nil,
+	atom.Samp:       nil,
+	atom.Section:    nil,
+	atom.Small:      nil,
+	atom.Span:       nil,
+	atom.Strong:     nil,
+	atom.Sub:        nil,
+	atom.Summary:    nil,
+	atom.Sup:        nil,
+	atom.Table:      nil,
+	atom.Tbody:      nil,
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      nil,
+	atom.Th:         {"colspan", "rowspan", "scope"},
+	atom.Thead:      nil,
+	atom.Time:       {"datetime"},
+	atom.Tr:         nil,
+	atom.U:          nil,
+	atom.Ul:         nil,
+	atom.Var:        nil,
+}
+
+var globalAttrs = []string{"title", "lang", "dir"}
+
+// droppedElements are removed together with everything inside them.
+var droppedElements = map[atom.Atom]bool{
+	atom.Applet:   true,
+	atom.Audio:    true,
+	atom.Base:     true,
+	atom.Button:   true,
+	atom.Embed:    true,
+	atom.Form:     true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Head:     true,
+	atom.Iframe:   true,
+	atom.Input:    true,
+	atom.Link:     true,
+	atom.Math:     true,
+	atom.Meta:     true,
+	atom.Noembed:  true,
+	atom.Noframes: true,
+	atom.Noscript: true,
+	atom.Object:   true,
+	atom.Script:   true,
+	atom.Select:   true,
+	atom.Style:    true,
+	atom.Svg:      true,
+	atom.Template: true,
+	atom.Textarea: true,
+	atom.Title:    true,
+	atom.Video:    true,
+}
+
+// urlAttrs are attributes whose values are URLs, with the schemes allowed in each.
+var urlAttrs = map[string][]string{
+	"href": {"http", "https", "mailto"},
+	"src":  {"http", "https"},
+	"cite": {"http", "https"},
+}
+
+// HTML sanitizes an untrusted HTML fragment against an element and attribute
+// allow-list. Relative URLs are resolved against base, URLs with other
+// schemes (javascript:, data:, ...) are removed, and links open in a new
+// context with rel="noopener noreferrer".
+func HTML(src string, base *url.URL) string {
+	if src == "" {
+		return ""
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		// The parser only fails on reader errors; treat as plain text.
+		return html.EscapeString(src)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		for _, out := range clean(n, base) {
+			if err := html.Render(&b, out); err != nil {
+				return ""
+			}
+		}
+	}
+	return b.String()
+}
+
+// Text sanitizes an optional HN story or comment text field. Nil stays nil.
+func Text(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	out := HTML(*s, HNBase)
+	return &out
+}
+
+// PlainText returns the text content of an HTML fragment with runs of
+// whitespace, including element boundaries, collapsed to single spaces.
+func PlainText(src string) string {
+	if src == "" {
+		return ""
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		return strings.Join(strings.Fields(src), " ")
+	}
+
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// clean returns the sanitized replacement nodes for n, detached from any tree.
+func clean(n *html.Node, base *url.URL) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: n.Data}}
+	case html.ElementNode:
+	case html.DocumentNode:
+		return cleanChildren(n, base)
+	default:
+		// Comments, doctypes and raw nodes are dropped.
+		return nil
+	}
+
+	if droppedElements[n.DataAtom] || n.DataAtom == 0 && isDroppedName(n.Data) {
+		return nil
+	}
+	allowed, ok := allowedElements[n.DataAtom]
+	if !ok || n.Namespace != "" {
+		return cleanChildren(n, base)
+	}
+
+	out := &html.Node{Type: html.ElementNode, Data: n.DataAtom.String(), DataAtom: n.DataAtom}
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !permitted(attr.Key, allowed) {
+			continue
+		}
+		val := attr.Val
+		if schemes, isURL := urlAttrs[attr.Key]; isURL {
+			var ok bool
+			if val, ok = safeURL(val, base, schemes); !ok {
+				continue
+			}
+		}
+		out.Attr = append(out.Attr, html.Attribute{Key: attr.Key, Val: val})
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		out.Attr = append(out.Attr,
+			html.Attribute{Key: "target", Val: "_blank"},
+			html.Attribute{Key: "rel", Val: "noopener noreferrer"},
+		)
+	case atom.Img:
+		if !hasAttr(out, "src") {
+			return nil
+		}
+	}
+
+	for _, child := range cleanChildren(n, base) {
+		out.AppendChild(child)
+	}
+	return []*html.Node{out}
+}
+
+func cleanChildren(n *html.Node, base *url.URL) []*html.Node {
+	var out []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		out = append(out, clean(c, base)...)
+	}
+	return out
+}
+
+// isDroppedName catches elements the parser didn't map to an atom, such as
+// namespaced or misspelled variants of dropped elements.
+func isDroppedName(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return droppedElements[atom.Lookup([]byte(name))]
+}
+
+func permitted(key string, allowed []string) bool {
+	for _, k := range allowed {
+		if k == key {
+			return true
+		}
+	}
+	for _, k := range globalAttrs {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// safeURL resolves raw against base and reports whether the result uses one
+// of the allowed schemes.
+func safeURL(raw string, base *url.URL, schemes []string) (string, bool) {
+	// Mirror the WHATWG URL parser: browsers trim leading and trailing C0
+	// controls and spaces and drop tabs and newlines anywhere, so
+	// "java\tscript:" must not slip past the scheme check.
+	raw = strings.TrimFunc(raw, func(r rune) bool { return r <= ' ' })
+	raw = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, raw)
+	if raw == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	for _, s := range schemes {
+		if scheme == s {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
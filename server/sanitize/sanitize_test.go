@@ -0,0 +1,127 @@
+package sanitize
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	base := &url.URL{Scheme: "https", Host: "example.com", Path: "/posts/1"}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", `<p>Hello <b>world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{"script", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"script in unknown element", `<x-foo><script>alert(1)</script>ok</x-foo>`, `ok`},
+		{"namespaced script", `<p><svg:script>alert(1)</svg:script>ok</p>`, `<p>ok</p>`},
+		{"style", `<style>body{display:none}</style><p>ok</p>`, `<p>ok</p>`},
+		{"iframe", `<iframe src="https://evil.example/"></iframe><p>ok</p>`, `<p>ok</p>`},
+		{"object and embed", `<object data="x.swf"><embed src="x.swf"></object>ok`, `ok`},
+		{"form", `<form action="https://evil.example/"><input name="q"><button>go</button></form>ok`, `ok`},
+		{"onerror", `<img src="/a.png" onerror="alert(1)">`, `<img src="https://example.com/a.png"/>`},
+		{"onclick", `<p onclick="alert(1)" title="t">x</p>`, `<p title="t">x</p>`},
+		{"onmouseover on link", `<a href="/x" onmouseover="alert(1)">x</a>`,
+			`<a href="https://example.com/x" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"uppercase javascript href", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"javascript href with whitespace", "<a href=\" \x01java\tscr\nipt:alert(1)\">x</a>", `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"entity-obfuscated javascript href", `<a href="&#106;avascript&#58;alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"hex entity javascript href", `<a href="&#x6A;&#x61;vascript&colon;alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"entity tab in javascript href", `<a href="java&#x09;script:alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"data img", `<img src="data:image/svg+xml,<svg onload=alert(1)>">`, ``},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{"mailto href", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"svg", `<svg onload="alert(1)"><a xlink:href="javascript:alert(1)">x</a></svg>ok`, `ok`},
+		{"svg foreignObject", `<svg><foreignObject><img src=x onerror=alert(1)></foreignObject></svg>ok`, `ok`},
+		{"math", `<math><mtext><img src=x onerror=alert(1)></mtext></math>ok`, `ok`},
+		{"math href", `<math href="javascript:alert(1)"><mi>x</mi></math>ok`, `ok`},
+		{"comment", `<!-- <script>alert(1)</script> -->ok`, `ok`},
+		{"relative link", `<a href="../about">x</a>`, `<a href="https://example.com/about" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"root-relative img", `<img src="/img/a.png" alt="a">`, `<img src="https://example.com/img/a.png" alt="a"/>`},
+		{"protocol-relative link", `<a href="//cdn.example.net/x">x</a>`, `<a href="https://cdn.example.net/x" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"fragment link", `<a href="#top">x</a>`, `<a href="https://example.com/posts/1#top" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"existing rel and target replaced", `<a href="https://a.example/" rel="opener" target="_self">x</a>`,
+			`<a href="https://a.example/" target="_blank" rel="noopener noreferrer">x</a>`},
+		{"unknown element unwrapped", `<center><p>x</p></center>`, `<p>x</p>`},
+		{"escaped text", `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in, base); got != tt.want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLNoActiveContent(t *testing.T) {
+	payloads := []string{
+		`<scr<script>ipt>alert(1)</script>`,
+		`<img src=x onerror=alert(1)//`,
+		`<a href="javascript&colon;alert(1)">x</a>`,
+		`<svg><script>alert(1)</script></svg>`,
+		`<math><style><img src=x onerror=alert(1)></style></math>`,
+		`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+		`<table><td><iframe srcdoc="<script>alert(1)</script>"></iframe></td></table>`,
+		`<details open ontoggle=alert(1)>x</details>`,
+		`<base href="javascript:/"><a href="x">x</a>`,
+		`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+	}
+	for _, in := range payloads {
+		got := strings.ToLower(HTML(in, HNBase))
+		for _, bad := range []string{"<script", "<iframe", "<svg", "<math", "<style", "<base", "<meta", "javascript:", " on"} {
+			if strings.Contains(got, bad) {
+				t.Errorf("HTML(%q) = %q, contains %q", in, got, bad)
+			}
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	if Text(nil) != nil {
+		t.Error("Text(nil) != nil")
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`Hello<p>world`, `Hello<p>world</p>`},
+		{`see <a href="item?id=1">this</a>`, `see <a href="https://news.ycombinator.com/item?id=1" target="_blank" rel="noopener noreferrer">this</a>`},
+		{`<a href="https://example.com/" rel="nofollow">https://example.com/</a>`,
+			`<a href="https://example.com/" target="_blank" rel="noopener noreferrer">https://example.com/</a>`},
+		{`<i>x</i><script>alert(1)</script>`, `<i>x</i>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a target="_blank" rel="noopener noreferrer">x</a>`},
+		{`<img src=x onerror=alert(1)>`, `<img src="https://news.ycombinator.com/x"/>`},
+		{`<pre><code>  x &lt; y</code></pre>`, `<pre><code>  x &lt; y</code></pre>`},
+	}
+	for _, tt := range tests {
+		in := tt.in
+		got := Text(&in)
+		if got == nil || *got != tt.want {
+			t.Errorf("Text(%q)\n got %v\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{``, ``},
+		{`<p>Hello</p><p>world</p>`, `Hello world`},
+		{`a &amp; b`, `a & b`},
+		{"  lots\n of\t space ", `lots of space`},
+	}
+	for _, tt := range tests {
+		if got := PlainText(tt.in); got != tt.want {
+			t.Errorf("PlainText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -12,6 +12,8 @@ type Event struct {
 	ID    uint64
 	Type  string
 	Data  string
+	// User restricts delivery to one user's subscribers; empty broadcasts.
+	User  string
 }
 
 func (e *Event) Format() string {
@@ -20,28 +22,44 @@ func (e *Event) Format() string {
 
 type Broker struct {
 	mu          sync.RWMutex
-	subscribers map[chan *Event]struct{}
+	subscribers map[chan *Event]string // channel -> subscriber's user
 	ring        []*Event
 	ringSize    int
 	nextID      uint64
+	active      chan struct{}
+	userOf      func(*http.Request) string
 }
 
 func NewBroker(ringSize int) *Broker {
 	return &Broker{
-		subscribers: make(map[chan *Event]struct{}),
+		subscribers: make(map[chan *Event]string),
 		ring:        make([]*Event, 0, ringSize),
 		ringSize:    ringSize,
 		nextID:      1,
+		active:      make(chan struct{}, 1),
 	}
 }
 
+// SetUserFunc sets how subscribers are identified for PublishTo. Without it,
+// every subscriber is the anonymous user "".
+func (b *Broker) SetUserFunc(userOf func(*http.Request) string) {
+	b.userOf = userOf
+}
+
 // Publish broadcasts an event to all subscribers and stores in ring buffer.
 func (b *Broker) Publish(eventType, data string) {
+	b.PublishTo("", eventType, data)
+}
+
+// PublishTo sends an event only to the given user's subscribers. An empty
+// user broadcasts.
+func (b *Broker) PublishTo(user, eventType, data string) {
 	b.mu.Lock()
 	evt := &Event{
 		ID:   b.nextID,
 		Type: eventType,
 		Data: data,
+		User: user,
 	}
 	b.nextID++
 
@@ -53,8 +71,10 @@ func (b *Broker) Publish(eventType, data string) {
 
 	// Copy subscribers to avoid holding lock during send
 	subs := make([]chan *Event, 0, len(b.subscribers))
-	for ch := range b.subscribers {
-		subs = append(subs, ch)
+	for ch, u := range b.subscribers {
+		if user == "" || user == u {
+			subs = append(subs, ch)
+		}
 	}
 	b.mu.Unlock()
 
@@ -67,11 +87,18 @@ func (b *Broker) Publish(eventType, data string) {
 	}
 }
 
-func (b *Broker) subscribe() chan *Event {
+func (b *Broker) subscribe(user string) chan *Event {
 	ch := make(chan *Event, 64)
 	b.mu.Lock()
-	b.subscribers[ch] = struct{}{}
+	b.subscribers[ch] = user
+	first := len(b.subscribers) == 1
 	b.mu.Unlock()
+	if first {
+		select {
+		case b.active <- struct{}{}:
+		default:
+		}
+	}
 	return ch
 }
 
@@ -82,9 +109,10 @@ func (b *Broker) unsubscribe(ch chan *Event) {
 	close(ch)
 }
 
-// eventsAfter returns all events in the ring buffer after the given ID.
-// If the ID is too old (not in buffer), returns nil to indicate sync_required.
-func (b *Broker) eventsAfter(lastID uint64) ([]*Event, bool) {
+// eventsAfter returns all events in the ring buffer after the given ID that
+// are visible to user. If the ID is too old (not in buffer), returns nil to
+// indicate sync_required.
+func (b *Broker) eventsAfter(lastID uint64, user string) ([]*Event, bool) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
@@ -100,7 +128,7 @@ func (b *Broker) eventsAfter(lastID uint64) ([]*Event, bool) {
 
 	var events []*Event
 	for _, e := range b.ring {
-		if e.ID > lastID {
+		if e.ID > lastID && (e.User == "" || e.User == user) {
 			events = append(events, e)
 		}
 	}
@@ -119,6 +147,11 @@ func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
+	var user string
+	if b.userOf != nil {
+		user = b.userOf(r)
+	}
+
 	// Handle Last-Event-ID (header for browser reconnects, query param for initial connect)
 	lastEventID := r.Header.Get("Last-Event-ID")
 	if lastEventID == "" {
@@ -126,7 +159,7 @@ func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if lastEventID != "" {
 		if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
-			events, ok := b.eventsAfter(id)
+			events, ok := b.eventsAfter(id, user)
 			if !ok {
 				// Too old, send sync_required
 				fmt.Fprintf(w, "id: %d\nevent: sync_required\ndata: {}\n\n", b.nextID-1)
@@ -140,7 +173,7 @@ func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	ch := b.subscribe()
+	ch := b.subscribe(user)
 	defer b.unsubscribe(ch)
 
 	// Send a keepalive comment immediately
@@ -164,6 +197,12 @@ func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Active receives a signal whenever the first client subscribes after a
+// period with none, so idle background work can speed back up.
+func (b *Broker) Active() <-chan struct{} {
+	return b.active
+}
+
 func (b *Broker) SubscriberCount() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+)
+
+// ArticleMeta is the reading metadata for a story's extracted article.
+type ArticleMeta = GetArticleMetaByStoryIDsRow
+
+// StoryListItem is a story as returned by list endpoints, with metadata from
+// its extracted article when one exists.
+type StoryListItem struct {
+	*Story
+	Article *ArticleMeta `json:"article"`
+}
+
+// WithArticleMeta attaches article metadata to stories, preserving order.
+func WithArticleMeta(ctx context.Context, db DBTX, q *Queries, stories []*Story) ([]*StoryListItem, error) {
+	ids := make([]int, len(stories))
+	for i, st := range stories {
+		ids[i] = st.ID
+	}
+
+	metas, err := q.GetArticleMetaByStoryIDs(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*ArticleMeta, len(metas))
+	for _, m := range metas {
+		byID[m.StoryID] = m
+	}
+
+	items := make([]*StoryListItem, len(stories))
+	for i, st := range stories {
+		items[i] = &StoryListItem{Story: st, Article: byID[st.ID]}
+	}
+	return items, nil
+}
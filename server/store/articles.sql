@@ -1,14 +1,58 @@
 -- name: UpsertArticle :exec
-INSERT INTO articles (story_id, content, title, excerpt, byline, extraction_failed, fetched_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO articles (story_id, content, title, excerpt, byline, extraction_failed, fetched_at,
+    word_count, reading_time_minutes, language, lead_image_url, site_name, published_at,
+    source, archive_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(story_id) DO UPDATE SET
     content=excluded.content, title=excluded.title, excerpt=excluded.excerpt,
     byline=excluded.byline, extraction_failed=excluded.extraction_failed,
-    fetched_at=excluded.fetched_at;
+    fetched_at=excluded.fetched_at,
+    word_count=excluded.word_count, reading_time_minutes=excluded.reading_time_minutes,
+    language=excluded.language, lead_image_url=excluded.lead_image_url,
+    site_name=excluded.site_name, published_at=excluded.published_at,
+    source=excluded.source, archive_url=excluded.archive_url;
 
 -- name: GetArticleByStoryID :one
-SELECT story_id, content, title, excerpt, byline, extraction_failed, fetched_at
+SELECT story_id, content, title, excerpt, byline, extraction_failed, fetched_at,
+    word_count, reading_time_minutes, language, lead_image_url, site_name, published_at,
+    source, archive_url
 FROM articles WHERE story_id = ?;
 
+-- name: GetArticleMetaByStoryIDs :many
+SELECT story_id, extraction_failed, word_count, reading_time_minutes, language,
+    lead_image_url, site_name, published_at, source
+FROM articles WHERE story_id IN (sqlc.slice('story_ids'));
+
+-- name: GetArticleContents :many
+SELECT story_id, content FROM articles
+WHERE story_id IN (sqlc.slice(story_ids)) AND NOT extraction_failed AND content IS NOT NULL AND content != '';
+
 -- name: DeleteArticle :exec
 DELETE FROM articles WHERE story_id = ?;
+
+-- name: InsertArticleVersion :exec
+INSERT INTO article_versions (story_id, content, title, word_count, fetched_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetLatestArticleVersion :one
+SELECT id, story_id, content, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ?
+ORDER BY id DESC LIMIT 1;
+
+-- name: GetArticleVersion :one
+SELECT id, story_id, content, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ? AND id = ?;
+
+-- name: ListArticleVersions :many
+SELECT id, story_id, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ?
+ORDER BY id ASC;
+
+-- name: ListReextractCandidates :many
+SELECT s.id, s.url
+FROM stories s
+JOIN articles a ON a.story_id = s.id
+WHERE s.rank IS NOT NULL AND s.rank <= CAST(sqlc.arg(max_rank) AS INTEGER)
+AND s.url IS NOT NULL
+AND a.fetched_at < sqlc.arg(fetched_before)
+ORDER BY s.rank ASC;
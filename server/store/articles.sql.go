@@ -7,6 +7,7 @@ package store
 
 import (
 	"context"
+	"strings"
 )
 
 const deleteArticle = `-- name: DeleteArticle :exec
@@ -19,7 +20,9 @@ func (q *Queries) DeleteArticle(ctx context.Context, db DBTX, storyID int) error
 }
 
 const getArticleByStoryID = `-- name: GetArticleByStoryID :one
-SELECT story_id, content, title, excerpt, byline, extraction_failed, fetched_at
+SELECT story_id, content, title, excerpt, byline, extraction_failed, fetched_at,
+    word_count, reading_time_minutes, language, lead_image_url, site_name, published_at,
+    source, archive_url
 FROM articles WHERE story_id = ?
 `
 
@@ -34,27 +37,307 @@ func (q *Queries) GetArticleByStoryID(ctx context.Context, db DBTX, storyID int)
 		&i.Byline,
 		&i.ExtractionFailed,
 		&i.FetchedAt,
+		&i.WordCount,
+		&i.ReadingTimeMinutes,
+		&i.Language,
+		&i.LeadImageURL,
+		&i.SiteName,
+		&i.PublishedAt,
+		&i.Source,
+		&i.ArchiveURL,
 	)
 	return &i, err
 }
 
+const getArticleContents = `-- name: GetArticleContents :many
+SELECT story_id, content FROM articles
+WHERE story_id IN (/*SLICE:story_ids*/?) AND NOT extraction_failed AND content IS NOT NULL AND content != ''
+`
+
+type GetArticleContentsRow struct {
+	StoryID int     `json:"story_id"`
+	Content *string `json:"content"`
+}
+
+func (q *Queries) GetArticleContents(ctx context.Context, db DBTX, storyIds []int) ([]*GetArticleContentsRow, error) {
+	query := getArticleContents
+	var queryParams []interface{}
+	if len(storyIds) > 0 {
+		for _, v := range storyIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", strings.Repeat(",?", len(storyIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", "NULL", 1)
+	}
+	rows, err := db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*GetArticleContentsRow{}
+	for rows.Next() {
+		var i GetArticleContentsRow
+		if err := rows.Scan(&i.StoryID, &i.Content); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getArticleMetaByStoryIDs = `-- name: GetArticleMetaByStoryIDs :many
+SELECT story_id, extraction_failed, word_count, reading_time_minutes, language,
+    lead_image_url, site_name, published_at, source
+FROM articles WHERE story_id IN (/*SLICE:story_ids*/?)
+`
+
+type GetArticleMetaByStoryIDsRow struct {
+	StoryID            int     `json:"story_id"`
+	ExtractionFailed   bool    `json:"extraction_failed"`
+	WordCount          *int    `json:"word_count"`
+	ReadingTimeMinutes *int    `json:"reading_time_minutes"`
+	Language           *string `json:"language"`
+	LeadImageURL       *string `json:"lead_image_url"`
+	SiteName           *string `json:"site_name"`
+	PublishedAt        *int64  `json:"published_at"`
+	Source             string  `json:"source"`
+}
+
+func (q *Queries) GetArticleMetaByStoryIDs(ctx context.Context, db DBTX, storyIds []int) ([]*GetArticleMetaByStoryIDsRow, error) {
+	query := getArticleMetaByStoryIDs
+	var queryParams []interface{}
+	if len(storyIds) > 0 {
+		for _, v := range storyIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", strings.Repeat(",?", len(storyIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", "NULL", 1)
+	}
+	rows, err := db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*GetArticleMetaByStoryIDsRow{}
+	for rows.Next() {
+		var i GetArticleMetaByStoryIDsRow
+		if err := rows.Scan(
+			&i.StoryID,
+			&i.ExtractionFailed,
+			&i.WordCount,
+			&i.ReadingTimeMinutes,
+			&i.Language,
+			&i.LeadImageURL,
+			&i.SiteName,
+			&i.PublishedAt,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getArticleVersion = `-- name: GetArticleVersion :one
+SELECT id, story_id, content, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ? AND id = ?
+`
+
+type GetArticleVersionParams struct {
+	StoryID int `json:"story_id"`
+	ID      int `json:"id"`
+}
+
+func (q *Queries) GetArticleVersion(ctx context.Context, db DBTX, arg GetArticleVersionParams) (*ArticleVersion, error) {
+	row := db.QueryRowContext(ctx, getArticleVersion, arg.StoryID, arg.ID)
+	var i ArticleVersion
+	err := row.Scan(
+		&i.ID,
+		&i.StoryID,
+		&i.Content,
+		&i.Title,
+		&i.WordCount,
+		&i.FetchedAt,
+	)
+	return &i, err
+}
+
+const getLatestArticleVersion = `-- name: GetLatestArticleVersion :one
+SELECT id, story_id, content, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ?
+ORDER BY id DESC LIMIT 1
+`
+
+func (q *Queries) GetLatestArticleVersion(ctx context.Context, db DBTX, storyID int) (*ArticleVersion, error) {
+	row := db.QueryRowContext(ctx, getLatestArticleVersion, storyID)
+	var i ArticleVersion
+	err := row.Scan(
+		&i.ID,
+		&i.StoryID,
+		&i.Content,
+		&i.Title,
+		&i.WordCount,
+		&i.FetchedAt,
+	)
+	return &i, err
+}
+
+const insertArticleVersion = `-- name: InsertArticleVersion :exec
+INSERT INTO article_versions (story_id, content, title, word_count, fetched_at)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type InsertArticleVersionParams struct {
+	StoryID   int     `json:"story_id"`
+	Content   string  `json:"content"`
+	Title     *string `json:"title"`
+	WordCount int     `json:"word_count"`
+	FetchedAt int64   `json:"fetched_at"`
+}
+
+func (q *Queries) InsertArticleVersion(ctx context.Context, db DBTX, arg InsertArticleVersionParams) error {
+	_, err := db.ExecContext(ctx, insertArticleVersion,
+		arg.StoryID,
+		arg.Content,
+		arg.Title,
+		arg.WordCount,
+		arg.FetchedAt,
+	)
+	return err
+}
+
+const listArticleVersions = `-- name: ListArticleVersions :many
+SELECT id, story_id, title, word_count, fetched_at
+FROM article_versions WHERE story_id = ?
+ORDER BY id ASC
+`
+
+type ListArticleVersionsRow struct {
+	ID        int     `json:"id"`
+	StoryID   int     `json:"story_id"`
+	Title     *string `json:"title"`
+	WordCount int     `json:"word_count"`
+	FetchedAt int64   `json:"fetched_at"`
+}
+
+func (q *Queries) ListArticleVersions(ctx context.Context, db DBTX, storyID int) ([]*ListArticleVersionsRow, error) {
+	rows, err := db.QueryContext(ctx, listArticleVersions, storyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListArticleVersionsRow{}
+	for rows.Next() {
+		var i ListArticleVersionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.StoryID,
+			&i.Title,
+			&i.WordCount,
+			&i.FetchedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReextractCandidates = `-- name: ListReextractCandidates :many
+SELECT s.id, s.url
+FROM stories s
+JOIN articles a ON a.story_id = s.id
+WHERE s.rank IS NOT NULL AND s.rank <= CAST(?1 AS INTEGER)
+AND s.url IS NOT NULL
+AND a.fetched_at < ?2
+ORDER BY s.rank ASC
+`
+
+type ListReextractCandidatesParams struct {
+	MaxRank       int   `json:"max_rank"`
+	FetchedBefore int64 `json:"fetched_before"`
+}
+
+type ListReextractCandidatesRow struct {
+	ID  int     `json:"id"`
+	URL *string `json:"url"`
+}
+
+func (q *Queries) ListReextractCandidates(ctx context.Context, db DBTX, arg ListReextractCandidatesParams) ([]*ListReextractCandidatesRow, error) {
+	rows, err := db.QueryContext(ctx, listReextractCandidates, arg.MaxRank, arg.FetchedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListReextractCandidatesRow{}
+	for rows.Next() {
+		var i ListReextractCandidatesRow
+		if err := rows.Scan(&i.ID, &i.URL); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const upsertArticle = `-- name: UpsertArticle :exec
-INSERT INTO articles (story_id, content, title, excerpt, byline, extraction_failed, fetched_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO articles (story_id, content, title, excerpt, byline, extraction_failed, fetched_at,
+    word_count, reading_time_minutes, language, lead_image_url, site_name, published_at,
+    source, archive_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(story_id) DO UPDATE SET
     content=excluded.content, title=excluded.title, excerpt=excluded.excerpt,
     byline=excluded.byline, extraction_failed=excluded.extraction_failed,
-    fetched_at=excluded.fetched_at
+    fetched_at=excluded.fetched_at,
+    word_count=excluded.word_count, reading_time_minutes=excluded.reading_time_minutes,
+    language=excluded.language, lead_image_url=excluded.lead_image_url,
+    site_name=excluded.site_name, published_at=excluded.published_at,
+    source=excluded.source, archive_url=excluded.archive_url
 `
 
 type UpsertArticleParams struct {
-	StoryID          int     `json:"story_id"`
-	Content          *string `json:"content"`
-	Title            *string `json:"title"`
-	Excerpt          *string `json:"excerpt"`
-	Byline           *string `json:"byline"`
-	ExtractionFailed bool    `json:"extraction_failed"`
-	FetchedAt        int64   `json:"fetched_at"`
+	StoryID            int     `json:"story_id"`
+	Content            *string `json:"content"`
+	Title              *string `json:"title"`
+	Excerpt            *string `json:"excerpt"`
+	Byline             *string `json:"byline"`
+	ExtractionFailed   bool    `json:"extraction_failed"`
+	FetchedAt          int64   `json:"fetched_at"`
+	WordCount          *int    `json:"word_count"`
+	ReadingTimeMinutes *int    `json:"reading_time_minutes"`
+	Language           *string `json:"language"`
+	LeadImageURL       *string `json:"lead_image_url"`
+	SiteName           *string `json:"site_name"`
+	PublishedAt        *int64  `json:"published_at"`
+	Source             string  `json:"source"`
+	ArchiveURL         *string `json:"archive_url"`
 }
 
 func (q *Queries) UpsertArticle(ctx context.Context, db DBTX, arg UpsertArticleParams) error {
@@ -66,6 +349,14 @@ func (q *Queries) UpsertArticle(ctx context.Context, db DBTX, arg UpsertArticleP
 		arg.Byline,
 		arg.ExtractionFailed,
 		arg.FetchedAt,
+		arg.WordCount,
+		arg.ReadingTimeMinutes,
+		arg.Language,
+		arg.LeadImageURL,
+		arg.SiteName,
+		arg.PublishedAt,
+		arg.Source,
+		arg.ArchiveURL,
 	)
 	return err
 }
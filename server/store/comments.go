@@ -7,11 +7,44 @@ import (
 // CommentNode wraps a Comment with its nested children for tree responses.
 type CommentNode struct {
 	*Comment
+	// Muted marks comments by users the reader filtered out. They stay in the
+	// tree so replies keep their context, and clients show them collapsed.
+	Muted    bool           `json:"muted,omitempty"`
 	Children []*CommentNode `json:"children"`
+	// More counts the replies left out of Children when a response is
+	// limited in depth; they can be loaded from the comment's subtree.
+	More int `json:"more,omitempty"`
 }
 
-// GetCommentTree returns all comments for a story as a nested tree.
-func GetCommentTree(ctx context.Context, db DBTX, q *Queries, storyID int) ([]*CommentNode, int64, error) {
+// CommentState is the stored state of a comment that re-fetches compare
+// against to detect edits, deletions and moderation.
+type CommentState = GetCommentStatesByStoryRow
+
+// Comment revision changes.
+const (
+	RevisionEdited  = "edited"
+	RevisionDeleted = "deleted"
+	RevisionDead    = "dead"
+	RevisionRevived = "revived"
+)
+
+// CommentStatesByIDs returns the stored state of the given comments, keyed by ID.
+func CommentStatesByIDs(ctx context.Context, db DBTX, q *Queries, ids []int) (map[int]*CommentState, error) {
+	rows, err := q.GetCommentStatesByIDs(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[int]*CommentState, len(rows))
+	for _, row := range rows {
+		st := CommentState(*row)
+		states[st.ID] = &st
+	}
+	return states, nil
+}
+
+// GetCommentTree returns all comments for a story as a nested tree, marking
+// comments by users muted in filter.
+func GetCommentTree(ctx context.Context, db DBTX, q *Queries, storyID int, filter *StoryFilter) ([]*CommentNode, int64, error) {
 	rows, err := q.GetCommentsByStory(ctx, db, storyID)
 	if err != nil {
 		return nil, 0, err
@@ -22,7 +55,7 @@ func GetCommentTree(ctx context.Context, db DBTX, q *Queries, storyID int) ([]*C
 	var maxFetchedAt int64
 
 	for _, row := range rows {
-		node := &CommentNode{Comment: row, Children: []*CommentNode{}}
+		node := &CommentNode{Comment: row, Muted: row.By != nil && filter.Mutes(*row.By), Children: []*CommentNode{}}
 		if node.FetchedAt > maxFetchedAt {
 			maxFetchedAt = node.FetchedAt
 		}
@@ -57,3 +90,51 @@ func pruneDeleted(comments []*CommentNode) []*CommentNode {
 	}
 	return result
 }
+
+// TopComment returns the top-level comment on a story with the most replies
+// beneath it, the earliest on ties, or nil if there are no live comments. HN
+// doesn't expose comment points, so reply count stands in for them.
+func TopComment(ctx context.Context, db DBTX, q *Queries, storyID int) (*Comment, error) {
+	rows, err := q.GetCommentsByStory(ctx, db, storyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are oldest first, so parents precede their replies.
+	rootOf := make(map[int]int, len(rows))
+	replies := make(map[int]int)
+	for _, row := range rows {
+		if row.ParentID == nil {
+			rootOf[row.ID] = row.ID
+			continue
+		}
+		if root, ok := rootOf[*row.ParentID]; ok {
+			rootOf[row.ID] = root
+			replies[root]++
+		}
+	}
+
+	var top *Comment
+	for _, row := range rows {
+		if row.ParentID != nil || row.Dead || row.Deleted || row.Text == nil || *row.Text == "" {
+			continue
+		}
+		if top == nil || replies[row.ID] > replies[top.ID] {
+			top = row
+		}
+	}
+	return top, nil
+}
+
+// FindComment returns the node with id in a comment tree, or nil.
+func FindComment(nodes []*CommentNode, id int) *CommentNode {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n
+		}
+		if found := FindComment(n.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
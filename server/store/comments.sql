@@ -10,10 +10,48 @@ ON CONFLICT(id) DO UPDATE SET
 -- name: CommentExists :one
 SELECT COUNT(*) FROM comments WHERE id = ?;
 
+-- name: GetCommentByID :one
+SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at, edited_at
+FROM comments WHERE id = ?;
+
 -- name: GetCommentsByStory :many
-SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at
+SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at, edited_at
 FROM comments WHERE story_id = ?
-ORDER BY time ASC;
+ORDER BY time ASC, id ASC;
 
 -- name: GetCommentIDsByStory :many
 SELECT id FROM comments WHERE story_id = ?;
+
+-- name: GetCommentStatesByStory :many
+SELECT id, story_id, text, dead, deleted FROM comments WHERE story_id = ?;
+
+-- name: GetCommentStatesByIDs :many
+SELECT id, story_id, text, dead, deleted FROM comments WHERE id IN (sqlc.slice(ids));
+
+-- name: MarkCommentEdited :exec
+UPDATE comments SET edited_at = ? WHERE id = ?;
+
+-- name: InsertCommentRevision :exec
+INSERT INTO comment_revisions (comment_id, story_id, change, old_text, new_text, detected_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListCommentRevisionsByStory :many
+SELECT id, comment_id, story_id, change, old_text, new_text, detected_at
+FROM comment_revisions WHERE story_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: ListCommentsDueForRefresh :many
+-- Comments on stories outside the eager set, in age tiers that are
+-- re-checked at decreasing frequency. Deleted comments are final.
+SELECT c.id FROM comments c
+JOIN stories s ON s.id = c.story_id
+WHERE (s.rank IS NULL OR s.rank > CAST(sqlc.arg(eager_rank) AS INTEGER))
+AND c.deleted = FALSE
+AND (
+    (c.time >= sqlc.arg(recent_since) AND c.fetched_at < sqlc.arg(recent_checked_before))
+    OR (c.time < sqlc.arg(recent_since) AND c.time >= sqlc.arg(day_since) AND c.fetched_at < sqlc.arg(day_checked_before))
+    OR (c.time < sqlc.arg(day_since) AND c.time >= sqlc.arg(week_since) AND c.fetched_at < sqlc.arg(week_checked_before))
+)
+ORDER BY c.time DESC
+LIMIT sqlc.arg(max_comments);
@@ -7,6 +7,7 @@ package store
 
 import (
 	"context"
+	"strings"
 )
 
 const commentExists = `-- name: CommentExists :one
@@ -20,6 +21,29 @@ func (q *Queries) CommentExists(ctx context.Context, db DBTX, id int) (int, erro
 	return count, err
 }
 
+const getCommentByID = `-- name: GetCommentByID :one
+SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at, edited_at
+FROM comments WHERE id = ?
+`
+
+func (q *Queries) GetCommentByID(ctx context.Context, db DBTX, id int) (*Comment, error) {
+	row := db.QueryRowContext(ctx, getCommentByID, id)
+	var i Comment
+	err := row.Scan(
+		&i.ID,
+		&i.StoryID,
+		&i.ParentID,
+		&i.By,
+		&i.Text,
+		&i.Time,
+		&i.Dead,
+		&i.Deleted,
+		&i.FetchedAt,
+		&i.EditedAt,
+	)
+	return &i, err
+}
+
 const getCommentIDsByStory = `-- name: GetCommentIDsByStory :many
 SELECT id FROM comments WHERE story_id = ?
 `
@@ -47,10 +71,102 @@ func (q *Queries) GetCommentIDsByStory(ctx context.Context, db DBTX, storyID int
 	return items, nil
 }
 
+const getCommentStatesByIDs = `-- name: GetCommentStatesByIDs :many
+SELECT id, story_id, text, dead, deleted FROM comments WHERE id IN (/*SLICE:ids*/?)
+`
+
+type GetCommentStatesByIDsRow struct {
+	ID      int     `json:"id"`
+	StoryID int     `json:"story_id"`
+	Text    *string `json:"text"`
+	Dead    bool    `json:"dead"`
+	Deleted bool    `json:"deleted"`
+}
+
+func (q *Queries) GetCommentStatesByIDs(ctx context.Context, db DBTX, ids []int) ([]*GetCommentStatesByIDsRow, error) {
+	query := getCommentStatesByIDs
+	var queryParams []interface{}
+	if len(ids) > 0 {
+		for _, v := range ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	rows, err := db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*GetCommentStatesByIDsRow{}
+	for rows.Next() {
+		var i GetCommentStatesByIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.StoryID,
+			&i.Text,
+			&i.Dead,
+			&i.Deleted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCommentStatesByStory = `-- name: GetCommentStatesByStory :many
+SELECT id, story_id, text, dead, deleted FROM comments WHERE story_id = ?
+`
+
+type GetCommentStatesByStoryRow struct {
+	ID      int     `json:"id"`
+	StoryID int     `json:"story_id"`
+	Text    *string `json:"text"`
+	Dead    bool    `json:"dead"`
+	Deleted bool    `json:"deleted"`
+}
+
+func (q *Queries) GetCommentStatesByStory(ctx context.Context, db DBTX, storyID int) ([]*GetCommentStatesByStoryRow, error) {
+	rows, err := db.QueryContext(ctx, getCommentStatesByStory, storyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*GetCommentStatesByStoryRow{}
+	for rows.Next() {
+		var i GetCommentStatesByStoryRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.StoryID,
+			&i.Text,
+			&i.Dead,
+			&i.Deleted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getCommentsByStory = `-- name: GetCommentsByStory :many
-SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at
+SELECT id, story_id, parent_id, by, text, time, dead, deleted, fetched_at, edited_at
 FROM comments WHERE story_id = ?
-ORDER BY time ASC
+ORDER BY time ASC, id ASC
 `
 
 func (q *Queries) GetCommentsByStory(ctx context.Context, db DBTX, storyID int) ([]*Comment, error) {
@@ -72,6 +188,76 @@ func (q *Queries) GetCommentsByStory(ctx context.Context, db DBTX, storyID int)
 			&i.Dead,
 			&i.Deleted,
 			&i.FetchedAt,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertCommentRevision = `-- name: InsertCommentRevision :exec
+INSERT INTO comment_revisions (comment_id, story_id, change, old_text, new_text, detected_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type InsertCommentRevisionParams struct {
+	CommentID  int     `json:"comment_id"`
+	StoryID    int     `json:"story_id"`
+	Change     string  `json:"change"`
+	OldText    *string `json:"old_text"`
+	NewText    *string `json:"new_text"`
+	DetectedAt int64   `json:"detected_at"`
+}
+
+func (q *Queries) InsertCommentRevision(ctx context.Context, db DBTX, arg InsertCommentRevisionParams) error {
+	_, err := db.ExecContext(ctx, insertCommentRevision,
+		arg.CommentID,
+		arg.StoryID,
+		arg.Change,
+		arg.OldText,
+		arg.NewText,
+		arg.DetectedAt,
+	)
+	return err
+}
+
+const listCommentRevisionsByStory = `-- name: ListCommentRevisionsByStory :many
+SELECT id, comment_id, story_id, change, old_text, new_text, detected_at
+FROM comment_revisions WHERE story_id = ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+type ListCommentRevisionsByStoryParams struct {
+	StoryID int `json:"story_id"`
+	Limit   int `json:"limit"`
+}
+
+func (q *Queries) ListCommentRevisionsByStory(ctx context.Context, db DBTX, arg ListCommentRevisionsByStoryParams) ([]*CommentRevision, error) {
+	rows, err := db.QueryContext(ctx, listCommentRevisionsByStory, arg.StoryID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*CommentRevision{}
+	for rows.Next() {
+		var i CommentRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.CommentID,
+			&i.StoryID,
+			&i.Change,
+			&i.OldText,
+			&i.NewText,
+			&i.DetectedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -86,6 +272,79 @@ func (q *Queries) GetCommentsByStory(ctx context.Context, db DBTX, storyID int)
 	return items, nil
 }
 
+const listCommentsDueForRefresh = `-- name: ListCommentsDueForRefresh :many
+SELECT c.id FROM comments c
+JOIN stories s ON s.id = c.story_id
+WHERE (s.rank IS NULL OR s.rank > CAST(?1 AS INTEGER))
+AND c.deleted = FALSE
+AND (
+    (c.time >= ?2 AND c.fetched_at < ?3)
+    OR (c.time < ?2 AND c.time >= ?4 AND c.fetched_at < ?5)
+    OR (c.time < ?4 AND c.time >= ?6 AND c.fetched_at < ?7)
+)
+ORDER BY c.time DESC
+LIMIT ?8
+`
+
+type ListCommentsDueForRefreshParams struct {
+	EagerRank           int   `json:"eager_rank"`
+	RecentSince         int64 `json:"recent_since"`
+	RecentCheckedBefore int64 `json:"recent_checked_before"`
+	DaySince            int64 `json:"day_since"`
+	DayCheckedBefore    int64 `json:"day_checked_before"`
+	WeekSince           int64 `json:"week_since"`
+	WeekCheckedBefore   int64 `json:"week_checked_before"`
+	MaxComments         int   `json:"max_comments"`
+}
+
+// Comments on stories outside the eager set, in age tiers that are
+// re-checked at decreasing frequency. Deleted comments are final.
+func (q *Queries) ListCommentsDueForRefresh(ctx context.Context, db DBTX, arg ListCommentsDueForRefreshParams) ([]int, error) {
+	rows, err := db.QueryContext(ctx, listCommentsDueForRefresh,
+		arg.EagerRank,
+		arg.RecentSince,
+		arg.RecentCheckedBefore,
+		arg.DaySince,
+		arg.DayCheckedBefore,
+		arg.WeekSince,
+		arg.WeekCheckedBefore,
+		arg.MaxComments,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markCommentEdited = `-- name: MarkCommentEdited :exec
+UPDATE comments SET edited_at = ? WHERE id = ?
+`
+
+type MarkCommentEditedParams struct {
+	EditedAt *int64 `json:"edited_at"`
+	ID       int    `json:"id"`
+}
+
+func (q *Queries) MarkCommentEdited(ctx context.Context, db DBTX, arg MarkCommentEditedParams) error {
+	_, err := db.ExecContext(ctx, markCommentEdited, arg.EditedAt, arg.ID)
+	return err
+}
+
 const upsertComment = `-- name: UpsertComment :exec
 INSERT INTO comments (id, story_id, parent_id, by, text, time, dead, deleted, fetched_at)
 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
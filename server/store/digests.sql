@@ -0,0 +1,32 @@
+-- name: GetDigestSettings :one
+SELECT user_sub, email, frequency, send_hour, weekday, timezone, story_count, next_send_at, last_sent_at
+FROM digest_settings WHERE user_sub = ?;
+
+-- name: UpsertDigestSettings :one
+INSERT INTO digest_settings (user_sub, email, frequency, send_hour, weekday, timezone, story_count, next_send_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_sub) DO UPDATE SET
+    email = excluded.email,
+    frequency = excluded.frequency,
+    send_hour = excluded.send_hour,
+    weekday = excluded.weekday,
+    timezone = excluded.timezone,
+    story_count = excluded.story_count,
+    next_send_at = excluded.next_send_at
+RETURNING user_sub, email, frequency, send_hour, weekday, timezone, story_count, next_send_at, last_sent_at;
+
+-- name: DeleteDigestSettings :execrows
+DELETE FROM digest_settings WHERE user_sub = ?;
+
+-- name: ListDueDigests :many
+SELECT user_sub FROM digest_settings WHERE next_send_at <= ?;
+
+-- name: SetDigestSchedule :exec
+-- Advances a digest past the occurrence that was sent or skipped. The
+-- expected next_send_at guards against settings changed in the meantime.
+UPDATE digest_settings SET next_send_at = sqlc.arg(next_send_at), last_sent_at = COALESCE(sqlc.narg(last_sent_at), last_sent_at)
+WHERE user_sub = sqlc.arg(user_sub) AND next_send_at = sqlc.arg(due_at);
+
+-- name: GetArticleExcerpts :many
+SELECT story_id, excerpt FROM articles
+WHERE story_id IN (sqlc.slice(story_ids)) AND NOT extraction_failed AND excerpt IS NOT NULL AND excerpt != '';
@@ -0,0 +1,14 @@
+-- name: GetFeedToken :one
+SELECT user_sub, token, created_at FROM feed_tokens WHERE user_sub = ?;
+
+-- name: GetFeedTokenUser :one
+SELECT user_sub FROM feed_tokens WHERE token = ?;
+
+-- name: SetFeedToken :one
+-- Replaces any previous token, revoking it.
+INSERT INTO feed_tokens (user_sub, token, created_at) VALUES (?, ?, ?)
+ON CONFLICT (user_sub) DO UPDATE SET token = excluded.token, created_at = excluded.created_at
+RETURNING user_sub, token, created_at;
+
+-- name: DeleteFeedToken :execrows
+DELETE FROM feed_tokens WHERE user_sub = ?;
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/danielmmetz/hn-client/server/urlnorm"
+)
+
+// Filter kinds.
+const (
+	FilterDomain  = "domain"
+	FilterKeyword = "keyword"
+	FilterUser    = "user"
+)
+
+// ErrInvalidFilter is returned by NormalizeFilter for unknown kinds and
+// empty values.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// NormalizeFilter returns the stored form of a filter value: registrable
+// domains for domain filters, and trimmed lowercase text otherwise.
+func NormalizeFilter(kind, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch kind {
+	case FilterDomain:
+		value = urlnorm.Domain(value)
+	case FilterKeyword, FilterUser:
+	default:
+		return "", ErrInvalidFilter
+	}
+	if value == "" {
+		return "", ErrInvalidFilter
+	}
+	return value, nil
+}
+
+// StoryFilter is a user's filter rules and hidden stories, compiled for
+// matching. The zero value and nil match nothing.
+type StoryFilter struct {
+	domains  map[string]bool
+	keywords []string
+	users    map[string]bool
+	hidden   map[int]bool
+}
+
+// NewStoryFilter compiles stored filter rules and hidden story IDs.
+func NewStoryFilter(rules []*Filter, hiddenIDs []int) *StoryFilter {
+	f := &StoryFilter{domains: make(map[string]bool), users: make(map[string]bool), hidden: make(map[int]bool, len(hiddenIDs))}
+	for _, id := range hiddenIDs {
+		f.hidden[id] = true
+	}
+	for _, r := range rules {
+		switch r.Kind {
+		case FilterDomain:
+			f.domains[r.Value] = true
+		case FilterKeyword:
+			f.keywords = append(f.keywords, r.Value)
+		case FilterUser:
+			f.users[r.Value] = true
+		}
+	}
+	return f
+}
+
+// LoadStoryFilter returns the compiled filter rules and hidden stories of a user.
+func LoadStoryFilter(ctx context.Context, db DBTX, q *Queries, userSub string) (*StoryFilter, error) {
+	rules, err := q.ListFilters(ctx, db, userSub)
+	if err != nil {
+		return nil, err
+	}
+	hiddenIDs, err := q.ListHiddenStoryIDs(ctx, db, userSub)
+	if err != nil {
+		return nil, err
+	}
+	return NewStoryFilter(rules, hiddenIDs), nil
+}
+
+// Empty reports whether the filter hides nothing.
+func (f *StoryFilter) Empty() bool {
+	return f == nil || len(f.domains) == 0 && len(f.keywords) == 0 && len(f.users) == 0 && len(f.hidden) == 0
+}
+
+// Hides reports whether a story is excluded from lists: it was hidden, it's
+// from a muted domain or submitter, or its title contains a muted keyword as
+// whole words.
+func (f *StoryFilter) Hides(s *Story) bool {
+	if f.Empty() {
+		return false
+	}
+	if f.hidden[s.ID] {
+		return true
+	}
+	if s.Domain != nil && f.domains[*s.Domain] {
+		return true
+	}
+	if f.users[strings.ToLower(s.By)] {
+		return true
+	}
+	title := strings.ToLower(s.Title)
+	for _, kw := range f.keywords {
+		if ContainsWords(title, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mutes reports whether comments by the given user are collapsed.
+func (f *StoryFilter) Mutes(by string) bool {
+	return !f.Empty() && f.users[strings.ToLower(by)]
+}
+
+// ContainsWords reports whether phrase occurs in s without being part of a
+// longer word, so "go" matches "Show HN: Go tools" but not "Google".
+func ContainsWords(s, phrase string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], phrase)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		i = start + size
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
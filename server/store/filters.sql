@@ -0,0 +1,22 @@
+-- name: ListFilters :many
+SELECT id, user_sub, kind, value, created_at FROM filters
+WHERE user_sub = ?
+ORDER BY kind, value;
+
+-- name: GetFilter :one
+SELECT id, user_sub, kind, value, created_at FROM filters
+WHERE id = ? AND user_sub = ?;
+
+-- name: CreateFilter :one
+INSERT INTO filters (user_sub, kind, value, created_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (user_sub, kind, value) DO NOTHING
+RETURNING id, user_sub, kind, value, created_at;
+
+-- name: UpdateFilter :one
+-- Returns no row if the change would duplicate another of the user's filters.
+UPDATE OR IGNORE filters SET kind = ?, value = ?
+WHERE id = ? AND user_sub = ?
+RETURNING id, user_sub, kind, value, created_at;
+
+-- name: DeleteFilter :execrows
+DELETE FROM filters WHERE id = ? AND user_sub = ?;
@@ -0,0 +1,20 @@
+-- name: HideStory :exec
+INSERT INTO hidden_stories (user_sub, story_id, hidden_at) VALUES (?, ?, ?)
+ON CONFLICT (user_sub, story_id) DO NOTHING;
+
+-- name: UnhideStory :execrows
+DELETE FROM hidden_stories WHERE user_sub = ? AND story_id = ?;
+
+-- name: ListHiddenStoryIDs :many
+SELECT story_id FROM hidden_stories WHERE user_sub = ?;
+
+-- name: CountHiddenStories :one
+SELECT COUNT(*) FROM hidden_stories WHERE user_sub = ?;
+
+-- name: ListHiddenStories :many
+SELECT s.id, s.title, s.url, s.text, s.score, s.by, s.time, s.descendants, s.type, s.fetched_at, s.rank, s.dead, s.canonical_url, s.domain
+FROM hidden_stories h
+JOIN stories s ON s.id = h.story_id
+WHERE h.user_sub = ?
+ORDER BY h.hidden_at DESC, h.story_id DESC
+LIMIT ? OFFSET ?;
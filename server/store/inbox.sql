@@ -0,0 +1,62 @@
+-- name: GetHNAccount :one
+SELECT user_sub, username, linked_at, checked_at FROM hn_accounts WHERE user_sub = ?;
+
+-- name: ListHNAccounts :many
+SELECT user_sub, username, linked_at, checked_at FROM hn_accounts ORDER BY user_sub;
+
+-- name: LinkHNAccount :one
+-- Linking a different username starts its replies over from a fresh scan.
+INSERT INTO hn_accounts (user_sub, username, linked_at) VALUES (?, ?, ?)
+ON CONFLICT (user_sub) DO UPDATE SET
+    username = excluded.username,
+    linked_at = excluded.linked_at,
+    checked_at = NULL
+WHERE hn_accounts.username != excluded.username
+RETURNING user_sub, username, linked_at, checked_at;
+
+-- name: UnlinkHNAccount :execrows
+DELETE FROM hn_accounts WHERE user_sub = ?;
+
+-- name: SetHNAccountChecked :exec
+UPDATE hn_accounts SET checked_at = ? WHERE user_sub = ? AND username = ?;
+
+-- name: ClearInbox :exec
+DELETE FROM inbox WHERE user_sub = ?;
+
+-- name: ListInboxReplyIDs :many
+SELECT reply_id FROM inbox WHERE user_sub = sqlc.arg(user_sub) AND reply_id IN (sqlc.slice(reply_ids));
+
+-- name: CreateInboxReply :one
+-- Returns no row if the reply is already in the inbox.
+INSERT INTO inbox (user_sub, reply_id, parent_id, by, text, time, created_at, read_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_sub, reply_id) DO NOTHING
+RETURNING id;
+
+-- name: GetInboxReply :one
+SELECT i.id, i.reply_id, i.parent_id, i.by, i.text, i.time, c.story_id, s.title AS story_title, i.created_at, i.read_at
+FROM inbox i
+LEFT JOIN comments c ON c.id = i.parent_id
+LEFT JOIN stories s ON s.id = c.story_id
+WHERE i.id = ?;
+
+-- name: ListInbox :many
+SELECT i.id, i.reply_id, i.parent_id, i.by, i.text, i.time, c.story_id, s.title AS story_title, i.created_at, i.read_at
+FROM inbox i
+LEFT JOIN comments c ON c.id = i.parent_id
+LEFT JOIN stories s ON s.id = c.story_id
+WHERE i.user_sub = sqlc.arg(user_sub)
+  AND (CAST(sqlc.arg(unread_only) AS BOOLEAN) = 0 OR i.read_at IS NULL)
+ORDER BY i.time DESC, i.id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountInbox :one
+SELECT COUNT(*) AS total, CAST(COALESCE(SUM(read_at IS NULL), 0) AS INTEGER) AS unread
+FROM inbox WHERE user_sub = ?;
+
+-- name: MarkInboxRead :execrows
+UPDATE inbox SET read_at = sqlc.arg(read_at)
+WHERE user_sub = sqlc.arg(user_sub) AND read_at IS NULL AND id IN (sqlc.slice(ids));
+
+-- name: MarkAllInboxRead :execrows
+UPDATE inbox SET read_at = ? WHERE user_sub = ? AND read_at IS NULL;
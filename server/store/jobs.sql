@@ -0,0 +1,57 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, key, payload, priority, status, attempts, max_attempts, run_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, 'pending', 0, ?, ?, ?, ?)
+ON CONFLICT(key) WHERE status IN ('pending', 'running') DO UPDATE SET
+    priority=max(jobs.priority, excluded.priority),
+    run_at=min(jobs.run_at, excluded.run_at),
+    payload=CASE WHEN jobs.status = 'pending' THEN excluded.payload ELSE jobs.payload END,
+    next_payload=CASE WHEN jobs.status = 'running' AND jobs.payload <> excluded.payload THEN excluded.payload ELSE jobs.next_payload END,
+    updated_at=excluded.updated_at
+RETURNING id, status, next_payload;
+
+-- name: ClaimJob :one
+UPDATE jobs SET status = 'running', attempts = attempts + 1, updated_at = sqlc.arg(now)
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE status = 'pending' AND run_at <= sqlc.arg(now)
+    ORDER BY priority DESC, run_at ASC, id ASC
+    LIMIT 1
+)
+RETURNING id, kind, key, payload, priority, status, attempts, max_attempts, run_at, created_at, updated_at, last_error, next_payload;
+
+-- name: NextJobRunAt :one
+SELECT CAST(COALESCE(MIN(run_at), 0) AS INTEGER) AS next_run_at FROM jobs WHERE status = 'pending';
+
+-- name: CompleteJob :exec
+UPDATE jobs SET status = 'done', last_error = NULL, updated_at = ? WHERE id = ?;
+
+-- name: RetryJob :exec
+UPDATE jobs SET status = 'pending', priority = ?, run_at = ?, last_error = ?, updated_at = ? WHERE id = ?;
+
+-- name: PromoteNextPayload :execrows
+UPDATE jobs SET status = 'pending', payload = next_payload, next_payload = NULL, attempts = 0,
+    run_at = ?, last_error = NULL, updated_at = ?
+WHERE id = ? AND next_payload IS NOT NULL;
+
+-- name: RequeueJob :exec
+UPDATE jobs SET status = 'pending', attempts = max(attempts - 1, 0), last_error = ?, updated_at = ? WHERE id = ?;
+
+-- name: FailJob :exec
+UPDATE jobs SET status = 'failed', last_error = ?, updated_at = ? WHERE id = ?;
+
+-- name: ResetRunningJobs :execrows
+UPDATE jobs SET status = 'pending', updated_at = ? WHERE status = 'running';
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs WHERE status IN ('done', 'failed') AND updated_at < ?;
+
+-- name: CountJobsByStatus :many
+SELECT kind, status, COUNT(*) AS count FROM jobs GROUP BY kind, status ORDER BY kind, status;
+
+-- name: ListJobs :many
+SELECT id, kind, key, payload, priority, status, attempts, max_attempts, run_at, created_at, updated_at, last_error, next_payload
+FROM jobs
+WHERE (CAST(sqlc.arg(status) AS TEXT) = '' OR status = sqlc.arg(status))
+ORDER BY CASE status WHEN 'running' THEN 0 WHEN 'pending' THEN 1 ELSE 2 END,
+    priority DESC, updated_at DESC
+LIMIT sqlc.arg(max_jobs);
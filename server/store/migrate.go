@@ -0,0 +1,310 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/danielmmetz/hn-client/server/sanitize"
+	"github.com/danielmmetz/hn-client/server/urlnorm"
+)
+
+// addedColumns lists columns introduced after their table was first created.
+// schema.sql carries the full definitions for fresh databases (and for sqlc);
+// these are applied to existing databases before the schema runs so that any
+// indexes it declares on new columns can be created.
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"articles", "word_count", "INTEGER"},
+	{"articles", "reading_time_minutes", "INTEGER"},
+	{"articles", "language", "TEXT"},
+	{"articles", "lead_image_url", "TEXT"},
+	{"articles", "site_name", "TEXT"},
+	{"articles", "published_at", "INTEGER"},
+	{"articles", "source", "TEXT NOT NULL DEFAULT 'origin'"},
+	{"articles", "archive_url", "TEXT"},
+	{"comments", "edited_at", "INTEGER"},
+	{"stories", "canonical_url", "TEXT"},
+	{"stories", "domain", "TEXT"},
+	{"jobs", "next_payload", "TEXT"},
+}
+
+// rebuiltTables lists derived tables whose key changed. An existing table
+// missing the given column is dropped, recreated by schema.sql and refilled
+// by the workers that own it.
+var rebuiltTables = []struct {
+	table, column string
+}{
+	{"rankings", "algo"},
+}
+
+func migrate(db *sql.DB) error {
+	for _, t := range rebuiltTables {
+		cols, err := tableColumns(db, t.table)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 0 || cols[t.column] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE %s", t.table)); err != nil {
+			return fmt.Errorf("drop %s: %w", t.table, err)
+		}
+		slog.Info("dropped derived table for rebuild", "table", t.table)
+	}
+
+	for _, c := range addedColumns {
+		cols, err := tableColumns(db, c.table)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 0 || cols[c.column] {
+			continue // table will be created by schema.sql, or column already present
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", c.table, c.column, err)
+		}
+		slog.Info("added column", "table", c.table, "column", c.column)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read user_version: %w", err)
+	}
+	for i := version; i < len(dataMigrations); i++ {
+		if err := dataMigrations[i](context.Background(), db); err != nil {
+			return fmt.Errorf("data migration %d: %w", i+1, err)
+		}
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			return fmt.Errorf("set user_version: %w", err)
+		}
+		slog.Info("applied data migration", "version", i+1)
+	}
+	return nil
+}
+
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("table info %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// dataMigrations rewrite existing rows and run once each, in order. The number
+// applied so far is tracked in PRAGMA user_version; only append to this list.
+var dataMigrations = []func(context.Context, *sql.DB) error{
+	sanitizeStoredHTML,
+	canonicalizeStoryURLs,
+	extractStoryDomains,
+}
+
+// sanitizeStoredHTML applies the HTML sanitizer to content stored before
+// sanitization happened at fetch time.
+func sanitizeStoredHTML(ctx context.Context, db *sql.DB) error {
+	type row struct {
+		id   int
+		html string
+		base string
+	}
+
+	batches := []struct {
+		name   string
+		query  string
+		update string
+	}{
+		{
+			name:   "articles",
+			query:  `SELECT a.story_id, a.content, COALESCE(s.url, '') FROM articles a JOIN stories s ON s.id = a.story_id WHERE a.content IS NOT NULL AND a.story_id > ? ORDER BY a.story_id LIMIT 500`,
+			update: `UPDATE articles SET content = ? WHERE story_id = ?`,
+		},
+		{
+			name:   "stories",
+			query:  `SELECT id, text, '' FROM stories WHERE text IS NOT NULL AND id > ? ORDER BY id LIMIT 500`,
+			update: `UPDATE stories SET text = ? WHERE id = ?`,
+		},
+		{
+			name:   "comments",
+			query:  `SELECT id, text, '' FROM comments WHERE text IS NOT NULL AND id > ? ORDER BY id LIMIT 500`,
+			update: `UPDATE comments SET text = ? WHERE id = ?`,
+		},
+	}
+
+	for _, b := range batches {
+		lastID, total := 0, 0
+		for {
+			rows, err := db.QueryContext(ctx, b.query, lastID)
+			if err != nil {
+				return fmt.Errorf("select %s: %w", b.name, err)
+			}
+			var batch []row
+			for rows.Next() {
+				var r row
+				if err := rows.Scan(&r.id, &r.html, &r.base); err != nil {
+					rows.Close()
+					return err
+				}
+				batch = append(batch, r)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			for _, r := range batch {
+				base := sanitize.HNBase
+				if r.base != "" {
+					if u, err := url.Parse(r.base); err == nil {
+						base = u
+					}
+				}
+				if _, err := tx.ExecContext(ctx, b.update, sanitize.HTML(r.html, base), r.id); err != nil {
+					tx.Rollback()
+					return fmt.Errorf("update %s %d: %w", b.name, r.id, err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			lastID = batch[len(batch)-1].id
+			total += len(batch)
+		}
+		slog.Info("sanitized stored html", "table", b.name, "rows", total)
+	}
+	return nil
+}
+
+// canonicalizeStoryURLs fills canonical_url for stories stored before it was
+// recorded. Shortened links are left as-is; they're resolved when refetched
+// with a changed URL.
+func canonicalizeStoryURLs(ctx context.Context, db *sql.DB) error {
+	type row struct {
+		id  int
+		url string
+	}
+
+	lastID, total := 0, 0
+	for {
+		rows, err := db.QueryContext(ctx, `SELECT id, url FROM stories WHERE url IS NOT NULL AND canonical_url IS NULL AND id > ? ORDER BY id LIMIT 500`, lastID)
+		if err != nil {
+			return fmt.Errorf("select stories: %w", err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.url); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, r := range batch {
+			canonical, err := urlnorm.Canonicalize(r.url)
+			if err != nil {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE stories SET canonical_url = ? WHERE id = ?`, canonical, r.id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("update story %d: %w", r.id, err)
+			}
+			total++
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		lastID = batch[len(batch)-1].id
+	}
+	slog.Info("canonicalized story urls", "rows", total)
+	return nil
+}
+
+// extractStoryDomains fills domain for stories stored before it was recorded,
+// from their canonical URL.
+func extractStoryDomains(ctx context.Context, db *sql.DB) error {
+	type row struct {
+		id        int
+		canonical string
+	}
+
+	lastID, total := 0, 0
+	for {
+		rows, err := db.QueryContext(ctx, `SELECT id, canonical_url FROM stories WHERE canonical_url IS NOT NULL AND domain IS NULL AND id > ? ORDER BY id LIMIT 500`, lastID)
+		if err != nil {
+			return fmt.Errorf("select stories: %w", err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.canonical); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, r := range batch {
+			domain := urlnorm.Domain(r.canonical)
+			if domain == "" {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE stories SET domain = ? WHERE id = ?`, domain, r.id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("update story %d: %w", r.id, err)
+			}
+			total++
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		lastID = batch[len(batch)-1].id
+	}
+	slog.Info("extracted story domains", "rows", total)
+	return nil
+}
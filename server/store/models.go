@@ -4,14 +4,42 @@
 
 package store
 
+type Alert struct {
+	ID        int    `json:"id"`
+	UserSub   string `json:"user_sub"`
+	WatchID   int    `json:"watch_id"`
+	StoryID   int    `json:"story_id"`
+	CommentID *int   `json:"comment_id"`
+	ItemID    int    `json:"item_id"`
+	CreatedAt int64  `json:"created_at"`
+	ReadAt    *int64 `json:"read_at"`
+}
+
 type Article struct {
-	StoryID          int     `json:"story_id"`
-	Content          *string `json:"content"`
-	Title            *string `json:"title"`
-	Excerpt          *string `json:"excerpt"`
-	Byline           *string `json:"byline"`
-	ExtractionFailed bool    `json:"extraction_failed"`
-	FetchedAt        int64   `json:"fetched_at"`
+	StoryID            int     `json:"story_id"`
+	Content            *string `json:"content"`
+	Title              *string `json:"title"`
+	Excerpt            *string `json:"excerpt"`
+	Byline             *string `json:"byline"`
+	ExtractionFailed   bool    `json:"extraction_failed"`
+	FetchedAt          int64   `json:"fetched_at"`
+	WordCount          *int    `json:"word_count"`
+	ReadingTimeMinutes *int    `json:"reading_time_minutes"`
+	Language           *string `json:"language"`
+	LeadImageURL       *string `json:"lead_image_url"`
+	SiteName           *string `json:"site_name"`
+	PublishedAt        *int64  `json:"published_at"`
+	Source             string  `json:"source"`
+	ArchiveURL         *string `json:"archive_url"`
+}
+
+type ArticleVersion struct {
+	ID        int     `json:"id"`
+	StoryID   int     `json:"story_id"`
+	Content   string  `json:"content"`
+	Title     *string `json:"title"`
+	WordCount int     `json:"word_count"`
+	FetchedAt int64   `json:"fetched_at"`
 }
 
 type Comment struct {
@@ -24,11 +52,99 @@ type Comment struct {
 	Dead      bool    `json:"dead"`
 	Deleted   bool    `json:"deleted"`
 	FetchedAt int64   `json:"fetched_at"`
+	EditedAt  *int64  `json:"edited_at"`
+}
+
+type CommentRevision struct {
+	ID         int     `json:"id"`
+	CommentID  int     `json:"comment_id"`
+	StoryID    int     `json:"story_id"`
+	Change     string  `json:"change"`
+	OldText    *string `json:"old_text"`
+	NewText    *string `json:"new_text"`
+	DetectedAt int64   `json:"detected_at"`
+}
+
+type DigestSetting struct {
+	UserSub    string `json:"user_sub"`
+	Email      string `json:"email"`
+	Frequency  string `json:"frequency"`
+	SendHour   int    `json:"send_hour"`
+	Weekday    int    `json:"weekday"`
+	Timezone   string `json:"timezone"`
+	StoryCount int    `json:"story_count"`
+	NextSendAt int64  `json:"next_send_at"`
+	LastSentAt *int64 `json:"last_sent_at"`
+}
+
+type FeedToken struct {
+	UserSub   string `json:"user_sub"`
+	Token     string `json:"token"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type Filter struct {
+	ID        int    `json:"id"`
+	UserSub   string `json:"user_sub"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type HiddenStory struct {
+	UserSub  string `json:"user_sub"`
+	StoryID  int    `json:"story_id"`
+	HiddenAt int64  `json:"hidden_at"`
+}
+
+type HnAccount struct {
+	UserSub   string `json:"user_sub"`
+	Username  string `json:"username"`
+	LinkedAt  int64  `json:"linked_at"`
+	CheckedAt *int64 `json:"checked_at"`
+}
+
+type Inbox struct {
+	ID        int     `json:"id"`
+	UserSub   string  `json:"user_sub"`
+	ReplyID   int     `json:"reply_id"`
+	ParentID  int     `json:"parent_id"`
+	By        string  `json:"by"`
+	Text      *string `json:"text"`
+	Time      int64   `json:"time"`
+	CreatedAt int64   `json:"created_at"`
+	ReadAt    *int64  `json:"read_at"`
+}
+
+type Job struct {
+	ID          int     `json:"id"`
+	Kind        string  `json:"kind"`
+	Key         string  `json:"key"`
+	Payload     string  `json:"payload"`
+	Priority    int     `json:"priority"`
+	Status      string  `json:"status"`
+	Attempts    int     `json:"attempts"`
+	MaxAttempts int     `json:"max_attempts"`
+	RunAt       int64   `json:"run_at"`
+	CreatedAt   int64   `json:"created_at"`
+	UpdatedAt   int64   `json:"updated_at"`
+	LastError   *string `json:"last_error"`
+	NextPayload *string `json:"next_payload"`
+}
+
+type PushSubscription struct {
+	ID        int    `json:"id"`
+	UserSub   string `json:"user_sub"`
+	Endpoint  string `json:"endpoint"`
+	P256dh    string `json:"p256dh"`
+	Auth      string `json:"auth"`
+	CreatedAt int64  `json:"created_at"`
 }
 
 type Ranking struct {
 	StoryID    int     `json:"story_id"`
 	Period     string  `json:"period"`
+	Algo       string  `json:"algo"`
 	Score      float64 `json:"score"`
 	ComputedAt int64   `json:"computed_at"`
 }
@@ -41,16 +157,32 @@ type Session struct {
 }
 
 type Story struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	URL         *string `json:"url"`
-	Text        *string `json:"text"`
-	Score       int     `json:"score"`
-	By          string  `json:"by"`
-	Time        int64   `json:"time"`
-	Descendants int     `json:"descendants"`
-	Type        string  `json:"type"`
-	FetchedAt   int64   `json:"fetched_at"`
-	Rank        *int    `json:"rank"`
-	Dead        bool    `json:"dead"`
+	ID           int     `json:"id"`
+	Title        string  `json:"title"`
+	URL          *string `json:"url"`
+	Text         *string `json:"text"`
+	Score        int     `json:"score"`
+	By           string  `json:"by"`
+	Time         int64   `json:"time"`
+	Descendants  int     `json:"descendants"`
+	Type         string  `json:"type"`
+	FetchedAt    int64   `json:"fetched_at"`
+	Rank         *int    `json:"rank"`
+	Dead         bool    `json:"dead"`
+	CanonicalURL *string `json:"canonical_url"`
+	Domain       *string `json:"domain"`
+}
+
+type VapidKey struct {
+	ID         int    `json:"id"`
+	PrivateKey string `json:"private_key"`
+	CreatedAt  int64  `json:"created_at"`
+}
+
+type Watch struct {
+	ID        int    `json:"id"`
+	UserSub   string `json:"user_sub"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	CreatedAt int64  `json:"created_at"`
 }
@@ -0,0 +1,31 @@
+-- name: GetVAPIDKey :one
+SELECT private_key FROM vapid_keys WHERE id = 1;
+
+-- name: InsertVAPIDKey :exec
+-- Keeps an existing key, so concurrent first starts agree on one.
+INSERT INTO vapid_keys (id, private_key, created_at) VALUES (1, ?, ?)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: UpsertPushSubscription :one
+-- A browser re-subscribing, possibly as another user, replaces its keys.
+INSERT INTO push_subscriptions (user_sub, endpoint, p256dh, auth, created_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (endpoint) DO UPDATE SET
+    user_sub = excluded.user_sub,
+    p256dh = excluded.p256dh,
+    auth = excluded.auth
+RETURNING id, user_sub, endpoint, p256dh, auth, created_at;
+
+-- name: GetPushSubscription :one
+SELECT id, user_sub, endpoint, p256dh, auth, created_at FROM push_subscriptions WHERE id = ?;
+
+-- name: ListPushSubscriptionIDs :many
+SELECT id FROM push_subscriptions WHERE user_sub = ?;
+
+-- name: ListAllPushSubscriptionIDs :many
+SELECT id FROM push_subscriptions;
+
+-- name: DeletePushSubscription :exec
+DELETE FROM push_subscriptions WHERE id = ?;
+
+-- name: DeletePushSubscriptionByEndpoint :execrows
+DELETE FROM push_subscriptions WHERE endpoint = ? AND user_sub = ?;
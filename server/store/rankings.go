@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"strings"
+)
+
+// rankingBatchSize bounds the rows per multi-row statement, keeping each well
+// under SQLite's bound parameter limit.
+const rankingBatchSize = 200
+
+// RankingScore is a computed score for one story under one algorithm.
+type RankingScore struct {
+	StoryID int
+	Algo    string
+	Score   float64
+}
+
+// UpsertRankings writes scores for a period with multi-row INSERT ... ON
+// CONFLICT statements, batched by rankingBatchSize.
+func UpsertRankings(ctx context.Context, db DBTX, period string, computedAt int64, scores []RankingScore) error {
+	for len(scores) > 0 {
+		batch := scores[:min(len(scores), rankingBatchSize)]
+		scores = scores[len(batch):]
+
+		var b strings.Builder
+		b.WriteString("INSERT INTO rankings (story_id, period, algo, score, computed_at) VALUES ")
+		args := make([]interface{}, 0, len(batch)*5)
+		for i, s := range batch {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(?, ?, ?, ?, ?)")
+			args = append(args, s.StoryID, period, s.Algo, s.Score, computedAt)
+		}
+		b.WriteString(" ON CONFLICT (story_id, period, algo) DO UPDATE SET score = excluded.score, computed_at = excluded.computed_at")
+
+		if _, err := db.ExecContext(ctx, b.String(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRankings removes every algorithm's ranking of the given stories for a
+// period, batched by rankingBatchSize.
+func DeleteRankings(ctx context.Context, db DBTX, q *Queries, period string, storyIDs []int) error {
+	for len(storyIDs) > 0 {
+		batch := storyIDs[:min(len(storyIDs), rankingBatchSize)]
+		storyIDs = storyIDs[len(batch):]
+		if err := q.DeleteRankingsForStories(ctx, db, DeleteRankingsForStoriesParams{Period: period, StoryIds: batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
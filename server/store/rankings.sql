@@ -1,19 +1,23 @@
--- name: DeleteRankingsByPeriod :exec
-DELETE FROM rankings WHERE period = ?;
+-- name: ListRankingScores :many
+SELECT story_id, algo, score FROM rankings WHERE period = ?;
 
--- name: InsertRanking :exec
-INSERT INTO rankings (story_id, period, score, computed_at) VALUES (?, ?, ?, ?);
+-- name: DeleteRankingsForStories :exec
+DELETE FROM rankings WHERE period = ? AND story_id IN (sqlc.slice(story_ids));
 
 -- name: CountRankingsByPeriod :one
-SELECT COUNT(*) FROM rankings WHERE period = ?;
+SELECT COUNT(*) FROM rankings r
+JOIN stories s ON s.id = r.story_id
+WHERE r.period = sqlc.arg(period) AND r.algo = sqlc.arg(algo)
+AND (CAST(sqlc.narg(domain) AS TEXT) IS NULL OR s.domain = sqlc.narg(domain));
 
 -- name: GetStoriesByPeriod :many
-SELECT s.id, s.title, s.url, s.text, s.score, s.by, s.time, s.descendants, s.type, s.fetched_at, s.rank, s.dead
+SELECT s.id, s.title, s.url, s.text, s.score, s.by, s.time, s.descendants, s.type, s.fetched_at, s.rank, s.dead, s.canonical_url, s.domain
 FROM rankings r
 JOIN stories s ON s.id = r.story_id
-WHERE r.period = ?
-ORDER BY r.score DESC
-LIMIT ? OFFSET ?;
+WHERE r.period = sqlc.arg(period) AND r.algo = sqlc.arg(algo)
+AND (CAST(sqlc.narg(domain) AS TEXT) IS NULL OR s.domain = sqlc.narg(domain))
+ORDER BY r.score DESC, r.story_id DESC
+LIMIT sqlc.arg(max_stories) OFFSET sqlc.arg(skip);
 
 -- name: HasActiveRankings :one
 SELECT COUNT(*) FROM rankings WHERE story_id = ?;
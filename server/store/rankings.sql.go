@@ -7,45 +7,80 @@ package store
 
 import (
 	"context"
+	"strings"
 )
 
 const countRankingsByPeriod = `-- name: CountRankingsByPeriod :one
-SELECT COUNT(*) FROM rankings WHERE period = ?
+SELECT COUNT(*) FROM rankings r
+JOIN stories s ON s.id = r.story_id
+WHERE r.period = ?1 AND r.algo = ?2
+AND (CAST(?3 AS TEXT) IS NULL OR s.domain = ?3)
 `
 
-func (q *Queries) CountRankingsByPeriod(ctx context.Context, db DBTX, period string) (int, error) {
-	row := db.QueryRowContext(ctx, countRankingsByPeriod, period)
+type CountRankingsByPeriodParams struct {
+	Period string  `json:"period"`
+	Algo   string  `json:"algo"`
+	Domain *string `json:"domain"`
+}
+
+func (q *Queries) CountRankingsByPeriod(ctx context.Context, db DBTX, arg CountRankingsByPeriodParams) (int, error) {
+	row := db.QueryRowContext(ctx, countRankingsByPeriod, arg.Period, arg.Algo, arg.Domain)
 	var count int
 	err := row.Scan(&count)
 	return count, err
 }
 
-const deleteRankingsByPeriod = `-- name: DeleteRankingsByPeriod :exec
-DELETE FROM rankings WHERE period = ?
+const deleteRankingsForStories = `-- name: DeleteRankingsForStories :exec
+DELETE FROM rankings WHERE period = ? AND story_id IN (/*SLICE:story_ids*/?)
 `
 
-func (q *Queries) DeleteRankingsByPeriod(ctx context.Context, db DBTX, period string) error {
-	_, err := db.ExecContext(ctx, deleteRankingsByPeriod, period)
+type DeleteRankingsForStoriesParams struct {
+	Period   string `json:"period"`
+	StoryIds []int  `json:"story_ids"`
+}
+
+func (q *Queries) DeleteRankingsForStories(ctx context.Context, db DBTX, arg DeleteRankingsForStoriesParams) error {
+	query := deleteRankingsForStories
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.Period)
+	if len(arg.StoryIds) > 0 {
+		for _, v := range arg.StoryIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", strings.Repeat(",?", len(arg.StoryIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:story_ids*/?", "NULL", 1)
+	}
+	_, err := db.ExecContext(ctx, query, queryParams...)
 	return err
 }
 
 const getStoriesByPeriod = `-- name: GetStoriesByPeriod :many
-SELECT s.id, s.title, s.url, s.text, s.score, s.by, s.time, s.descendants, s.type, s.fetched_at, s.rank, s.dead
+SELECT s.id, s.title, s.url, s.text, s.score, s.by, s.time, s.descendants, s.type, s.fetched_at, s.rank, s.dead, s.canonical_url, s.domain
 FROM rankings r
 JOIN stories s ON s.id = r.story_id
-WHERE r.period = ?
-ORDER BY r.score DESC
-LIMIT ? OFFSET ?
+WHERE r.period = ?1 AND r.algo = ?2
+AND (CAST(?3 AS TEXT) IS NULL OR s.domain = ?3)
+ORDER BY r.score DESC, r.story_id DESC
+LIMIT ?5 OFFSET ?4
 `
 
 type GetStoriesByPeriodParams struct {
-	Period string `json:"period"`
-	Limit  int    `json:"limit"`
-	Offset int    `json:"offset"`
+	Period     string  `json:"period"`
+	Algo       string  `json:"algo"`
+	Domain     *string `json:"domain"`
+	Skip       int     `json:"skip"`
+	MaxStories int     `json:"max_stories"`
 }
 
 func (q *Queries) GetStoriesByPeriod(ctx context.Context, db DBTX, arg GetStoriesByPeriodParams) ([]*Story, error) {
-	rows, err := db.QueryContext(ctx, getStoriesByPeriod, arg.Period, arg.Limit, arg.Offset)
+	rows, err := db.QueryContext(ctx, getStoriesByPeriod,
+		arg.Period,
+		arg.Algo,
+		arg.Domain,
+		arg.Skip,
+		arg.MaxStories,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +101,8 @@ func (q *Queries) GetStoriesByPeriod(ctx context.Context, db DBTX, arg GetStorie
 			&i.FetchedAt,
 			&i.Rank,
 			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
 		); err != nil {
 			return nil, err
 		}
@@ -91,23 +128,35 @@ func (q *Queries) HasActiveRankings(ctx context.Context, db DBTX, storyID int) (
 	return count, err
 }
 
-const insertRanking = `-- name: InsertRanking :exec
-INSERT INTO rankings (story_id, period, score, computed_at) VALUES (?, ?, ?, ?)
+const listRankingScores = `-- name: ListRankingScores :many
+SELECT story_id, algo, score FROM rankings WHERE period = ?
 `
 
-type InsertRankingParams struct {
-	StoryID    int     `json:"story_id"`
-	Period     string  `json:"period"`
-	Score      float64 `json:"score"`
-	ComputedAt int64   `json:"computed_at"`
+type ListRankingScoresRow struct {
+	StoryID int     `json:"story_id"`
+	Algo    string  `json:"algo"`
+	Score   float64 `json:"score"`
 }
 
-func (q *Queries) InsertRanking(ctx context.Context, db DBTX, arg InsertRankingParams) error {
-	_, err := db.ExecContext(ctx, insertRanking,
-		arg.StoryID,
-		arg.Period,
-		arg.Score,
-		arg.ComputedAt,
-	)
-	return err
+func (q *Queries) ListRankingScores(ctx context.Context, db DBTX, period string) ([]*ListRankingScoresRow, error) {
+	rows, err := db.QueryContext(ctx, listRankingScores, period)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListRankingScoresRow{}
+	for rows.Next() {
+		var i ListRankingScoresRow
+		if err := rows.Scan(&i.StoryID, &i.Algo, &i.Score); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
@@ -10,8 +10,12 @@ CREATE TABLE IF NOT EXISTS stories (
     type        TEXT NOT NULL DEFAULT 'story',
     fetched_at  INTEGER NOT NULL,
     rank        INTEGER,
-    dead        BOOLEAN NOT NULL DEFAULT FALSE
+    dead        BOOLEAN NOT NULL DEFAULT FALSE,
+    canonical_url TEXT,
+    domain      TEXT
 );
+CREATE INDEX IF NOT EXISTS idx_stories_canonical_url ON stories(canonical_url);
+CREATE INDEX IF NOT EXISTS idx_stories_domain_time ON stories(domain, time DESC);
 
 CREATE TABLE IF NOT EXISTS comments (
     id          INTEGER PRIMARY KEY,
@@ -22,9 +26,23 @@ CREATE TABLE IF NOT EXISTS comments (
     time        INTEGER NOT NULL,
     dead        BOOLEAN NOT NULL DEFAULT FALSE,
     deleted     BOOLEAN NOT NULL DEFAULT FALSE,
-    fetched_at  INTEGER NOT NULL
+    fetched_at  INTEGER NOT NULL,
+    edited_at   INTEGER
 );
 CREATE INDEX IF NOT EXISTS idx_comments_story ON comments(story_id);
+CREATE INDEX IF NOT EXISTS idx_comments_time ON comments(time);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id  INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    story_id    INTEGER NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    change      TEXT NOT NULL,
+    old_text    TEXT,
+    new_text    TEXT,
+    detected_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_story ON comment_revisions(story_id, id);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment ON comment_revisions(comment_id);
 
 CREATE TABLE IF NOT EXISTS articles (
     story_id         INTEGER PRIMARY KEY REFERENCES stories(id) ON DELETE CASCADE,
@@ -33,17 +51,152 @@ CREATE TABLE IF NOT EXISTS articles (
     excerpt          TEXT,
     byline           TEXT,
     extraction_failed BOOLEAN NOT NULL DEFAULT FALSE,
-    fetched_at       INTEGER NOT NULL
+    fetched_at       INTEGER NOT NULL,
+    word_count       INTEGER,
+    reading_time_minutes INTEGER,
+    language         TEXT,
+    lead_image_url   TEXT,
+    site_name        TEXT,
+    published_at     INTEGER,
+    source           TEXT NOT NULL DEFAULT 'origin',
+    archive_url      TEXT
+);
+
+CREATE TABLE IF NOT EXISTS article_versions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    story_id    INTEGER NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    content     TEXT NOT NULL,
+    title       TEXT,
+    word_count  INTEGER NOT NULL DEFAULT 0,
+    fetched_at  INTEGER NOT NULL
 );
+CREATE INDEX IF NOT EXISTS idx_article_versions_story ON article_versions(story_id, id);
 
 CREATE TABLE IF NOT EXISTS rankings (
     story_id    INTEGER NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
     period      TEXT NOT NULL,
+    algo        TEXT NOT NULL,
     score       REAL NOT NULL,
     computed_at INTEGER NOT NULL,
-    PRIMARY KEY (story_id, period)
+    PRIMARY KEY (story_id, period, algo)
+);
+CREATE INDEX IF NOT EXISTS idx_rankings_period_algo_score ON rankings(period, algo, score DESC);
+
+CREATE TABLE IF NOT EXISTS filters (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_sub    TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    value       TEXT NOT NULL,
+    created_at  INTEGER NOT NULL,
+    UNIQUE (user_sub, kind, value)
+);
+
+CREATE TABLE IF NOT EXISTS hidden_stories (
+    user_sub    TEXT NOT NULL,
+    story_id    INTEGER NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    hidden_at   INTEGER NOT NULL,
+    PRIMARY KEY (user_sub, story_id)
+);
+
+CREATE TABLE IF NOT EXISTS watches (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_sub    TEXT NOT NULL,
+    kind        TEXT NOT NULL,
+    value       TEXT NOT NULL,
+    created_at  INTEGER NOT NULL,
+    UNIQUE (user_sub, kind, value)
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_sub    TEXT NOT NULL,
+    watch_id    INTEGER NOT NULL REFERENCES watches(id) ON DELETE CASCADE,
+    story_id    INTEGER NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    comment_id  INTEGER,
+    item_id     INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL,
+    read_at     INTEGER,
+    UNIQUE (watch_id, item_id)
+);
+CREATE INDEX IF NOT EXISTS idx_alerts_user ON alerts(user_sub, id DESC);
+
+CREATE TABLE IF NOT EXISTS hn_accounts (
+    user_sub    TEXT PRIMARY KEY,
+    username    TEXT NOT NULL,
+    linked_at   INTEGER NOT NULL,
+    checked_at  INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS inbox (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_sub    TEXT NOT NULL,
+    reply_id    INTEGER NOT NULL,
+    parent_id   INTEGER NOT NULL,
+    by          TEXT NOT NULL,
+    text        TEXT,
+    time        INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL,
+    read_at     INTEGER,
+    UNIQUE (user_sub, reply_id)
+);
+CREATE INDEX IF NOT EXISTS idx_inbox_user ON inbox(user_sub, id DESC);
+
+CREATE TABLE IF NOT EXISTS vapid_keys (
+    id          INTEGER PRIMARY KEY CHECK (id = 1),
+    private_key TEXT NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_sub    TEXT NOT NULL,
+    endpoint    TEXT NOT NULL UNIQUE,
+    p256dh      TEXT NOT NULL,
+    auth        TEXT NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user ON push_subscriptions(user_sub);
+
+CREATE TABLE IF NOT EXISTS digest_settings (
+    user_sub     TEXT PRIMARY KEY,
+    email        TEXT NOT NULL,
+    frequency    TEXT NOT NULL,
+    send_hour    INTEGER NOT NULL,
+    weekday      INTEGER NOT NULL,
+    timezone     TEXT NOT NULL,
+    story_count  INTEGER NOT NULL,
+    next_send_at INTEGER NOT NULL,
+    last_sent_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_digest_settings_next ON digest_settings(next_send_at);
+
+-- Feed readers can't sign in, so feeds accept a per-user token instead of
+-- a session when authentication is on.
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    user_sub   TEXT PRIMARY KEY,
+    token      TEXT NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind        TEXT NOT NULL,
+    key         TEXT NOT NULL,
+    payload     TEXT NOT NULL,
+    priority    INTEGER NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'pending',
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at      INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL,
+    updated_at  INTEGER NOT NULL,
+    last_error  TEXT,
+    -- Payload enqueued while the job was running, run once it finishes.
+    next_payload TEXT
 );
-CREATE INDEX IF NOT EXISTS idx_rankings_period_score ON rankings(period, score DESC);
+-- At most one live job per key; finished jobs are kept for inspection.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_live_key ON jobs(key) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs(status, priority DESC, run_at);
 
 CREATE TABLE IF NOT EXISTS sessions (
     token      TEXT PRIMARY KEY,
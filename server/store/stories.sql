@@ -1,30 +1,35 @@
 -- name: UpsertStory :exec
-INSERT INTO stories (id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO stories (id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(id) DO UPDATE SET
     title=excluded.title, url=excluded.url, text=excluded.text,
     score=excluded.score, by=excluded.by, time=excluded.time,
     descendants=excluded.descendants, type=excluded.type,
     fetched_at=excluded.fetched_at,
     rank=COALESCE(excluded.rank, stories.rank),
-    dead=excluded.dead;
+    dead=excluded.dead,
+    -- keep a resolved shortener target until the URL itself changes
+    canonical_url=CASE WHEN stories.url IS excluded.url THEN COALESCE(stories.canonical_url, excluded.canonical_url) ELSE excluded.canonical_url END,
+    domain=CASE WHEN stories.url IS excluded.url THEN COALESCE(stories.domain, excluded.domain) ELSE excluded.domain END;
 
 -- name: GetStoryByID :one
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE id = ?;
 
 -- name: GetStoriesByIDs :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE id IN (sqlc.slice('ids'));
 
 -- name: CountRankedStories :one
-SELECT COUNT(*) FROM stories WHERE rank IS NOT NULL;
+SELECT COUNT(*) FROM stories WHERE rank IS NOT NULL
+AND (CAST(sqlc.narg(domain) AS TEXT) IS NULL OR domain = sqlc.narg(domain));
 
 -- name: ListStoriesByRank :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE rank IS NOT NULL
+AND (CAST(sqlc.narg(domain) AS TEXT) IS NULL OR domain = sqlc.narg(domain))
 ORDER BY rank ASC
-LIMIT ? OFFSET ?;
+LIMIT sqlc.arg(max_stories) OFFSET sqlc.arg(skip);
 
 -- name: ClearRanks :exec
 UPDATE stories SET rank = NULL;
@@ -48,7 +53,7 @@ SELECT COUNT(*) FROM comments WHERE story_id = ?;
 SELECT fetched_at FROM stories WHERE id = ?;
 
 -- name: ListStoriesByTimeRange :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE time >= ? AND time < ?
 ORDER BY time DESC;
 
@@ -56,7 +61,54 @@ ORDER BY time DESC;
 SELECT s.id FROM stories s
 WHERE s.rank IS NULL
 AND s.fetched_at < ?
+AND s.time < ?
 AND NOT EXISTS (SELECT 1 FROM rankings r WHERE r.story_id = s.id);
 
 -- name: DeleteStory :exec
 DELETE FROM stories WHERE id = ?;
+
+-- name: SetStoryResolvedURL :exec
+UPDATE stories SET canonical_url = ?, domain = ? WHERE id = ?;
+
+-- name: ListStoriesByCanonicalURL :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories WHERE canonical_url = sqlc.arg(canonical_url) AND id != sqlc.arg(id)
+ORDER BY time DESC
+LIMIT sqlc.arg(max_stories);
+
+-- name: ListRelatedStoriesByDomain :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories
+WHERE domain = CAST(sqlc.arg(domain) AS TEXT) AND canonical_url != CAST(sqlc.arg(exclude_url) AS TEXT)
+ORDER BY time DESC
+LIMIT sqlc.arg(max_stories);
+
+-- name: ListStoriesByDomain :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories WHERE domain = ?
+ORDER BY time DESC
+LIMIT ? OFFSET ?;
+
+-- name: ListDomainStats :many
+SELECT s.domain AS domain,
+    COUNT(*) AS story_count,
+    CAST(AVG(s.score) AS REAL) AS avg_score,
+    COUNT(a.story_id) AS extraction_attempts,
+    CAST(COALESCE(SUM(a.extraction_failed = FALSE), 0) AS INTEGER) AS extraction_successes
+FROM stories s
+LEFT JOIN articles a ON a.story_id = s.id
+WHERE s.domain IS NOT NULL
+GROUP BY s.domain
+ORDER BY story_count DESC, s.domain
+LIMIT ?;
+
+-- name: GetDomainStats :one
+SELECT s.domain AS domain,
+    COUNT(*) AS story_count,
+    CAST(AVG(s.score) AS REAL) AS avg_score,
+    COUNT(a.story_id) AS extraction_attempts,
+    CAST(COALESCE(SUM(a.extraction_failed = FALSE), 0) AS INTEGER) AS extraction_successes
+FROM stories s
+LEFT JOIN articles a ON a.story_id = s.id
+WHERE s.domain = ?
+GROUP BY s.domain;
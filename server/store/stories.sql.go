@@ -32,10 +32,11 @@ func (q *Queries) CountCommentsForStory(ctx context.Context, db DBTX, storyID in
 
 const countRankedStories = `-- name: CountRankedStories :one
 SELECT COUNT(*) FROM stories WHERE rank IS NOT NULL
+AND (CAST(?1 AS TEXT) IS NULL OR domain = ?1)
 `
 
-func (q *Queries) CountRankedStories(ctx context.Context, db DBTX) (int, error) {
-	row := db.QueryRowContext(ctx, countRankedStories)
+func (q *Queries) CountRankedStories(ctx context.Context, db DBTX, domain *string) (int, error) {
+	row := db.QueryRowContext(ctx, countRankedStories, domain)
 	var count int
 	err := row.Scan(&count)
 	return count, err
@@ -61,8 +62,41 @@ func (q *Queries) DeleteStory(ctx context.Context, db DBTX, id int) error {
 	return err
 }
 
+const getDomainStats = `-- name: GetDomainStats :one
+SELECT s.domain AS domain,
+    COUNT(*) AS story_count,
+    CAST(AVG(s.score) AS REAL) AS avg_score,
+    COUNT(a.story_id) AS extraction_attempts,
+    CAST(COALESCE(SUM(a.extraction_failed = FALSE), 0) AS INTEGER) AS extraction_successes
+FROM stories s
+LEFT JOIN articles a ON a.story_id = s.id
+WHERE s.domain = ?
+GROUP BY s.domain
+`
+
+type GetDomainStatsRow struct {
+	Domain              *string `json:"domain"`
+	StoryCount          int     `json:"story_count"`
+	AvgScore            float64 `json:"avg_score"`
+	ExtractionAttempts  int     `json:"extraction_attempts"`
+	ExtractionSuccesses int     `json:"extraction_successes"`
+}
+
+func (q *Queries) GetDomainStats(ctx context.Context, db DBTX, domain *string) (*GetDomainStatsRow, error) {
+	row := db.QueryRowContext(ctx, getDomainStats, domain)
+	var i GetDomainStatsRow
+	err := row.Scan(
+		&i.Domain,
+		&i.StoryCount,
+		&i.AvgScore,
+		&i.ExtractionAttempts,
+		&i.ExtractionSuccesses,
+	)
+	return &i, err
+}
+
 const getStoriesByIDs = `-- name: GetStoriesByIDs :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE id IN (/*SLICE:ids*/?)
 `
 
@@ -98,6 +132,8 @@ func (q *Queries) GetStoriesByIDs(ctx context.Context, db DBTX, ids []int) ([]*S
 			&i.FetchedAt,
 			&i.Rank,
 			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
 		); err != nil {
 			return nil, err
 		}
@@ -113,7 +149,7 @@ func (q *Queries) GetStoriesByIDs(ctx context.Context, db DBTX, ids []int) ([]*S
 }
 
 const getStoryByID = `-- name: GetStoryByID :one
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE id = ?
 `
 
@@ -133,6 +169,8 @@ func (q *Queries) GetStoryByID(ctx context.Context, db DBTX, id int) (*Story, er
 		&i.FetchedAt,
 		&i.Rank,
 		&i.Dead,
+		&i.CanonicalURL,
+		&i.Domain,
 	)
 	return &i, err
 }
@@ -148,20 +186,227 @@ func (q *Queries) GetStoryFetchedAt(ctx context.Context, db DBTX, id int) (int64
 	return fetched_at, err
 }
 
+const listDomainStats = `-- name: ListDomainStats :many
+SELECT s.domain AS domain,
+    COUNT(*) AS story_count,
+    CAST(AVG(s.score) AS REAL) AS avg_score,
+    COUNT(a.story_id) AS extraction_attempts,
+    CAST(COALESCE(SUM(a.extraction_failed = FALSE), 0) AS INTEGER) AS extraction_successes
+FROM stories s
+LEFT JOIN articles a ON a.story_id = s.id
+WHERE s.domain IS NOT NULL
+GROUP BY s.domain
+ORDER BY story_count DESC, s.domain
+LIMIT ?
+`
+
+type ListDomainStatsRow struct {
+	Domain              *string `json:"domain"`
+	StoryCount          int     `json:"story_count"`
+	AvgScore            float64 `json:"avg_score"`
+	ExtractionAttempts  int     `json:"extraction_attempts"`
+	ExtractionSuccesses int     `json:"extraction_successes"`
+}
+
+func (q *Queries) ListDomainStats(ctx context.Context, db DBTX, limit int) ([]*ListDomainStatsRow, error) {
+	rows, err := db.QueryContext(ctx, listDomainStats, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListDomainStatsRow{}
+	for rows.Next() {
+		var i ListDomainStatsRow
+		if err := rows.Scan(
+			&i.Domain,
+			&i.StoryCount,
+			&i.AvgScore,
+			&i.ExtractionAttempts,
+			&i.ExtractionSuccesses,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRelatedStoriesByDomain = `-- name: ListRelatedStoriesByDomain :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories
+WHERE domain = CAST(?1 AS TEXT) AND canonical_url != CAST(?2 AS TEXT)
+ORDER BY time DESC
+LIMIT ?3
+`
+
+type ListRelatedStoriesByDomainParams struct {
+	Domain     string `json:"domain"`
+	ExcludeURL string `json:"exclude_url"`
+	MaxStories int    `json:"max_stories"`
+}
+
+func (q *Queries) ListRelatedStoriesByDomain(ctx context.Context, db DBTX, arg ListRelatedStoriesByDomainParams) ([]*Story, error) {
+	rows, err := db.QueryContext(ctx, listRelatedStoriesByDomain, arg.Domain, arg.ExcludeURL, arg.MaxStories)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*Story{}
+	for rows.Next() {
+		var i Story
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.URL,
+			&i.Text,
+			&i.Score,
+			&i.By,
+			&i.Time,
+			&i.Descendants,
+			&i.Type,
+			&i.FetchedAt,
+			&i.Rank,
+			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStoriesByCanonicalURL = `-- name: ListStoriesByCanonicalURL :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories WHERE canonical_url = ?1 AND id != ?2
+ORDER BY time DESC
+LIMIT ?3
+`
+
+type ListStoriesByCanonicalURLParams struct {
+	CanonicalURL *string `json:"canonical_url"`
+	ID           int     `json:"id"`
+	MaxStories   int     `json:"max_stories"`
+}
+
+func (q *Queries) ListStoriesByCanonicalURL(ctx context.Context, db DBTX, arg ListStoriesByCanonicalURLParams) ([]*Story, error) {
+	rows, err := db.QueryContext(ctx, listStoriesByCanonicalURL, arg.CanonicalURL, arg.ID, arg.MaxStories)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*Story{}
+	for rows.Next() {
+		var i Story
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.URL,
+			&i.Text,
+			&i.Score,
+			&i.By,
+			&i.Time,
+			&i.Descendants,
+			&i.Type,
+			&i.FetchedAt,
+			&i.Rank,
+			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStoriesByDomain = `-- name: ListStoriesByDomain :many
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
+FROM stories WHERE domain = ?
+ORDER BY time DESC
+LIMIT ? OFFSET ?
+`
+
+type ListStoriesByDomainParams struct {
+	Domain *string `json:"domain"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+func (q *Queries) ListStoriesByDomain(ctx context.Context, db DBTX, arg ListStoriesByDomainParams) ([]*Story, error) {
+	rows, err := db.QueryContext(ctx, listStoriesByDomain, arg.Domain, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*Story{}
+	for rows.Next() {
+		var i Story
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.URL,
+			&i.Text,
+			&i.Score,
+			&i.By,
+			&i.Time,
+			&i.Descendants,
+			&i.Type,
+			&i.FetchedAt,
+			&i.Rank,
+			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listStoriesByRank = `-- name: ListStoriesByRank :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE rank IS NOT NULL
+AND (CAST(?1 AS TEXT) IS NULL OR domain = ?1)
 ORDER BY rank ASC
-LIMIT ? OFFSET ?
+LIMIT ?3 OFFSET ?2
 `
 
 type ListStoriesByRankParams struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Domain     *string `json:"domain"`
+	Skip       int     `json:"skip"`
+	MaxStories int     `json:"max_stories"`
 }
 
 func (q *Queries) ListStoriesByRank(ctx context.Context, db DBTX, arg ListStoriesByRankParams) ([]*Story, error) {
-	rows, err := db.QueryContext(ctx, listStoriesByRank, arg.Limit, arg.Offset)
+	rows, err := db.QueryContext(ctx, listStoriesByRank, arg.Domain, arg.Skip, arg.MaxStories)
 	if err != nil {
 		return nil, err
 	}
@@ -182,6 +427,8 @@ func (q *Queries) ListStoriesByRank(ctx context.Context, db DBTX, arg ListStorie
 			&i.FetchedAt,
 			&i.Rank,
 			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
 		); err != nil {
 			return nil, err
 		}
@@ -197,7 +444,7 @@ func (q *Queries) ListStoriesByRank(ctx context.Context, db DBTX, arg ListStorie
 }
 
 const listStoriesByTimeRange = `-- name: ListStoriesByTimeRange :many
-SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead
+SELECT id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain
 FROM stories WHERE time >= ? AND time < ?
 ORDER BY time DESC
 `
@@ -229,6 +476,8 @@ func (q *Queries) ListStoriesByTimeRange(ctx context.Context, db DBTX, arg ListS
 			&i.FetchedAt,
 			&i.Rank,
 			&i.Dead,
+			&i.CanonicalURL,
+			&i.Domain,
 		); err != nil {
 			return nil, err
 		}
@@ -258,11 +507,17 @@ const oldOffPageStoryIDs = `-- name: OldOffPageStoryIDs :many
 SELECT s.id FROM stories s
 WHERE s.rank IS NULL
 AND s.fetched_at < ?
+AND s.time < ?
 AND NOT EXISTS (SELECT 1 FROM rankings r WHERE r.story_id = s.id)
 `
 
-func (q *Queries) OldOffPageStoryIDs(ctx context.Context, db DBTX, fetchedAt int64) ([]int, error) {
-	rows, err := db.QueryContext(ctx, oldOffPageStoryIDs, fetchedAt)
+type OldOffPageStoryIDsParams struct {
+	FetchedAt int64 `json:"fetched_at"`
+	Time      int64 `json:"time"`
+}
+
+func (q *Queries) OldOffPageStoryIDs(ctx context.Context, db DBTX, arg OldOffPageStoryIDsParams) ([]int, error) {
+	rows, err := db.QueryContext(ctx, oldOffPageStoryIDs, arg.FetchedAt, arg.Time)
 	if err != nil {
 		return nil, err
 	}
@@ -298,6 +553,21 @@ func (q *Queries) SetRank(ctx context.Context, db DBTX, arg SetRankParams) error
 	return err
 }
 
+const setStoryResolvedURL = `-- name: SetStoryResolvedURL :exec
+UPDATE stories SET canonical_url = ?, domain = ? WHERE id = ?
+`
+
+type SetStoryResolvedURLParams struct {
+	CanonicalURL *string `json:"canonical_url"`
+	Domain       *string `json:"domain"`
+	ID           int     `json:"id"`
+}
+
+func (q *Queries) SetStoryResolvedURL(ctx context.Context, db DBTX, arg SetStoryResolvedURLParams) error {
+	_, err := db.ExecContext(ctx, setStoryResolvedURL, arg.CanonicalURL, arg.Domain, arg.ID)
+	return err
+}
+
 const storyExists = `-- name: StoryExists :one
 SELECT COUNT(*) FROM stories WHERE id = ?
 `
@@ -310,30 +580,35 @@ func (q *Queries) StoryExists(ctx context.Context, db DBTX, id int) (int, error)
 }
 
 const upsertStory = `-- name: UpsertStory :exec
-INSERT INTO stories (id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO stories (id, title, url, text, score, by, time, descendants, type, fetched_at, rank, dead, canonical_url, domain)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(id) DO UPDATE SET
     title=excluded.title, url=excluded.url, text=excluded.text,
     score=excluded.score, by=excluded.by, time=excluded.time,
     descendants=excluded.descendants, type=excluded.type,
     fetched_at=excluded.fetched_at,
     rank=COALESCE(excluded.rank, stories.rank),
-    dead=excluded.dead
+    dead=excluded.dead,
+    -- keep a resolved shortener target until the URL itself changes
+    canonical_url=CASE WHEN stories.url IS excluded.url THEN COALESCE(stories.canonical_url, excluded.canonical_url) ELSE excluded.canonical_url END,
+    domain=CASE WHEN stories.url IS excluded.url THEN COALESCE(stories.domain, excluded.domain) ELSE excluded.domain END
 `
 
 type UpsertStoryParams struct {
-	ID          int     `json:"id"`
-	Title       string  `json:"title"`
-	URL         *string `json:"url"`
-	Text        *string `json:"text"`
-	Score       int     `json:"score"`
-	By          string  `json:"by"`
-	Time        int64   `json:"time"`
-	Descendants int     `json:"descendants"`
-	Type        string  `json:"type"`
-	FetchedAt   int64   `json:"fetched_at"`
-	Rank        *int    `json:"rank"`
-	Dead        bool    `json:"dead"`
+	ID           int     `json:"id"`
+	Title        string  `json:"title"`
+	URL          *string `json:"url"`
+	Text         *string `json:"text"`
+	Score        int     `json:"score"`
+	By           string  `json:"by"`
+	Time         int64   `json:"time"`
+	Descendants  int     `json:"descendants"`
+	Type         string  `json:"type"`
+	FetchedAt    int64   `json:"fetched_at"`
+	Rank         *int    `json:"rank"`
+	Dead         bool    `json:"dead"`
+	CanonicalURL *string `json:"canonical_url"`
+	Domain       *string `json:"domain"`
 }
 
 func (q *Queries) UpsertStory(ctx context.Context, db DBTX, arg UpsertStoryParams) error {
@@ -350,6 +625,8 @@ func (q *Queries) UpsertStory(ctx context.Context, db DBTX, arg UpsertStoryParam
 		arg.FetchedAt,
 		arg.Rank,
 		arg.Dead,
+		arg.CanonicalURL,
+		arg.Domain,
 	)
 	return err
 }
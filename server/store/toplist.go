@@ -47,6 +47,13 @@ func (t *TopList) Page(page, pageSize int) ([]int, int) {
 	return result, total
 }
 
+// IDs returns a copy of the whole list.
+func (t *TopList) IDs() []int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]int(nil), t.ids...)
+}
+
 // Len returns the number of IDs in the list.
 func (t *TopList) Len() int {
 	t.mu.RLock()
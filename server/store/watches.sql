@@ -0,0 +1,57 @@
+-- name: ListWatches :many
+SELECT id, user_sub, kind, value, created_at FROM watches
+WHERE user_sub = ?
+ORDER BY kind, value;
+
+-- name: ListAllWatches :many
+SELECT id, user_sub, kind, value, created_at FROM watches
+ORDER BY id;
+
+-- name: CreateWatch :one
+INSERT INTO watches (user_sub, kind, value, created_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (user_sub, kind, value) DO NOTHING
+RETURNING id, user_sub, kind, value, created_at;
+
+-- name: DeleteWatch :execrows
+DELETE FROM watches WHERE id = ? AND user_sub = ?;
+
+-- name: CreateAlert :one
+-- Returns no row if the watch already alerted on this item.
+INSERT INTO alerts (user_sub, watch_id, story_id, comment_id, item_id, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (watch_id, item_id) DO NOTHING
+RETURNING id;
+
+-- name: GetAlert :one
+SELECT a.id, a.watch_id, w.kind AS watch_kind, w.value AS watch_value,
+       a.story_id, s.title AS story_title, s.by AS story_by,
+       a.comment_id, c.by AS comment_by, a.created_at, a.read_at
+FROM alerts a
+JOIN watches w ON w.id = a.watch_id
+JOIN stories s ON s.id = a.story_id
+LEFT JOIN comments c ON c.id = a.comment_id
+WHERE a.id = ?;
+
+-- name: ListAlerts :many
+SELECT a.id, a.watch_id, w.kind AS watch_kind, w.value AS watch_value,
+       a.story_id, s.title AS story_title, s.by AS story_by,
+       a.comment_id, c.by AS comment_by, a.created_at, a.read_at
+FROM alerts a
+JOIN watches w ON w.id = a.watch_id
+JOIN stories s ON s.id = a.story_id
+LEFT JOIN comments c ON c.id = a.comment_id
+WHERE a.user_sub = sqlc.arg(user_sub)
+  AND (CAST(sqlc.arg(unread_only) AS BOOLEAN) = 0 OR a.read_at IS NULL)
+ORDER BY a.id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountAlerts :one
+SELECT COUNT(*) AS total, CAST(COALESCE(SUM(read_at IS NULL), 0) AS INTEGER) AS unread
+FROM alerts WHERE user_sub = ?;
+
+-- name: MarkAlertsRead :execrows
+UPDATE alerts SET read_at = sqlc.arg(read_at)
+WHERE user_sub = sqlc.arg(user_sub) AND read_at IS NULL AND id IN (sqlc.slice(ids));
+
+-- name: MarkAllAlertsRead :execrows
+UPDATE alerts SET read_at = ? WHERE user_sub = ? AND read_at IS NULL;
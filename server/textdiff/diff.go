@@ -0,0 +1,231 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Op is the kind of an Edit.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is one step in transforming a into b.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxEditDistance bounds the work Diff does. Inputs further apart than this
+// are reported as a wholesale replacement instead of a minimal script.
+const maxEditDistance = 2000
+
+// maxParagraphEdits bounds the paragraph-level diff in ChangedWords. A
+// document with more paragraphs changed than this has materially changed.
+const maxParagraphEdits = 200
+
+// Diff returns an edit script from a to b using Myers' O((N+M)D) algorithm,
+// so near-identical documents diff quickly regardless of length.
+func Diff(a, b []string) []Edit {
+	if edits, ok := diff(a, b, maxEditDistance); ok {
+		return edits
+	}
+	return replaceAll(a, b)
+}
+
+// diff returns an edit script from a to b, or false if they are more than
+// maxD edits apart.
+func diff(a, b []string, maxD int) ([]Edit, bool) {
+	var trace [][]int
+	d, ok := myers(a, b, maxD, &trace)
+	if !ok {
+		return nil, false
+	}
+	return backtrack(a, b, trace, d), true
+}
+
+// myers returns the number of insertions and deletions between a and b, or
+// false if it exceeds maxD. If trace is non-nil, the diagonals -d..d are
+// saved to it before each step d so the path can be recovered; that takes
+// O(D²) memory, so callers that only need the distance pass nil.
+func myers(a, b []string, maxD int, trace *[][]int) (int, bool) {
+	n, m := len(a), len(b)
+	maxD = min(n+m, maxD)
+
+	// v[k+offset] holds the furthest x reached on diagonal k.
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	for d := 0; d <= maxD; d++ {
+		if trace != nil {
+			*trace = append(*trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset] // move down: insertion
+			} else {
+				x = v[k-1+offset] + 1 // move right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				return d, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func backtrack(a, b []string, trace [][]int, d int) []Edit {
+	var edits []Edit
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d] // diagonals -d..d at index k+d
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Op: Equal, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, Edit{Op: Insert, Text: b[y]})
+		} else {
+			x--
+			edits = append(edits, Edit{Op: Delete, Text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, Edit{Op: Equal, Text: a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, s := range a {
+		edits = append(edits, Edit{Op: Delete, Text: s})
+	}
+	for _, s := range b {
+		edits = append(edits, Edit{Op: Insert, Text: s})
+	}
+	return edits
+}
+
+// ChangedWords counts words inserted or deleted between two documents given
+// as paragraphs, stopping at limit. Paragraphs are matched first and only
+// the words of changed runs are compared, so the cost follows the size of
+// the edit rather than of the documents.
+func ChangedWords(a, b []string, limit int) int {
+	paras, ok := diff(a, b, maxParagraphEdits)
+	if !ok {
+		return limit
+	}
+
+	changed := 0
+	var deleted, inserted []string
+	for i := 0; i <= len(paras); i++ {
+		if i < len(paras) && paras[i].Op != Equal {
+			words := strings.Fields(paras[i].Text)
+			if paras[i].Op == Delete {
+				deleted = append(deleted, words...)
+			} else {
+				inserted = append(inserted, words...)
+			}
+			continue
+		}
+		if len(deleted)+len(inserted) == 0 {
+			continue
+		}
+		d, ok := myers(deleted, inserted, limit-changed, nil)
+		if !ok {
+			return limit
+		}
+		if changed += d; changed >= limit {
+			return limit
+		}
+		deleted, inserted = deleted[:0], inserted[:0]
+	}
+	return changed
+}
+
+// blockElements end a paragraph when splitting HTML into text blocks.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true,
+	atom.Dt: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Summary: true, atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true,
+	atom.Ul: true,
+}
+
+// Paragraphs extracts the visible text of an HTML fragment as a list of
+// whitespace-normalized blocks, one per paragraph-level element.
+func Paragraphs(htmlContent string) []string {
+	var paras []string
+	var cur strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.FieldsFunc(cur.String(), unicode.IsSpace), " "); text != "" {
+			paras = append(paras, text)
+		}
+		cur.Reset()
+	}
+
+	z := html.NewTokenizer(strings.NewReader(htmlContent))
+	skip := 0 // depth inside script/style, which have no visible text
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			flush()
+			return paras
+		case html.TextToken:
+			if skip == 0 {
+				cur.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			tt := z.Token()
+			switch tt.DataAtom {
+			case atom.Script, atom.Style:
+				if tt.Type == html.StartTagToken {
+					skip++
+				} else if tt.Type == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			default:
+				if blockElements[tt.DataAtom] {
+					flush()
+				}
+			}
+		}
+	}
+}
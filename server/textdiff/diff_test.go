@@ -0,0 +1,109 @@
+package textdiff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides of an edit script.
+func apply(edits []Edit) (a, b []string) {
+	for _, e := range edits {
+		if e.Op != Insert {
+			a = append(a, e.Text)
+		}
+		if e.Op != Delete {
+			b = append(b, e.Text)
+		}
+	}
+	return a, b
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"a b c", "", 3},
+		{"", "a b c", 3},
+		{"a b c", "a x c", 2},
+		{"a b c d", "b c d e", 2},
+		{"the quick brown fox", "the slow brown dog", 4},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		edits := Diff(a, b)
+		gotA, gotB := apply(edits)
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Errorf("Diff(%q, %q) = %v does not rebuild its inputs", tt.a, tt.b, edits)
+		}
+		changes := 0
+		for _, e := range edits {
+			if e.Op != Equal {
+				changes++
+			}
+		}
+		if changes != tt.changes {
+			t.Errorf("Diff(%q, %q) has %d changes, want %d", tt.a, tt.b, changes, tt.changes)
+		}
+	}
+}
+
+// words returns n distinct words with the given prefix.
+func words(prefix string, n int) string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return strings.Join(w, " ")
+}
+
+func TestChangedWords(t *testing.T) {
+	long := words("w", 200)
+	tests := []struct {
+		name  string
+		a, b  []string
+		limit int
+		want  int
+	}{
+		{"identical", []string{"a b", "c d"}, []string{"a b", "c d"}, 5, 0},
+		{"reflowed", []string{"a b c d"}, []string{"a b", "c d"}, 5, 0},
+		{"one word", []string{long, "a b c"}, []string{long, "a x c"}, 5, 2},
+		{"paragraph added", []string{long}, []string{long, "new words here"}, 5, 3},
+		{"edits in separate paragraphs", []string{"a b", long, "c d"}, []string{"a x", long, "c y"}, 10, 4},
+		{"capped", []string{"a b c"}, []string{"x y z"}, 5, 5},
+		{"capped across paragraphs", []string{"a b", long, "c d"}, []string{"x y", long, "z w"}, 5, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChangedWords(tt.a, tt.b, tt.limit); got != tt.want {
+				t.Errorf("ChangedWords = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedWordsRewrite(t *testing.T) {
+	// A rewritten long article stops at the limit instead of diffing every
+	// word of both versions.
+	var a, b []string
+	for i := 0; i < 500; i++ {
+		a = append(a, words(fmt.Sprintf("a%d-", i), 100))
+		b = append(b, words(fmt.Sprintf("b%d-", i), 100))
+	}
+	if got := ChangedWords(a, b, 5); got != 5 {
+		t.Errorf("ChangedWords = %d, want 5", got)
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	got := Paragraphs(`<h1>Title</h1><p>One  <b>two</b>
+three</p><script>var x = 1;</script><ul><li>a</li><li>b</li></ul>tail`)
+	want := []string{"Title", "One two three", "a", "b", "tail"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Paragraphs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+// Package urlnorm canonicalizes submitted URLs so that different spellings of
+// the same link compare equal.
+package urlnorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrUnsupported is returned for URLs that aren't absolute http(s) links.
+var ErrUnsupported = errors.New("unsupported url")
+
+// trackingParams are query parameters that identify where a click came from
+// rather than what was linked to.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "mkt_tok": true,
+	"_hsenc": true, "_hsmi": true, "ref_src": true, "ref_url": true,
+	"smid": true, "cmpid": true,
+}
+
+// siteTrackingParams are tracking parameters only on some sites, keyed by
+// registrable domain, since elsewhere the same names select content (such as
+// GitHub's ?ref=<branch>).
+var siteTrackingParams = map[string]map[string]bool{
+	"youtube.com": {"si": true},
+	"spotify.com": {"si": true},
+}
+
+// hostPrefixes are stripped from hostnames: www and mobile variants serve
+// the same pages.
+var hostPrefixes = []string{"www.", "m.", "mobile."}
+
+// shorteners are hosts whose links only redirect elsewhere.
+var shorteners = map[string]bool{
+	"t.co": true, "bit.ly": true, "goo.gl": true, "tinyurl.com": true,
+	"ow.ly": true, "buff.ly": true, "is.gd": true, "lnkd.in": true,
+	"dlvr.it": true, "trib.al": true, "rebrand.ly": true, "shorturl.at": true,
+}
+
+// Canonicalize returns the canonical form of an http(s) URL: https scheme,
+// lowercase host without www or mobile prefixes and default ports, no
+// trailing slash, fragment or tracking parameters, and remaining query
+// parameters sorted. youtu.be links are expanded to youtube.com; other
+// shortened links are left for Resolve.
+func Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: %q", ErrUnsupported, raw)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, p := range hostPrefixes {
+		if trimmed, ok := strings.CutPrefix(host, p); ok && strings.Contains(trimmed, ".") {
+			host = trimmed
+			break
+		}
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	query := u.Query()
+	if host == "youtu.be" && len(u.Path) > 1 {
+		query.Set("v", strings.TrimPrefix(u.Path, "/"))
+		host, u.Path = "youtube.com", "/watch"
+	}
+	siteParams := siteTrackingParams[Domain(host)]
+	for key := range query {
+		lower := strings.ToLower(key)
+		if trackingParams[lower] || siteParams[lower] || strings.HasPrefix(lower, "utm_") {
+			query.Del(key)
+		}
+	}
+
+	out := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     strings.TrimRight(u.Path, "/"),
+		RawQuery: query.Encode(),
+	}
+	if out.Path == "" {
+		out.Path = "/"
+	}
+	return out.String(), nil
+}
+
+// Host returns the host of a canonical URL, or "" if it can't be parsed.
+func Host(canonical string) string {
+	u, err := url.Parse(canonical)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Domain returns the registrable domain (eTLD+1) of a host or canonical
+// URL, such as "bbc.co.uk" for "https://news.bbc.co.uk/...". Hosts that are
+// IP addresses or public suffixes themselves are returned whole.
+func Domain(hostOrURL string) string {
+	host := hostOrURL
+	if strings.Contains(hostOrURL, "://") {
+		u, err := url.Parse(hostOrURL)
+		if err != nil {
+			return ""
+		}
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(hostOrURL); err == nil {
+		host = h
+	}
+	host = strings.Trim(strings.ToLower(host), "[].")
+	if host == "" || net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// IsShortened reports whether a canonical URL points at a known link shortener.
+func IsShortened(canonical string) bool {
+	return shorteners[Host(canonical)]
+}
+
+// Resolve follows a shortened link's redirects with client and returns the
+// canonical form of the final URL. Callers should pass a client that refuses
+// non-public addresses, such as one from safehttp.NewClient.
+func Resolve(ctx context.Context, client *http.Client, raw string) (string, error) {
+	final, err := follow(ctx, client, http.MethodHead, raw)
+	if err != nil {
+		// Some shorteners don't answer HEAD.
+		final, err = follow(ctx, client, http.MethodGet, raw)
+	}
+	if err != nil {
+		return "", err
+	}
+	return Canonicalize(final)
+}
+
+func follow(ctx context.Context, client *http.Client, method, raw string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, raw, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("resolving %s: status %d", raw, resp.StatusCode)
+	}
+	return resp.Request.URL.String(), nil
+}
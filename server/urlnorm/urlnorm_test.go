@@ -0,0 +1,38 @@
+package urlnorm
+
+import "testing"
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://www.Example.com:80/a/?b=2&a=1#frag", "https://example.com/a?a=1&b=2"},
+		{"https://m.example.com", "https://example.com/"},
+		{"https://example.com:8443/x", "https://example.com:8443/x"},
+		{"https://example.com/a?utm_source=hn&UTM_Medium=x&fbclid=1&id=7", "https://example.com/a?id=7"},
+		{"https://twitter.com/a/status/1?ref_src=twsrc", "https://twitter.com/a/status/1"},
+		{"https://youtu.be/abc123?si=share", "https://youtube.com/watch?v=abc123"},
+		{"https://www.youtube.com/watch?v=abc123&si=share", "https://youtube.com/watch?v=abc123"},
+		{"https://open.spotify.com/track/1?si=xyz", "https://open.spotify.com/track/1"},
+		// ref and si select content on other sites.
+		{"https://github.com/o/r/blob/main/f.go?ref=v2", "https://github.com/o/r/blob/main/f.go?ref=v2"},
+		{"https://example.com/item?si=42", "https://example.com/item?si=42"},
+	}
+	for _, tt := range tests {
+		got, err := Canonicalize(tt.in)
+		if err != nil {
+			t.Errorf("Canonicalize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Canonicalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"ftp://example.com/", "mailto:a@example.com", "/relative"} {
+		if _, err := Canonicalize(in); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded", in)
+		}
+	}
+}
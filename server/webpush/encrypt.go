@@ -0,0 +1,107 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// recordSize is the aes128gcm record size. Payloads are sent as a single
+	// record, so it bounds the ciphertext.
+	recordSize = 4096
+	// headerSize is the salt, record size, key ID length and the 65-byte
+	// key ID (the sender's ephemeral public key).
+	headerSize = 16 + 4 + 1 + 65
+	// MaxPayload is the largest payload that fits one record, after the
+	// padding delimiter and the GCM tag.
+	MaxPayload = recordSize - headerSize - 1 - 16
+)
+
+// ErrPayloadTooLarge is returned for payloads over MaxPayload.
+var ErrPayloadTooLarge = errors.New("webpush: payload too large")
+
+// encrypt seals payload for a subscription per RFC 8291, in the aes128gcm
+// content coding of RFC 8188.
+func encrypt(payload, uaPublic, authSecret []byte) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	curve := ecdh.P256()
+	ua, err := curve.NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid p256dh key: %w", err)
+	}
+	if len(authSecret) != 16 {
+		return nil, errors.New("webpush: auth secret must be 16 bytes")
+	}
+
+	as, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return seal(payload, ua, authSecret, as, salt)
+}
+
+// seal encrypts payload with the sender's ephemeral key as and salt.
+func seal(payload []byte, ua *ecdh.PublicKey, authSecret []byte, as *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	shared, err := as.ECDH(ua)
+	if err != nil {
+		return nil, err
+	}
+	uaPublic, asPublic := ua.Bytes(), as.PublicKey().Bytes()
+
+	// Combine the ECDH secret with the subscription's auth secret, bound to
+	// both public keys.
+	prkKey, err := hkdf.Extract(sha256.New, shared, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, headerSize, headerSize+len(payload)+1+gcm.Overhead())
+	copy(out, salt)
+	binary.BigEndian.PutUint32(out[16:], recordSize)
+	out[20] = byte(len(asPublic))
+	copy(out[21:], asPublic)
+
+	// A single, final record: the payload followed by the 0x02 delimiter.
+	plaintext := append(append(make([]byte, 0, len(payload)+1), payload...), 0x02)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
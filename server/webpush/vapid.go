@@ -0,0 +1,92 @@
+package webpush
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// vapidExpiry is how long a VAPID token is valid. Push services reject
+// tokens that expire more than 24 hours ahead.
+const vapidExpiry = 12 * time.Hour
+
+var b64 = base64.RawURLEncoding
+
+// VAPIDKeys is the application server's P-256 key pair (RFC 8292), which
+// identifies it to push services.
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateVAPIDKeys creates a new key pair.
+func GenerateVAPIDKeys() (*VAPIDKeys, error) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &VAPIDKeys{private: k}, nil
+}
+
+// ParseVAPIDKeys restores a key pair from its PrivateKey form.
+func ParseVAPIDKeys(private string) (*VAPIDKeys, error) {
+	raw, err := b64.DecodeString(private)
+	if err != nil {
+		return nil, fmt.Errorf("decode vapid key: %w", err)
+	}
+	k, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse vapid key: %w", err)
+	}
+	return &VAPIDKeys{private: k}, nil
+}
+
+// PrivateKey returns the private scalar, base64url-encoded.
+func (k *VAPIDKeys) PrivateKey() string {
+	raw, _ := k.private.Bytes()
+	return b64.EncodeToString(raw)
+}
+
+// PublicKey returns the uncompressed public point, base64url-encoded. This
+// is the applicationServerKey browsers subscribe with.
+func (k *VAPIDKeys) PublicKey() string {
+	raw, _ := k.private.PublicKey.Bytes()
+	return b64.EncodeToString(raw)
+}
+
+// authorization returns the Authorization header value for a push to
+// endpoint: an ES256 JWT scoped to the endpoint's origin, and the public key.
+func (k *VAPIDKeys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims := map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidExpiry).Unix(),
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", unsigned, b64.EncodeToString(sig), k.PublicKey()), nil
+}
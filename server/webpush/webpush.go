@@ -0,0 +1,139 @@
+// Package webpush delivers encrypted Web Push messages (RFC 8030) with VAPID
+// authentication (RFC 8292) and aes128gcm payload encryption (RFC 8291).
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrGone is returned when the push service reports that a subscription has
+// expired or been unsubscribed; it should be deleted.
+var ErrGone = errors.New("webpush: subscription gone")
+
+// StatusError is a push service's rejection of a message.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webpush: push service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the message may be accepted if retried later.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Subscription is a browser's PushSubscription: the push service endpoint
+// and the keys its messages are encrypted to, base64url-encoded.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// Validate checks that the endpoint is an https URL, or http on a loopback
+// host as browsers allow for local development, and the keys decode to a
+// P-256 point and a 16-byte secret.
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Host == "" {
+		return errors.New("webpush: invalid endpoint URL")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u.Hostname())) {
+		return errors.New("webpush: endpoint must be an https URL")
+	}
+	if _, err := s.keys(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+type subscriptionKeys struct {
+	p256dh, auth []byte
+}
+
+func (s *Subscription) keys() (*subscriptionKeys, error) {
+	p256dh, err := b64.DecodeString(s.P256dh)
+	if err != nil || len(p256dh) != 65 {
+		return nil, errors.New("webpush: p256dh must be an uncompressed P-256 point")
+	}
+	auth, err := b64.DecodeString(s.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("webpush: auth must be 16 bytes")
+	}
+	return &subscriptionKeys{p256dh: p256dh, auth: auth}, nil
+}
+
+// Sender sends messages to push services.
+type Sender struct {
+	client  *http.Client
+	keys    *VAPIDKeys
+	subject string
+}
+
+// NewSender creates a Sender that signs requests with keys. subject is a
+// mailto: or https: contact for the push service operator, or empty.
+func NewSender(client *http.Client, keys *VAPIDKeys, subject string) *Sender {
+	return &Sender{client: client, keys: keys, subject: subject}
+}
+
+// Send encrypts payload for sub and posts it to the subscription's push
+// service, which holds it for up to ttl while the browser is offline.
+func (s *Sender) Send(ctx context.Context, sub *Subscription, payload []byte, ttl time.Duration) error {
+	keys, err := sub.keys()
+	if err != nil {
+		return err
+	}
+	body, err := encrypt(payload, keys.p256dh, keys.auth)
+	if err != nil {
+		return err
+	}
+	auth, err := s.keys.authorization(sub.Endpoint, s.subject, time.Now())
+	if err != nil {
+		return fmt.Errorf("webpush: sign request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webpush: create request: %w", err)
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl/time.Second)))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webpush: send: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{StatusCode: resp.StatusCode, Body: string(msg)}
+}
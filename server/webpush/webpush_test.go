@@ -0,0 +1,367 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := b64.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+// decrypt is the user agent's side of RFC 8291.
+func decrypt(t *testing.T, msg []byte, ua *ecdh.PrivateKey, authSecret []byte) []byte {
+	t.Helper()
+	if len(msg) < headerSize {
+		t.Fatalf("message too short: %d bytes", len(msg))
+	}
+	salt := msg[:16]
+	if rs := binary.BigEndian.Uint32(msg[16:20]); rs != recordSize {
+		t.Errorf("record size = %d, want %d", rs, recordSize)
+	}
+	idLen := int(msg[20])
+	asPublic := msg[21 : 21+idLen]
+	ciphertext := msg[21+idLen:]
+
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatalf("parse sender key: %v", err)
+	}
+	shared, err := ua.ECDH(as)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prkKey, _ := hkdf.Extract(sha256.New, shared, authSecret)
+	ikm, _ := hkdf.Expand(sha256.New, prkKey, "WebPush: info\x00"+string(ua.PublicKey().Bytes())+string(asPublic), 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	// Strip padding back to the final-record delimiter.
+	i := len(plaintext) - 1
+	for i >= 0 && plaintext[i] == 0 {
+		i--
+	}
+	if i < 0 || plaintext[i] != 0x02 {
+		t.Fatalf("missing final record delimiter")
+	}
+	return plaintext[:i]
+}
+
+// RFC 8291 section 5 example.
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcMessage    = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func TestSealRFC8291Vector(t *testing.T) {
+	curve := ecdh.P256()
+	as, err := curve.NewPrivateKey(mustDecode(t, rfcASPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ua, err := curve.NewPublicKey(mustDecode(t, rfcUAPublic))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := seal([]byte(rfcPlaintext), ua, mustDecode(t, rfcAuthSecret), as, mustDecode(t, rfcSalt))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if b64.EncodeToString(got) != rfcMessage {
+		t.Errorf("seal =\n%s\nwant\n%s", b64.EncodeToString(got), rfcMessage)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	ua, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := []byte("0123456789abcdef")
+
+	for _, payload := range [][]byte{{}, []byte(`{"title":"hi"}`), make([]byte, MaxPayload)} {
+		msg, err := encrypt(payload, ua.PublicKey().Bytes(), auth)
+		if err != nil {
+			t.Fatalf("encrypt %d bytes: %v", len(payload), err)
+		}
+		if len(msg) > recordSize {
+			t.Errorf("%d-byte payload encrypted to %d bytes, over the record size", len(payload), len(msg))
+		}
+		if got := decrypt(t, msg, ua, auth); string(got) != string(payload) {
+			t.Errorf("round trip of %d bytes returned %d bytes", len(payload), len(got))
+		}
+	}
+
+	if _, err := encrypt(make([]byte, MaxPayload+1), ua.PublicKey().Bytes(), auth); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("oversized payload: error = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestDecryptRFC8291Vector(t *testing.T) {
+	ua, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decrypt(t, mustDecode(t, rfcMessage), ua, mustDecode(t, rfcAuthSecret)); string(got) != rfcPlaintext {
+		t.Errorf("decrypt = %q, want %q", got, rfcPlaintext)
+	}
+}
+
+// verifyVAPID checks an Authorization header per RFC 8292 and returns the
+// JWT claims.
+func verifyVAPID(t *testing.T, header, publicKey string) map[string]interface{} {
+	t.Helper()
+	rest, ok := strings.CutPrefix(header, "vapid ")
+	if !ok {
+		t.Fatalf("Authorization = %q, want vapid scheme", header)
+	}
+	params := make(map[string]string)
+	for _, p := range strings.Split(rest, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		params[k] = v
+	}
+	if params["k"] != publicKey {
+		t.Errorf("k = %q, want %q", params["k"], publicKey)
+	}
+
+	parts := strings.Split(params["t"], ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts", len(parts))
+	}
+	var jwtHeader struct{ Typ, Alg string }
+	if err := json.Unmarshal(mustDecode(t, parts[0]), &jwtHeader); err != nil || jwtHeader.Alg != "ES256" || jwtHeader.Typ != "JWT" {
+		t.Errorf("JWT header = %s", mustDecode(t, parts[0]))
+	}
+
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), mustDecode(t, params["k"]))
+	if err != nil {
+		t.Fatalf("parse k: %v", err)
+	}
+	sig := mustDecode(t, parts[2])
+	if len(sig) != 64 {
+		t.Fatalf("signature is %d bytes, want 64", len(sig))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Error("JWT signature does not verify")
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(mustDecode(t, parts[1]), &claims); err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	return claims
+}
+
+func TestVAPIDAuthorization(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	header, err := keys.authorization("https://push.example.net:8443/send/abc?x=1", "mailto:ops@example.com", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := verifyVAPID(t, header, keys.PublicKey())
+	if claims["aud"] != "https://push.example.net:8443" {
+		t.Errorf("aud = %v, want the endpoint origin", claims["aud"])
+	}
+	if exp, _ := claims["exp"].(float64); int64(exp) != now.Add(vapidExpiry).Unix() {
+		t.Errorf("exp = %v, want %d", claims["exp"], now.Add(vapidExpiry).Unix())
+	}
+	if claims["sub"] != "mailto:ops@example.com" {
+		t.Errorf("sub = %v", claims["sub"])
+	}
+
+	header, err = keys.authorization("https://push.example.net/x", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := verifyVAPID(t, header, keys.PublicKey())["sub"]; ok {
+		t.Error("sub claim set for an empty subject")
+	}
+}
+
+func TestParseVAPIDKeys(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseVAPIDKeys(keys.PrivateKey())
+	if err != nil {
+		t.Fatalf("ParseVAPIDKeys: %v", err)
+	}
+	if parsed.PublicKey() != keys.PublicKey() {
+		t.Error("parsed keys have a different public key")
+	}
+}
+
+// testSubscription returns a subscription to endpoint with its private key
+// and auth secret.
+func testSubscription(t *testing.T, endpoint string) (*Subscription, *ecdh.PrivateKey, []byte) {
+	t.Helper()
+	ua, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := []byte("fedcba9876543210")
+	return &Subscription{
+		Endpoint: endpoint,
+		P256dh:   b64.EncodeToString(ua.PublicKey().Bytes()),
+		Auth:     b64.EncodeToString(auth),
+	}, ua, auth
+}
+
+func TestSend(t *testing.T) {
+	keys, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []byte
+	var req *http.Request
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		w.Write([]byte("push service says no"))
+	}))
+	defer srv.Close()
+
+	sub, ua, auth := testSubscription(t, srv.URL+"/push/abc")
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("Validate loopback endpoint: %v", err)
+	}
+	sender := NewSender(srv.Client(), keys, "mailto:ops@example.com")
+
+	status = http.StatusCreated
+	if err := sender.Send(context.Background(), sub, []byte("hello"), time.Hour); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if req.Method != http.MethodPost || req.URL.Path != "/push/abc" {
+		t.Errorf("request = %s %s", req.Method, req.URL.Path)
+	}
+	for k, want := range map[string]string{
+		"Content-Encoding": "aes128gcm",
+		"Content-Type":     "application/octet-stream",
+		"TTL":              "3600",
+	} {
+		if got := req.Header.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if aud := verifyVAPID(t, req.Header.Get("Authorization"), keys.PublicKey())["aud"]; aud != srv.URL {
+		t.Errorf("aud = %v, want %s", aud, srv.URL)
+	}
+	if got := decrypt(t, received, ua, auth); string(got) != "hello" {
+		t.Errorf("push service received %q, want %q", got, "hello")
+	}
+
+	tests := []struct {
+		status    int
+		gone      bool
+		temporary bool
+	}{
+		{http.StatusNotFound, true, false},
+		{http.StatusGone, true, false},
+		{http.StatusTooManyRequests, false, true},
+		{http.StatusInternalServerError, false, true},
+		{http.StatusServiceUnavailable, false, true},
+		{http.StatusBadRequest, false, false},
+		{http.StatusRequestEntityTooLarge, false, false},
+	}
+	for _, tt := range tests {
+		status = tt.status
+		err := sender.Send(context.Background(), sub, []byte("hello"), time.Hour)
+		if tt.gone {
+			if !errors.Is(err, ErrGone) {
+				t.Errorf("status %d: error = %v, want ErrGone", tt.status, err)
+			}
+			continue
+		}
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Errorf("status %d: error = %v, want StatusError", tt.status, err)
+			continue
+		}
+		if statusErr.StatusCode != tt.status || statusErr.Temporary() != tt.temporary {
+			t.Errorf("status %d: got status %d, Temporary %v; want Temporary %v",
+				tt.status, statusErr.StatusCode, statusErr.Temporary(), tt.temporary)
+		}
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	sub, _, _ := testSubscription(t, "")
+	tests := []struct {
+		endpoint string
+		ok       bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc", true},
+		{"http://localhost:8080/push", true},
+		{"http://127.0.0.1:8080/push", true},
+		{"http://[::1]/push", true},
+		{"http://push.example.net/push", false},
+		{"ftp://push.example.net/push", false},
+		{"https://", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		s := *sub
+		s.Endpoint = tt.endpoint
+		if err := s.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%q) = %v, want ok %v", tt.endpoint, err, tt.ok)
+		}
+	}
+
+	bad := *sub
+	bad.Endpoint = "https://push.example.net/x"
+	bad.Auth = b64.EncodeToString([]byte("short"))
+	if bad.Validate() == nil {
+		t.Error("Validate accepted a short auth secret")
+	}
+}
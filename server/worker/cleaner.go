@@ -9,13 +9,19 @@ import (
 	"github.com/danielmmetz/hn-client/server/store"
 )
 
+// DefaultRetention is how long stories are kept after leaving the top list.
+// Stories posted within MaxRankingAge are kept regardless, so that every
+// ranking period stays complete.
+const DefaultRetention = 30 * 24 * time.Hour
+
 type Cleaner struct {
-	db *sql.DB
-	q  *store.Queries
+	db        *sql.DB
+	q         *store.Queries
+	retention time.Duration
 }
 
-func NewCleaner(db *sql.DB, q *store.Queries) *Cleaner {
-	return &Cleaner{db: db, q: q}
+func NewCleaner(db *sql.DB, q *store.Queries, retention time.Duration) *Cleaner {
+	return &Cleaner{db: db, q: q, retention: retention}
 }
 
 // Start begins the daily cleanup cycle. It runs until the context is cancelled.
@@ -46,8 +52,11 @@ func (c *Cleaner) Start(ctx context.Context) {
 func (c *Cleaner) cleanup(ctx context.Context) {
 	slog.Info("cleaner: starting daily cleanup")
 
-	cutoff := time.Now().Add(-30 * 24 * time.Hour).Unix()
-	ids, err := c.q.OldOffPageStoryIDs(ctx, c.db, cutoff)
+	now := time.Now()
+	ids, err := c.q.OldOffPageStoryIDs(ctx, c.db, store.OldOffPageStoryIDsParams{
+		FetchedAt: now.Add(-c.retention).Unix(),
+		Time:      now.Add(-MaxRankingAge).Unix(),
+	})
 	if err != nil {
 		slog.Error("cleaner: error finding old stories", "error", err)
 		return
@@ -73,5 +82,11 @@ func (c *Cleaner) cleanup(ctx context.Context) {
 		}
 	}
 
+	if n, err := c.q.DeleteFinishedJobs(ctx, c.db, time.Now().Add(-24*time.Hour).Unix()); err != nil {
+		slog.Error("cleaner: error deleting finished jobs", "error", err)
+	} else if n > 0 {
+		slog.Info("cleaner: deleted finished jobs", "count", n)
+	}
+
 	slog.Info("cleaner: cleanup complete")
 }
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/sse"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// JobRefreshComments re-checks stored comments on stories outside the eager set.
+const JobRefreshComments = "refresh_comments"
+
+const (
+	commentRefreshInterval = 5 * time.Minute
+	commentRefreshBatch    = 500
+)
+
+// commentRefreshTiers sets how often comments of each age are re-checked.
+// HN allows edits and deletions for two hours, so young comments are checked
+// often; after that only moderation (flagging, vouching) changes them.
+var commentRefreshTiers = [3]struct {
+	maxAge, every time.Duration
+}{
+	{2 * time.Hour, 10 * time.Minute},
+	{24 * time.Hour, time.Hour},
+	{7 * 24 * time.Hour, 12 * time.Hour},
+}
+
+// CommentRefresher catches edited, deleted and killed comments on stories
+// that have left the eager set, whose trees the poller no longer re-walks.
+type CommentRefresher struct {
+	fetcher    *Fetcher
+	jobs       *jobs.Queue
+	db         *sql.DB
+	q          *store.Queries
+	broker     *sse.Broker
+	eagerCount int
+}
+
+// NewCommentRefresher creates a CommentRefresher for stories ranked below
+// eagerCount and registers its job handler with queue.
+func NewCommentRefresher(fetcher *Fetcher, queue *jobs.Queue, db *sql.DB, q *store.Queries, broker *sse.Broker, eagerCount int) *CommentRefresher {
+	r := &CommentRefresher{fetcher: fetcher, jobs: queue, db: db, q: q, broker: broker, eagerCount: eagerCount}
+	queue.Handle(JobRefreshComments, r.handle)
+	return r
+}
+
+// Start begins the refresh cycle. It runs until the context is cancelled.
+func (r *CommentRefresher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(commentRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("comment refresher: shutting down")
+				return
+			case <-ticker.C:
+				// A single key means a slow cycle is never queued twice.
+				if _, err := r.jobs.Enqueue(ctx, JobRefreshComments, JobRefreshComments, struct{}{}, jobs.PriorityLazy); err != nil {
+					slog.Error("comment refresher: error queueing job", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *CommentRefresher) handle(ctx context.Context, _ json.RawMessage) error {
+	now := time.Now()
+	since := func(d time.Duration) int64 { return now.Add(-d).Unix() }
+	tiers := commentRefreshTiers
+	ids, err := r.q.ListCommentsDueForRefresh(ctx, r.db, store.ListCommentsDueForRefreshParams{
+		EagerRank:           r.eagerCount,
+		RecentSince:         since(tiers[0].maxAge),
+		RecentCheckedBefore: since(tiers[0].every),
+		DaySince:            since(tiers[1].maxAge),
+		DayCheckedBefore:    since(tiers[1].every),
+		WeekSince:           since(tiers[2].maxAge),
+		WeekCheckedBefore:   since(tiers[2].every),
+		MaxComments:         commentRefreshBatch,
+	})
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	revised, err := r.fetcher.RefreshComments(ctx, ids)
+	slog.Info("comment refresher: cycle complete", "checked", len(ids), "stories_revised", len(revised))
+	for _, storyID := range revised {
+		data, _ := json.Marshal(map[string]interface{}{
+			"story_id":  storyID,
+			"timestamp": time.Now().Unix(),
+		})
+		r.broker.Publish("comments_updated", string(data))
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/danielmmetz/hn-client/server/digest"
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/sanitize"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// JobSendDigest builds and emails one user's digest.
+const JobSendDigest = "send_digest"
+
+const (
+	digestCheckInterval = time.Minute
+	// digestMaxDelay is how late a digest may go out, e.g. after downtime,
+	// before that occurrence is skipped.
+	digestMaxDelay = 6 * time.Hour
+	// digestExcerptLimit and digestCommentLimit bound quoted text, in runes.
+	digestExcerptLimit = 300
+	digestCommentLimit = 400
+)
+
+type digestJob struct {
+	UserSub string `json:"user_sub"`
+}
+
+// Digester emails users the top stories of the day or week on their
+// schedule, with article excerpts and each story's top comment. Stories the
+// user filtered out or hid are left out.
+type Digester struct {
+	ranker    *Ranker
+	mailer    *digest.Mailer
+	publicURL string
+	jobs      *jobs.Queue
+	db        *sql.DB
+	q         *store.Queries
+}
+
+// NewDigester creates a Digester and registers its job handler with queue.
+// mailer may be nil, in which case digests can be previewed but not sent.
+// Story links point at publicURL if set, and at HN otherwise.
+func NewDigester(ranker *Ranker, mailer *digest.Mailer, publicURL string, queue *jobs.Queue, db *sql.DB, q *store.Queries) *Digester {
+	d := &Digester{ranker: ranker, mailer: mailer, publicURL: strings.TrimRight(publicURL, "/"), jobs: queue, db: db, q: q}
+	queue.Handle(JobSendDigest, d.handle)
+	return d
+}
+
+// Start begins checking for due digests. It runs until the context is
+// cancelled, and does nothing without a mailer.
+func (d *Digester) Start(ctx context.Context) {
+	if d.mailer == nil {
+		slog.Info("digester: no SMTP relay configured, email digests disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("digester: shutting down")
+				return
+			case <-ticker.C:
+				d.enqueueDue(ctx)
+			}
+		}
+	}()
+}
+
+func (d *Digester) enqueueDue(ctx context.Context) {
+	users, err := d.q.ListDueDigests(ctx, d.db, time.Now().Unix())
+	if err != nil {
+		slog.Error("digester: error listing due digests", "error", err)
+		return
+	}
+	for _, user := range users {
+		key := JobSendDigest + ":" + user
+		if _, err := d.jobs.Enqueue(ctx, JobSendDigest, key, digestJob{UserSub: user}, jobs.PriorityLazy); err != nil {
+			slog.Error("digester: error queueing digest", "user_sub", user, "error", err)
+		}
+	}
+}
+
+// ScheduleOf returns the schedule stored in settings.
+func ScheduleOf(settings *store.DigestSetting) (digest.Schedule, error) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return digest.Schedule{}, err
+	}
+	return digest.Schedule{
+		Frequency: settings.Frequency,
+		Hour:      settings.SendHour,
+		Weekday:   time.Weekday(settings.Weekday),
+		Location:  loc,
+	}, nil
+}
+
+func (d *Digester) handle(ctx context.Context, payload json.RawMessage) error {
+	var job digestJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+	settings, err := store.Nullable(d.q.GetDigestSettings(ctx, d.db, job.UserSub))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if settings == nil || settings.NextSendAt > now.Unix() {
+		return nil // unsubscribed or already sent
+	}
+	schedule, err := ScheduleOf(settings)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	next := schedule.Next(now).Unix()
+
+	if late := now.Sub(time.Unix(settings.NextSendAt, 0)); late > digestMaxDelay {
+		slog.Warn("digester: skipping late digest", "user_sub", job.UserSub, "late", late.Round(time.Minute))
+		return d.q.SetDigestSchedule(ctx, d.db, store.SetDigestScheduleParams{NextSendAt: next, UserSub: job.UserSub, DueAt: settings.NextSendAt})
+	}
+
+	dg, err := d.Build(ctx, job.UserSub, settings.Frequency, settings.StoryCount, now.In(schedule.Location))
+	if err != nil {
+		return err
+	}
+	html, text, err := digest.Render(dg)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	if err := d.mailer.Send(settings.Email, dg.Title, html, text); err != nil {
+		return err
+	}
+	slog.Info("digester: sent digest", "user_sub", job.UserSub, "stories", len(dg.Stories))
+
+	sent := now.Unix()
+	return d.q.SetDigestSchedule(ctx, d.db, store.SetDigestScheduleParams{NextSendAt: next, LastSentAt: &sent, UserSub: job.UserSub, DueAt: settings.NextSendAt})
+}
+
+// Build assembles a digest of the top count stories for the frequency's
+// period (the last day or week) as of now, minus those userSub filtered out.
+func (d *Digester) Build(ctx context.Context, userSub, frequency string, count int, now time.Time) (*digest.Digest, error) {
+	period, title := PeriodDay, "Your daily Hacker News digest"
+	if frequency == digest.Weekly {
+		period, title = PeriodWeek, "Your weekly Hacker News digest"
+	}
+
+	ranked, err := d.ranker.Ranking(ctx, period, DefaultAlgo(period), now)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := store.LoadStoryFilter(ctx, d.db, d.q, userSub)
+	if err != nil {
+		return nil, err
+	}
+	var stories []*store.Story
+	for _, s := range ranked {
+		if len(stories) == count {
+			break
+		}
+		if !s.Dead && !filter.Hides(s) {
+			stories = append(stories, s)
+		}
+	}
+
+	ids := make([]int, len(stories))
+	for i, s := range stories {
+		ids[i] = s.ID
+	}
+	excerpts := make(map[int]string)
+	if len(ids) > 0 {
+		rows, err := d.q.GetArticleExcerpts(ctx, d.db, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			excerpts[row.StoryID] = truncateRunes(sanitize.PlainText(*row.Excerpt), digestExcerptLimit)
+		}
+	}
+
+	dg := &digest.Digest{Title: title, Date: now}
+	for i, s := range stories {
+		item := digest.Story{
+			Rank: i + 1, Title: s.Title, By: s.By, Score: s.Score, Comments: s.Descendants,
+			CommentsURL: d.storyLink(s.ID), Excerpt: excerpts[s.ID],
+		}
+		item.URL = item.CommentsURL
+		if s.URL != nil {
+			item.URL = *s.URL
+		}
+		if s.Domain != nil {
+			item.Domain = *s.Domain
+		}
+		top, err := store.TopComment(ctx, d.db, d.q, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		if top != nil && !(top.By != nil && filter.Mutes(*top.By)) {
+			item.TopComment = &digest.Comment{Text: truncateRunes(sanitize.PlainText(*top.Text), digestCommentLimit)}
+			if top.By != nil {
+				item.TopComment.By = *top.By
+			}
+		}
+		dg.Stories = append(dg.Stories, item)
+	}
+	return dg, nil
+}
+
+// storyLink is where a digest sends readers for a story's discussion.
+func (d *Digester) storyLink(id int) string {
+	if d.publicURL != "" {
+		return fmt.Sprintf("%s/#/story/%d", d.publicURL, id)
+	}
+	return fmt.Sprintf("https://news.ycombinator.com/item?id=%d", id)
+}
+
+func truncateRunes(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:limit-1])) + "…"
+}
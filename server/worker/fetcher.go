@@ -3,60 +3,51 @@ package worker
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
-	"golang.org/x/sync/singleflight"
-
 	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/jobs"
 	"github.com/danielmmetz/hn-client/server/readability"
+	"github.com/danielmmetz/hn-client/server/safehttp"
+	"github.com/danielmmetz/hn-client/server/sanitize"
 	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/textdiff"
+	"github.com/danielmmetz/hn-client/server/urlnorm"
 )
 
-type Fetcher struct {
-	client     *hn.Client
-	db         *sql.DB
-	q          *store.Queries
-	sfStory    singleflight.Group
-	sfComments singleflight.Group
-	sfArticle  singleflight.Group
-}
-
-func NewFetcher(client *hn.Client, db *sql.DB, q *store.Queries) *Fetcher {
-	return &Fetcher{client: client, db: db, q: q}
-}
+// resolveTimeout bounds following a shortened link's redirects.
+const resolveTimeout = 15 * time.Second
 
-// FetchStorySingleflight fetches a story via singleflight (concurrent callers share one request).
-func (f *Fetcher) FetchStorySingleflight(ctx context.Context, id int) error {
-	_, err, _ := f.sfStory.Do(fmt.Sprintf("story-%d", id), func() (interface{}, error) {
-		return nil, f.FetchStory(ctx, id, nil)
-	})
-	return err
+type Fetcher struct {
+	client    *hn.Client
+	extractor *readability.Extractor
+	db        *sql.DB
+	q         *store.Queries
+	jobs      *jobs.Queue
+	resolver  *http.Client
+	observers []ItemObserver
 }
 
-// FetchStoryWithCommentsSingleflight fetches story+comments via singleflight.
-func (f *Fetcher) FetchStoryWithCommentsSingleflight(ctx context.Context, id int) error {
-	_, err, _ := f.sfStory.Do(fmt.Sprintf("story-comments-%d", id), func() (interface{}, error) {
-		return nil, f.FetchStoryWithComments(ctx, id, nil)
-	})
-	return err
+// ItemObserver is told about stories and comments the first time the Fetcher
+// stores them.
+type ItemObserver interface {
+	NewStory(ctx context.Context, s *store.Story)
+	NewComment(ctx context.Context, storyID int, item *hn.Item)
 }
 
-// ExtractArticleSingleflight extracts an article via singleflight.
-func (f *Fetcher) ExtractArticleSingleflight(ctx context.Context, storyID int, url string) {
-	f.sfArticle.Do(fmt.Sprintf("article-%d", storyID), func() (interface{}, error) {
-		f.ExtractArticle(ctx, storyID, url)
-		return nil, nil
-	})
+// NewFetcher creates a Fetcher and registers its job handlers with queue.
+func NewFetcher(client *hn.Client, extractor *readability.Extractor, db *sql.DB, q *store.Queries, queue *jobs.Queue) *Fetcher {
+	f := &Fetcher{client: client, extractor: extractor, db: db, q: q, jobs: queue, resolver: safehttp.NewClient(resolveTimeout)}
+	f.registerJobs()
+	return f
 }
 
-// FetchCommentsSingleflight fetches comments via singleflight.
-func (f *Fetcher) FetchCommentsSingleflight(ctx context.Context, storyID int, kids []int) error {
-	_, err, _ := f.sfComments.Do(fmt.Sprintf("comments-%d", storyID), func() (interface{}, error) {
-		return nil, f.FetchComments(ctx, storyID, kids)
-	})
-	return err
+// AddObserver registers o to be told about new items. It must be called
+// before the Fetcher is used.
+func (f *Fetcher) AddObserver(o ItemObserver) {
+	f.observers = append(f.observers, o)
 }
 
 // FetchStory fetches and upserts a single story from HN.
@@ -70,29 +61,93 @@ func (f *Fetcher) FetchStory(ctx context.Context, id int, rank *int) error {
 	}
 
 	now := time.Now().Unix()
-	st := storyFromItem(item, now, rank)
-	return f.q.UpsertStory(ctx, f.db, store.UpsertStoryParams{
+	_, err = f.upsertStory(ctx, storyFromItem(item, now, rank))
+	return err
+}
+
+// upsertStory stores a story with its canonical URL and domain, reporting whether it's
+// new. Shortened links are queued for resolution when the URL changes.
+func (f *Fetcher) upsertStory(ctx context.Context, st *store.Story) (bool, error) {
+	existing, err := store.Nullable(f.q.GetStoryByID(ctx, f.db, st.ID))
+	if err != nil {
+		return false, err
+	}
+
+	var canonical, domain *string
+	if st.URL != nil {
+		if c, err := urlnorm.Canonicalize(*st.URL); err == nil {
+			d := urlnorm.Domain(c)
+			canonical, domain = &c, &d
+		}
+	}
+	if err := f.q.UpsertStory(ctx, f.db, store.UpsertStoryParams{
 		ID: st.ID, Title: st.Title, URL: st.URL, Text: st.Text,
 		Score: st.Score, By: st.By, Time: st.Time,
 		Descendants: st.Descendants, Type: st.Type,
 		FetchedAt: st.FetchedAt, Rank: st.Rank, Dead: st.Dead,
-	})
+		CanonicalURL: canonical, Domain: domain,
+	}); err != nil {
+		return false, err
+	}
+
+	if existing == nil {
+		st.CanonicalURL, st.Domain = canonical, domain
+		for _, o := range f.observers {
+			o.NewStory(ctx, st)
+		}
+	}
+
+	urlChanged := existing == nil || !equalPtr(existing.URL, st.URL)
+	if canonical != nil && urlChanged && urlnorm.IsShortened(*canonical) {
+		if _, err := f.jobs.Enqueue(ctx, JobResolveURL, JobKey(JobResolveURL, st.ID), storyJob{StoryID: st.ID, URL: *st.URL}, jobs.PriorityLazy); err != nil {
+			slog.Error("error queueing url resolution", "story_id", st.ID, "error", err)
+		}
+	}
+	return existing == nil, nil
+}
+
+// ResolveURL follows a shortened story URL and stores the canonical form and
+// domain of its destination.
+func (f *Fetcher) ResolveURL(ctx context.Context, storyID int, rawURL string) error {
+	canonical, err := urlnorm.Resolve(ctx, f.resolver, rawURL)
+	if err != nil {
+		return err
+	}
+	domain := urlnorm.Domain(canonical)
+	return f.q.SetStoryResolvedURL(ctx, f.db, store.SetStoryResolvedURLParams{CanonicalURL: &canonical, Domain: &domain, ID: storyID})
 }
 
-// FetchComments fetches all comments for a story recursively.
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// FetchComments fetches all comments for a story recursively, recording a
+// revision for each known comment that was edited, deleted or killed since it
+// was last fetched.
 func (f *Fetcher) FetchComments(ctx context.Context, storyID int, kids []int) error {
 	if len(kids) == 0 {
 		return nil
 	}
-	return f.fetchCommentsRecursive(ctx, storyID, kids)
+	rows, err := f.q.GetCommentStatesByStory(ctx, f.db, storyID)
+	if err != nil {
+		return err
+	}
+	known := make(map[int]*store.CommentState, len(rows))
+	for _, row := range rows {
+		known[row.ID] = row
+	}
+	return f.fetchCommentsRecursive(ctx, storyID, kids, known)
 }
 
-func (f *Fetcher) fetchCommentsRecursive(ctx context.Context, storyID int, kids []int) error {
+func (f *Fetcher) fetchCommentsRecursive(ctx context.Context, storyID int, kids []int, known map[int]*store.CommentState) error {
 	items := f.client.GetItems(ctx, kids)
 	now := time.Now().Unix()
 
 	for _, item := range items {
-		if item == nil {
+		if item == nil || item.ID == 0 {
 			continue
 		}
 
@@ -100,36 +155,142 @@ func (f *Fetcher) fetchCommentsRecursive(ctx context.Context, storyID int, kids
 			return err
 		}
 
-		var parentID *int
-		if item.Parent != storyID {
-			parentID = &item.Parent
+		if _, err := f.storeComment(ctx, storyID, item, known[item.ID], now); err != nil {
+			slog.Error("error upserting comment", "comment_id", item.ID, "error", err)
+			continue
 		}
 
-		var by *string
-		if item.By != "" {
-			by = &item.By
+		if len(item.Kids) > 0 {
+			if err := f.fetchCommentsRecursive(ctx, storyID, item.Kids, known); err != nil {
+				slog.Error("error fetching children of comment", "comment_id", item.ID, "error", err)
+			}
 		}
-		var text *string
-		if item.Text != "" {
-			text = &item.Text
+	}
+	return nil
+}
+
+// RefreshComments re-fetches individual stored comments, recording revisions
+// and walking any replies not yet stored. It returns the IDs of stories with
+// revised comments.
+func (f *Fetcher) RefreshComments(ctx context.Context, ids []int) ([]int, error) {
+	states, err := store.CommentStatesByIDs(ctx, f.db, f.q, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	items := f.client.GetItems(ctx, ids)
+	now := time.Now().Unix()
+	var revisedStories []int
+	seen := make(map[int]bool)
+	for _, item := range items {
+		if item == nil || item.ID == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return revisedStories, err
+		}
+		prev := states[item.ID]
+		if prev == nil {
+			continue
 		}
 
-		if err := f.q.UpsertComment(ctx, f.db, store.UpsertCommentParams{
-			ID: item.ID, StoryID: storyID, ParentID: parentID,
-			By: by, Text: text, Time: item.Time,
-			Dead: item.Dead, Deleted: item.Deleted, FetchedAt: now,
-		}); err != nil {
+		revised, err := f.storeComment(ctx, prev.StoryID, item, prev, now)
+		if err != nil {
 			slog.Error("error upserting comment", "comment_id", item.ID, "error", err)
 			continue
 		}
+		if revised && !seen[prev.StoryID] {
+			seen[prev.StoryID] = true
+			revisedStories = append(revisedStories, prev.StoryID)
+		}
 
-		if len(item.Kids) > 0 {
-			if err := f.fetchCommentsRecursive(ctx, storyID, item.Kids); err != nil {
-				slog.Error("error fetching children of comment", "comment_id", item.ID, "error", err)
+		if len(item.Kids) == 0 {
+			continue
+		}
+		stored, err := store.CommentStatesByIDs(ctx, f.db, f.q, item.Kids)
+		if err != nil {
+			slog.Error("error loading replies of comment", "comment_id", item.ID, "error", err)
+			continue
+		}
+		var replies []int
+		for _, kid := range item.Kids {
+			if stored[kid] == nil {
+				replies = append(replies, kid)
+			}
+		}
+		if len(replies) > 0 {
+			if err := f.fetchCommentsRecursive(ctx, prev.StoryID, replies, stored); err != nil {
+				slog.Error("error fetching replies of comment", "comment_id", item.ID, "error", err)
 			}
 		}
 	}
-	return nil
+	return revisedStories, nil
+}
+
+// storeComment upserts a fetched comment and, if prev is its previously
+// stored state, records a revision when the two differ. It reports whether a
+// revision was recorded.
+func (f *Fetcher) storeComment(ctx context.Context, storyID int, item *hn.Item, prev *store.CommentState, now int64) (bool, error) {
+	var parentID *int
+	if item.Parent != storyID {
+		parentID = &item.Parent
+	}
+
+	var by *string
+	if item.By != "" {
+		by = &item.By
+	}
+	var text *string
+	if item.Text != "" {
+		text = sanitize.Text(&item.Text)
+	}
+
+	if err := f.q.UpsertComment(ctx, f.db, store.UpsertCommentParams{
+		ID: item.ID, StoryID: storyID, ParentID: parentID,
+		By: by, Text: text, Time: item.Time,
+		Dead: item.Dead, Deleted: item.Deleted, FetchedAt: now,
+	}); err != nil {
+		return false, err
+	}
+
+	if prev == nil {
+		for _, o := range f.observers {
+			o.NewComment(ctx, storyID, item)
+		}
+		return false, nil
+	}
+	change := commentChange(prev, item, text)
+	if change == "" {
+		return false, nil
+	}
+	if err := f.q.InsertCommentRevision(ctx, f.db, store.InsertCommentRevisionParams{
+		CommentID: item.ID, StoryID: storyID, Change: change,
+		OldText: prev.Text, NewText: text, DetectedAt: now,
+	}); err != nil {
+		return false, err
+	}
+	if change == store.RevisionEdited {
+		if err := f.q.MarkCommentEdited(ctx, f.db, store.MarkCommentEditedParams{EditedAt: &now, ID: item.ID}); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// commentChange classifies how a re-fetched comment differs from its stored
+// state, or returns "" if it doesn't.
+func commentChange(prev *store.CommentState, item *hn.Item, text *string) string {
+	switch {
+	case item.Deleted && !prev.Deleted:
+		return store.RevisionDeleted
+	case item.Dead && !prev.Dead:
+		return store.RevisionDead
+	case !item.Dead && prev.Dead && !item.Deleted:
+		return store.RevisionRevived
+	case !item.Deleted && !prev.Deleted && text != nil && prev.Text != nil && *text != *prev.Text:
+		return store.RevisionEdited
+	}
+	return ""
 }
 
 // FetchStoryWithComments fetches story details and its comments from HN.
@@ -143,20 +304,10 @@ func (f *Fetcher) FetchStoryWithComments(ctx context.Context, id int, rank *int)
 	}
 
 	now := time.Now().Unix()
-	st := storyFromItem(item, now, rank)
 
-	// Check if story is new (for article extraction decision)
-	isNew := false
-	if existing, _ := store.Nullable(f.q.GetStoryByID(ctx, f.db, item.ID)); existing == nil {
-		isNew = true
-	}
-
-	if err := f.q.UpsertStory(ctx, f.db, store.UpsertStoryParams{
-		ID: st.ID, Title: st.Title, URL: st.URL, Text: st.Text,
-		Score: st.Score, By: st.By, Time: st.Time,
-		Descendants: st.Descendants, Type: st.Type,
-		FetchedAt: st.FetchedAt, Rank: st.Rank, Dead: st.Dead,
-	}); err != nil {
+	// New stories get their article extracted
+	isNew, err := f.upsertStory(ctx, storyFromItem(item, now, rank))
+	if err != nil {
 		return err
 	}
 
@@ -166,39 +317,153 @@ func (f *Fetcher) FetchStoryWithComments(ctx context.Context, id int, rank *int)
 		}
 	}
 
-	// Extract article for new stories with URLs
+	// Queue article extraction for new stories with URLs
 	if isNew && item.URL != "" {
-		f.ExtractArticle(ctx, item.ID, item.URL)
+		if err := f.EnqueueArticle(ctx, item.ID, item.URL, jobs.PriorityEager); err != nil {
+			slog.Error("error queueing article extraction", "story_id", item.ID, "error", err)
+		}
 	}
 
 	return nil
 }
 
 // ExtractArticle fetches and extracts reader-mode content for a story URL.
-func (f *Fetcher) ExtractArticle(ctx context.Context, storyID int, url string) {
+// A failed extraction is recorded on the article row and returned.
+func (f *Fetcher) ExtractArticle(ctx context.Context, storyID int, url string) error {
 	now := time.Now().Unix()
-	article, err := readability.Extract(ctx, url)
+	article, err := f.extractor.Extract(ctx, url)
 	if err != nil {
 		slog.Error("article extraction failed", "story_id", storyID, "error", err)
 		f.q.UpsertArticle(ctx, f.db, store.UpsertArticleParams{
 			StoryID:          storyID,
 			ExtractionFailed: true,
 			FetchedAt:        now,
+			Source:           readability.SourceOrigin,
 		})
-		return
+		return err
+	}
+	if article.Source == readability.SourceArchive {
+		slog.Info("article extracted from archive", "story_id", storyID, "archive_url", article.ArchiveURL)
 	}
 
-	if err := f.q.UpsertArticle(ctx, f.db, store.UpsertArticleParams{
-		StoryID:          storyID,
-		Content:          &article.Content,
-		Title:            &article.Title,
-		Excerpt:          &article.Excerpt,
-		Byline:           &article.Byline,
-		ExtractionFailed: false,
-		FetchedAt:        now,
-	}); err != nil {
+	if err := f.q.UpsertArticle(ctx, f.db, articleParams(storyID, article, now)); err != nil {
 		slog.Error("error storing article", "story_id", storyID, "error", err)
+		return nil
+	}
+	if _, err := f.recordVersion(ctx, storyID, article.Content, article.Title, article.WordCount, now); err != nil {
+		slog.Error("error recording article version", "story_id", storyID, "error", err)
+	}
+	return nil
+}
+
+// ReextractArticle re-fetches an article that may already be stored. Unlike
+// ExtractArticle, a failed fetch leaves existing content in place, since a
+// transient error shouldn't erase a good copy. It reports whether the content
+// changed materially and a new version was recorded.
+func (f *Fetcher) ReextractArticle(ctx context.Context, storyID int, url string) (bool, error) {
+	previous, err := store.Nullable(f.q.GetArticleByStoryID(ctx, f.db, storyID))
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now().Unix()
+	article, err := f.extractor.Extract(ctx, url)
+	if err != nil {
+		return false, err
+	}
+
+	// Articles extracted before versions were tracked have no history yet;
+	// seed it with the stored copy so the first change has a baseline.
+	if previous != nil && previous.Content != nil && !previous.ExtractionFailed {
+		latest, err := store.Nullable(f.q.GetLatestArticleVersion(ctx, f.db, storyID))
+		if err != nil {
+			return false, err
+		}
+		if latest == nil {
+			title := ""
+			if previous.Title != nil {
+				title = *previous.Title
+			}
+			wordCount := 0
+			if previous.WordCount != nil {
+				wordCount = *previous.WordCount
+			}
+			if _, err := f.recordVersion(ctx, storyID, *previous.Content, title, wordCount, previous.FetchedAt); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := f.q.UpsertArticle(ctx, f.db, articleParams(storyID, article, now)); err != nil {
+		return false, err
+	}
+	return f.recordVersion(ctx, storyID, article.Content, article.Title, article.WordCount, now)
+}
+
+// materialChangeWords is the number of inserted or deleted words at which a
+// re-extracted article counts as edited rather than just re-rendered.
+const materialChangeWords = 5
+
+// recordVersion stores content as a new version if it differs materially from
+// the latest one. It reports whether an existing version was superseded.
+func (f *Fetcher) recordVersion(ctx context.Context, storyID int, content, title string, wordCount int, fetchedAt int64) (bool, error) {
+	latest, err := store.Nullable(f.q.GetLatestArticleVersion(ctx, f.db, storyID))
+	if err != nil {
+		return false, err
+	}
+	if latest != nil {
+		if latest.Content == content {
+			return false, nil
+		}
+		before, after := textdiff.Paragraphs(latest.Content), textdiff.Paragraphs(content)
+		if textdiff.ChangedWords(before, after, materialChangeWords) < materialChangeWords {
+			return false, nil
+		}
+	}
+
+	var titlePtr *string
+	if title != "" {
+		titlePtr = &title
+	}
+	if err := f.q.InsertArticleVersion(ctx, f.db, store.InsertArticleVersionParams{
+		StoryID: storyID, Content: content, Title: titlePtr,
+		WordCount: wordCount, FetchedAt: fetchedAt,
+	}); err != nil {
+		return false, err
+	}
+	return latest != nil, nil
+}
+
+func articleParams(storyID int, article *readability.Article, now int64) store.UpsertArticleParams {
+	p := store.UpsertArticleParams{
+		StoryID:            storyID,
+		Content:            &article.Content,
+		Title:              &article.Title,
+		Excerpt:            &article.Excerpt,
+		Byline:             &article.Byline,
+		ExtractionFailed:   false,
+		FetchedAt:          now,
+		WordCount:          &article.WordCount,
+		ReadingTimeMinutes: &article.ReadingTime,
+		Source:             article.Source,
+	}
+	if article.Language != "" {
+		p.Language = &article.Language
+	}
+	if article.LeadImageURL != "" {
+		p.LeadImageURL = &article.LeadImageURL
+	}
+	if article.SiteName != "" {
+		p.SiteName = &article.SiteName
+	}
+	if article.ArchiveURL != "" {
+		p.ArchiveURL = &article.ArchiveURL
+	}
+	if article.PublishedAt != nil {
+		published := article.PublishedAt.Unix()
+		p.PublishedAt = &published
 	}
+	return p
 }
 
 func storyFromItem(item *hn.Item, now int64, rank *int) *store.Story {
@@ -218,7 +483,7 @@ func storyFromItem(item *hn.Item, now int64, rank *int) *store.Story {
 		st.URL = &item.URL
 	}
 	if item.Text != "" {
-		st.Text = &item.Text
+		st.Text = sanitize.Text(&item.Text)
 	}
 	if st.Type == "" {
 		st.Type = "story"
@@ -0,0 +1,125 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/readability"
+	"github.com/danielmmetz/hn-client/server/safehttp"
+	"github.com/danielmmetz/hn-client/server/urlnorm"
+)
+
+// Job kinds run by the Fetcher.
+const (
+	JobFetchStory     = "fetch_story"
+	JobFetchComments  = "fetch_comments"
+	JobExtractArticle = "extract_article"
+	JobResolveURL     = "resolve_url"
+)
+
+// storyJob is the payload shared by story-scoped jobs.
+type storyJob struct {
+	StoryID int    `json:"story_id"`
+	URL     string `json:"url,omitempty"`
+	Kids    []int  `json:"kids,omitempty"`
+}
+
+// JobKey returns the dedup key for a story-scoped job.
+func JobKey(kind string, storyID int) string {
+	return fmt.Sprintf("%s:%d", kind, storyID)
+}
+
+// decodeStoryJob parses a storyJob payload. Malformed payloads can never
+// succeed, so the error is permanent.
+func decodeStoryJob(payload json.RawMessage) (storyJob, error) {
+	var p storyJob
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return p, jobs.Permanent(fmt.Errorf("decode payload: %w", err))
+	}
+	return p, nil
+}
+
+func (f *Fetcher) registerJobs() {
+	f.jobs.Handle(JobFetchStory, func(ctx context.Context, payload json.RawMessage) error {
+		p, err := decodeStoryJob(payload)
+		if err != nil {
+			return err
+		}
+		return f.FetchStory(ctx, p.StoryID, nil)
+	})
+	f.jobs.Handle(JobFetchComments, func(ctx context.Context, payload json.RawMessage) error {
+		p, err := decodeStoryJob(payload)
+		if err != nil {
+			return err
+		}
+		return f.FetchComments(ctx, p.StoryID, p.Kids)
+	})
+	f.jobs.Handle(JobExtractArticle, func(ctx context.Context, payload json.RawMessage) error {
+		p, err := decodeStoryJob(payload)
+		if err != nil {
+			return err
+		}
+		if err := f.ExtractArticle(ctx, p.StoryID, p.URL); err != nil {
+			if !retryableExtraction(err) {
+				return jobs.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+	f.jobs.Handle(JobResolveURL, func(ctx context.Context, payload json.RawMessage) error {
+		p, err := decodeStoryJob(payload)
+		if err != nil {
+			return err
+		}
+		if err := f.ResolveURL(ctx, p.StoryID, p.URL); err != nil {
+			if errors.Is(err, safehttp.ErrBlockedAddress) || errors.Is(err, safehttp.ErrBlockedScheme) ||
+				errors.Is(err, urlnorm.ErrUnsupported) {
+				return jobs.Permanent(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// FetchStoryAndWait fetches a story at user priority and waits for it.
+func (f *Fetcher) FetchStoryAndWait(ctx context.Context, id int) error {
+	return f.jobs.Run(ctx, JobFetchStory, JobKey(JobFetchStory, id), storyJob{StoryID: id}, jobs.PriorityUser)
+}
+
+// FetchCommentsAndWait fetches a story's comments at user priority and waits for them.
+func (f *Fetcher) FetchCommentsAndWait(ctx context.Context, storyID int, kids []int) error {
+	return f.jobs.Run(ctx, JobFetchComments, JobKey(JobFetchComments, storyID), storyJob{StoryID: storyID, Kids: kids}, jobs.PriorityUser)
+}
+
+// ExtractArticleAndWait extracts an article at user priority and waits for
+// the attempt to finish. Failures are recorded on the article row.
+func (f *Fetcher) ExtractArticleAndWait(ctx context.Context, storyID int, url string) error {
+	return f.jobs.Run(ctx, JobExtractArticle, JobKey(JobExtractArticle, storyID), storyJob{StoryID: storyID, URL: url}, jobs.PriorityUser)
+}
+
+// EnqueueArticle schedules article extraction without waiting for it.
+func (f *Fetcher) EnqueueArticle(ctx context.Context, storyID int, url string, priority jobs.Priority) error {
+	_, err := f.jobs.Enqueue(ctx, JobExtractArticle, JobKey(JobExtractArticle, storyID), storyJob{StoryID: storyID, URL: url}, priority)
+	return err
+}
+
+// retryableExtraction reports whether a failed extraction might succeed
+// later. Blocked destinations, unsupported content and client errors won't.
+func retryableExtraction(err error) bool {
+	if errors.Is(err, safehttp.ErrBlockedAddress) || errors.Is(err, safehttp.ErrBlockedScheme) ||
+		errors.Is(err, readability.ErrUnsupportedContentType) {
+		return false
+	}
+	var statusErr *readability.StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -4,53 +4,179 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/danielmmetz/hn-client/server/hn"
 	"github.com/danielmmetz/hn-client/server/sse"
 	"github.com/danielmmetz/hn-client/server/store"
 )
 
+const (
+	// eagerWorkers and lazyWorkers bound the stories fetched concurrently in
+	// each phase. Requests to HN are further limited by the hn.Client.
+	eagerWorkers = 8
+	lazyWorkers  = 16
+	// pollFetchBudget is the percentage of the poll interval available for
+	// fetching, leaving the rest for the rank swap and ranking recompute.
+	pollFetchBudget = 80
+)
+
+// PollerConfig controls how often the Poller runs and how much it fetches.
+type PollerConfig struct {
+	// Interval is the time between polls while clients are connected, or
+	// always when Adaptive is off.
+	Interval time.Duration
+	// EagerCount is how many top stories are fetched with comments each cycle.
+	EagerCount int
+
+	// Adaptive backs polling off to IdleInterval while no SSE clients are
+	// connected, or NightInterval if longer during night hours, and returns
+	// to Interval as soon as a client connects.
+	Adaptive      bool
+	IdleInterval  time.Duration
+	NightInterval time.Duration
+	// NightStart and NightEnd are local hours bounding the night window
+	// [NightStart, NightEnd), which may wrap midnight. Equal values disable it.
+	NightStart, NightEnd int
+}
+
+// DefaultPollerConfig returns the fixed one-minute schedule.
+func DefaultPollerConfig() PollerConfig {
+	return PollerConfig{
+		Interval:      1 * time.Minute,
+		EagerCount:    60,
+		IdleInterval:  5 * time.Minute,
+		NightInterval: 15 * time.Minute,
+		NightStart:    1,
+		NightEnd:      7,
+	}
+}
+
+// ParseHourRange parses a night window such as "1-7" or "22-6". An empty
+// string yields an empty (disabled) window.
+func ParseHourRange(s string) (start, end int, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if ok {
+		start, err = strconv.Atoi(strings.TrimSpace(from))
+	}
+	if ok && err == nil {
+		end, err = strconv.Atoi(strings.TrimSpace(to))
+	}
+	if !ok || err != nil || start < 0 || start > 23 || end < 0 || end > 23 {
+		return 0, 0, fmt.Errorf("invalid hour range %q: want START-END with hours 0-23", s)
+	}
+	return start, end, nil
+}
+
 type Poller struct {
 	client   *hn.Client
 	db       *sql.DB
 	q        *store.Queries
 	fetcher  *Fetcher
 	ranker   *Ranker
+	trending *Trending
 	broker   *sse.Broker
 	topList  *store.TopList
-	interval time.Duration
+	cfg      PollerConfig
 }
 
-func NewPoller(client *hn.Client, fetcher *Fetcher, db *sql.DB, q *store.Queries, broker *sse.Broker, topList *store.TopList) *Poller {
-	ranker := NewRanker(db, q)
+func NewPoller(client *hn.Client, fetcher *Fetcher, ranker *Ranker, trending *Trending, db *sql.DB, q *store.Queries, broker *sse.Broker, topList *store.TopList, cfg PollerConfig) *Poller {
 	return &Poller{
 		client:   client,
 		db:       db,
 		q:        q,
 		fetcher:  fetcher,
 		ranker:   ranker,
+		trending: trending,
 		broker:   broker,
 		topList:  topList,
-		interval: 1 * time.Minute,
+		cfg:      cfg,
 	}
 }
 
+// nextInterval returns the delay between the poll starting at now and the next.
+func (p *Poller) nextInterval(now time.Time) time.Duration {
+	if !p.cfg.Adaptive || p.broker.SubscriberCount() > 0 {
+		return p.cfg.Interval
+	}
+	interval := max(p.cfg.Interval, p.cfg.IdleInterval)
+	if p.isNight(now) {
+		interval = max(interval, p.cfg.NightInterval)
+	}
+	return interval
+}
+
+func (p *Poller) isNight(now time.Time) bool {
+	start, end, h := p.cfg.NightStart, p.cfg.NightEnd, now.Hour()
+	if start <= end {
+		return start <= h && h < end
+	}
+	return h >= start || h < end
+}
+
+// fetchAll runs fetch for each ID with at most workers in flight, recording
+// successes in fetched by position. IDs not started before ctx ends are skipped.
+func (p *Poller) fetchAll(ctx context.Context, ids []int, fetched []bool, workers int, fetch func(context.Context, int) error) {
+	var g errgroup.Group
+	g.SetLimit(workers)
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			if err := fetch(ctx, id); err != nil {
+				if ctx.Err() == nil {
+					slog.Error("error fetching story", "story_id", id, "error", err)
+				}
+				return nil
+			}
+			fetched[i] = true
+			return nil
+		})
+	}
+	g.Wait()
+}
+
 // Start begins the polling loop. It runs until the context is cancelled.
 func (p *Poller) Start(ctx context.Context) {
 	go func() {
-		p.poll(ctx)
-		ticker := time.NewTicker(p.interval)
-		defer ticker.Stop()
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		var lastPoll time.Time
+		interval := p.cfg.Interval
 		for {
 			select {
 			case <-ctx.Done():
 				slog.Info("poller: shutting down")
 				return
-			case <-ticker.C:
-				p.poll(ctx)
+			case <-p.broker.Active():
+				if !p.cfg.Adaptive {
+					continue
+				}
+				// A client connected while polling was backed off: resume
+				// the normal schedule from the last poll.
+				timer.Reset(time.Until(lastPoll.Add(p.cfg.Interval)))
+				continue
+			case <-timer.C:
 			}
+
+			lastPoll = time.Now()
+			p.poll(ctx)
+
+			if next := p.nextInterval(time.Now()); next != interval {
+				slog.Info("poller: interval changed", "interval", next, "subscribers", p.broker.SubscriberCount())
+				interval = next
+			}
+			timer.Reset(time.Until(lastPoll.Add(interval)))
 		}
 	}()
 }
@@ -69,57 +195,72 @@ func (p *Poller) poll(ctx context.Context) {
 	p.topList.Set(topIDs)
 	slog.Info("TopList updated", "count", len(topIDs))
 
-	// Phase 1: Fetch all story data WITHOUT setting ranks.
-	var rankPairs []store.RankPair
-	var updatedIDs []int
+	// Phase 1: Fetch all story data WITHOUT setting ranks. Fetching stops
+	// at the cycle deadline so a slow poll never runs into the next tick;
+	// stories not reached keep their data from the previous cycle.
+	fetchCtx, cancel := context.WithDeadline(ctx, start.Add(p.cfg.Interval*pollFetchBudget/100))
+	defer cancel()
 
-	// Eager fetch: top 60 (stories + comments)
-	eagerCount := 60
+	// Eager fetch: top stories with comments. Articles for new stories are
+	// queued for extraction rather than fetched inline.
+	eagerCount := p.cfg.EagerCount
 	if len(topIDs) < eagerCount {
 		eagerCount = len(topIDs)
 	}
 
-	for i := 0; i < eagerCount; i++ {
-		if ctx.Err() != nil {
-			slog.Info("poller: cancelled during eager fetch")
-			return
-		}
-		id := topIDs[i]
-		if err := p.fetcher.FetchStoryWithComments(ctx, id, nil); err != nil {
-			slog.Error("error fetching story", "story_id", id, "error", err)
-			continue
-		}
-		rankPairs = append(rankPairs, store.RankPair{ID: id, Rank: i + 1})
-		updatedIDs = append(updatedIDs, id)
+	fetched := make([]bool, len(topIDs))
+	p.fetchAll(fetchCtx, topIDs[:eagerCount], fetched[:eagerCount], eagerWorkers, func(ctx context.Context, id int) error {
+		return p.fetcher.FetchStoryWithComments(ctx, id, nil)
+	})
+	if ctx.Err() != nil {
+		slog.Info("poller: cancelled during eager fetch")
+		return
 	}
 
-	// Lazy fetch: stories 61-500 (metadata only)
-	for i := eagerCount; i < len(topIDs); i++ {
-		if ctx.Err() != nil {
-			slog.Info("poller: cancelled during lazy fetch")
-			break
-		}
-		id := topIDs[i]
-		if err := p.fetcher.FetchStory(ctx, id, nil); err != nil {
-			slog.Error("error fetching story metadata", "story_id", id, "error", err)
-			continue
-		}
+	// Lazy fetch: the rest (metadata only)
+	p.fetchAll(fetchCtx, topIDs[eagerCount:], fetched[eagerCount:], lazyWorkers, func(ctx context.Context, id int) error {
+		return p.fetcher.FetchStory(ctx, id, nil)
+	})
+	if ctx.Err() != nil {
+		slog.Info("poller: cancelled during lazy fetch")
+		return
+	}
+	if fetchCtx.Err() != nil {
+		slog.Warn("poller: cycle deadline reached before all stories were fetched")
+	}
+
+	// Every listed story is ranked, including ones not refreshed this cycle;
+	// SetRank is a no-op for stories that were never stored.
+	rankPairs := make([]store.RankPair, 0, len(topIDs))
+	var updatedIDs []int
+	for i, id := range topIDs {
 		rankPairs = append(rankPairs, store.RankPair{ID: id, Rank: i + 1})
-		updatedIDs = append(updatedIDs, id)
+		if fetched[i] {
+			updatedIDs = append(updatedIDs, id)
+		}
 	}
 
 	// Phase 2: Atomic rank swap
-	if len(rankPairs) >= 10 {
+	if len(updatedIDs) >= 10 {
 		if err := store.SwapRanks(ctx, p.db, p.q, rankPairs); err != nil {
 			slog.Error("error swapping ranks", "error", err)
 		}
 	} else {
-		slog.Warn("skipping rank swap: insufficient stories fetched", "fetched", len(rankPairs), "minimum", 10)
+		slog.Warn("skipping rank swap: insufficient stories fetched", "fetched", len(updatedIDs), "minimum", 10)
 	}
 
 	// Recompute rankings
 	p.ranker.ComputeAll(ctx)
 
+	// Measure growth of the stories refreshed this cycle
+	if len(updatedIDs) > 0 {
+		if stories, err := p.q.GetStoriesByIDs(ctx, p.db, updatedIDs); err != nil {
+			slog.Error("error loading stories for trending", "error", err)
+		} else {
+			p.trending.Observe(ctx, stories, time.Now())
+		}
+	}
+
 	elapsed := time.Since(start)
 	slog.Info("poll complete", "stories_updated", len(updatedIDs), "elapsed", elapsed)
 
@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/store"
+	"github.com/danielmmetz/hn-client/server/webpush"
+)
+
+// JobPush delivers a push message to one subscription.
+const JobPush = "push"
+
+const (
+	// PushTimeout bounds each delivery to a push service.
+	PushTimeout = 30 * time.Second
+	// pushTTL is how long push services hold a message for an offline browser.
+	pushTTL = 24 * time.Hour
+	// pushBodyLimit bounds notification bodies, in runes.
+	pushBodyLimit = 200
+)
+
+// PushMessage is the payload the service worker shows as a notification.
+// Messages with the same Tag replace each other.
+type PushMessage struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+	Tag   string `json:"tag"`
+}
+
+// storyMessage returns a message that opens a story's comments.
+func storyMessage(eventType, tag, title, body string, storyID int) PushMessage {
+	return PushMessage{Type: eventType, Title: title, Body: truncateRunes(body, pushBodyLimit), URL: fmt.Sprintf("/#/story/%d", storyID), Tag: tag}
+}
+
+type pushJob struct {
+	SubscriptionID int         `json:"subscription_id"`
+	Message        PushMessage `json:"message"`
+}
+
+// LoadVAPIDKeys returns the server's VAPID keys, generating and storing them
+// on first use.
+func LoadVAPIDKeys(ctx context.Context, db *sql.DB, q *store.Queries) (*webpush.VAPIDKeys, error) {
+	private, err := q.GetVAPIDKey(ctx, db)
+	if err == sql.ErrNoRows {
+		keys, genErr := webpush.GenerateVAPIDKeys()
+		if genErr != nil {
+			return nil, genErr
+		}
+		if err := q.InsertVAPIDKey(ctx, db, store.InsertVAPIDKeyParams{PrivateKey: keys.PrivateKey(), CreatedAt: time.Now().Unix()}); err != nil {
+			return nil, err
+		}
+		private, err = q.GetVAPIDKey(ctx, db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return webpush.ParseVAPIDKeys(private)
+}
+
+// Pusher queues push messages for users' subscriptions and delivers them
+// through the job queue, so failed deliveries are retried. Subscriptions the
+// push service reports gone are deleted.
+type Pusher struct {
+	sender *webpush.Sender
+	jobs   *jobs.Queue
+	db     *sql.DB
+	q      *store.Queries
+}
+
+// NewPusher creates a Pusher that delivers messages with sender and registers
+// its job handler with queue. Subscription endpoints come from browsers, so
+// sender's client should be a safehttp client outside of tests.
+func NewPusher(sender *webpush.Sender, queue *jobs.Queue, db *sql.DB, q *store.Queries) *Pusher {
+	p := &Pusher{sender: sender, jobs: queue, db: db, q: q}
+	queue.Handle(JobPush, p.handle)
+	return p
+}
+
+// Notify queues msg for each of the user's subscriptions, or for every
+// subscription if userSub is empty. Errors are logged.
+func (p *Pusher) Notify(ctx context.Context, userSub string, msg PushMessage) {
+	var ids []int
+	var err error
+	if userSub == "" {
+		ids, err = p.q.ListAllPushSubscriptionIDs(ctx, p.db)
+	} else {
+		ids, err = p.q.ListPushSubscriptionIDs(ctx, p.db, userSub)
+	}
+	if err != nil {
+		slog.Error("push: error listing subscriptions", "error", err)
+		return
+	}
+	for _, id := range ids {
+		key := fmt.Sprintf("%s:%d:%s", JobPush, id, msg.Tag)
+		if _, err := p.jobs.Enqueue(ctx, JobPush, key, pushJob{SubscriptionID: id, Message: msg}, jobs.PriorityEager); err != nil {
+			slog.Error("push: error queueing message", "subscription_id", id, "error", err)
+		}
+	}
+}
+
+func (p *Pusher) handle(ctx context.Context, payload json.RawMessage) error {
+	var job pushJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+	sub, err := store.Nullable(p.q.GetPushSubscription(ctx, p.db, job.SubscriptionID))
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		return nil // unsubscribed since the message was queued
+	}
+	data, err := json.Marshal(job.Message)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	err = p.sender.Send(ctx, &webpush.Subscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}, data, pushTTL)
+	if errors.Is(err, webpush.ErrGone) {
+		slog.Info("push: subscription gone", "subscription_id", sub.ID)
+		return p.q.DeletePushSubscription(ctx, p.db, sub.ID)
+	}
+	var status *webpush.StatusError
+	if errors.As(err, &status) && !status.Temporary() {
+		return jobs.Permanent(err)
+	}
+	return err
+}
@@ -3,81 +3,174 @@ package worker
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
-	"math"
 	"time"
 
 	"github.com/danielmmetz/hn-client/server/store"
 )
 
 type Ranker struct {
-	db *sql.DB
-	q  *store.Queries
+	db         *sql.DB
+	q          *store.Queries
+	strategies []RankingStrategy
+	byName     map[string]RankingStrategy
+
+	// computed records the bucket each closed period was last computed for.
+	// It's only accessed from ComputeAll, which the Poller calls serially.
+	computed map[string]time.Time
+}
+
+// NewRanker creates a Ranker that materializes rankings for each strategy.
+func NewRanker(db *sql.DB, q *store.Queries, strategies []RankingStrategy) *Ranker {
+	byName := make(map[string]RankingStrategy, len(strategies))
+	for _, s := range strategies {
+		byName[s.Name()] = s
+	}
+	return &Ranker{db: db, q: q, strategies: strategies, byName: byName, computed: make(map[string]time.Time)}
+}
+
+// Strategy returns the registered strategy with the given name.
+func (r *Ranker) Strategy(name string) (RankingStrategy, bool) {
+	s, ok := r.byName[name]
+	return s, ok
 }
 
-func NewRanker(db *sql.DB, q *store.Queries) *Ranker {
-	return &Ranker{db: db, q: q}
+// Algos returns the names of the registered strategies.
+func (r *Ranker) Algos() []string {
+	names := make([]string, len(r.strategies))
+	for i, s := range r.strategies {
+		names[i] = s.Name()
+	}
+	return names
 }
 
-// ComputeAll computes rankings for day, yesterday, and week periods.
+// ComputeAll brings the rankings for every materialized period and strategy
+// up to date. Windows start at the period's bucket boundary; open windows
+// still extend to now so new stories are ranked as soon as they're fetched.
 func (r *Ranker) ComputeAll(ctx context.Context) {
 	now := time.Now()
-	nowUnix := now.Unix()
-
-	dayAgo := now.Add(-24 * time.Hour).Unix()
-	r.computePeriod(ctx, "day", dayAgo, nowUnix, nowUnix, false)
+	for _, p := range materializedPeriods {
+		bucket := now.Truncate(p.bucket)
+		from, to, _ := PeriodRange(p.name, bucket)
+		if to == bucket.Unix() {
+			to = now.Unix() + 1
+		} else if r.computed[p.name].Equal(bucket) {
+			continue
+		}
+		if r.computePeriod(ctx, p.name, from, to, bucket.Unix(), now.Unix()) {
+			r.computed[p.name] = bucket
+		}
+	}
+}
 
-	twoDaysAgo := now.Add(-48 * time.Hour).Unix()
-	r.computePeriod(ctx, "yesterday", twoDaysAgo, dayAgo, nowUnix, true)
+// Rank scores stories posted in [from, to) on demand, for periods that
+// aren't materialized.
+func (r *Ranker) Rank(ctx context.Context, algo string, from, to int64) ([]*store.Story, error) {
+	strategy, ok := r.Strategy(algo)
+	if !ok {
+		return nil, fmt.Errorf("unknown ranking algorithm %q", algo)
+	}
+	stories, err := r.q.ListStoriesByTimeRange(ctx, r.db, store.ListStoriesByTimeRangeParams{
+		Time: from, Time_2: to,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rankStories(stories, strategy, time.Now().Unix())
+	return stories, nil
+}
 
-	weekAgo := now.Add(-7 * 24 * time.Hour).Unix()
-	r.computePeriod(ctx, "week", weekAgo, nowUnix, nowUnix, false)
+// Ranking returns every story ranked for a named period as of now, best
+// first, from the stored rankings when the period is materialized.
+func (r *Ranker) Ranking(ctx context.Context, period, algo string, now time.Time) ([]*store.Story, error) {
+	if !IsMaterializedPeriod(period) {
+		from, to, ok := PeriodRange(period, now)
+		if !ok {
+			return nil, fmt.Errorf("unknown period %q", period)
+		}
+		return r.Rank(ctx, algo, from, to)
+	}
+	total, err := r.q.CountRankingsByPeriod(ctx, r.db, store.CountRankingsByPeriodParams{Period: period, Algo: algo})
+	if err != nil {
+		return nil, err
+	}
+	return r.q.GetStoriesByPeriod(ctx, r.db, store.GetStoriesByPeriodParams{Period: period, Algo: algo, MaxStories: total})
 }
 
-func (r *Ranker) computePeriod(ctx context.Context, period string, fromTime, toTime, now int64, useRawScore bool) {
+// computePeriod reconciles the stored rankings for period with stories posted
+// in [fromTime, toTime) scored as of scoredAt. It reports whether it succeeded.
+func (r *Ranker) computePeriod(ctx context.Context, period string, fromTime, toTime, scoredAt, now int64) bool {
 	stories, err := r.q.ListStoriesByTimeRange(ctx, r.db, store.ListStoriesByTimeRangeParams{
 		Time: fromTime, Time_2: toTime,
 	})
 	if err != nil {
 		slog.Error("ranker: error listing stories", "period", period, "error", err)
-		return
+		return false
 	}
-
-	// Upsert rankings in a transaction: delete old, insert new
-	tx, err := r.db.BeginTx(ctx, nil)
+	existing, err := r.q.ListRankingScores(ctx, r.db, period)
 	if err != nil {
-		slog.Error("ranker: error starting transaction", "period", period, "error", err)
-		return
+		slog.Error("ranker: error listing rankings", "period", period, "error", err)
+		return false
 	}
-	defer tx.Rollback()
 
-	if err := r.q.DeleteRankingsByPeriod(ctx, tx, period); err != nil {
-		slog.Error("ranker: error deleting old rankings", "period", period, "error", err)
-		return
+	type rankingKey struct {
+		storyID int
+		algo    string
+	}
+	stored := make(map[rankingKey]float64, len(existing))
+	for _, row := range existing {
+		stored[rankingKey{row.StoryID, row.Algo}] = row.Score
 	}
 
+	// Only rows whose score changed are written. Scores are deterministic for
+	// a given story and scoredAt, so unchanged stories compare exactly equal.
+	var changed []store.RankingScore
+	inWindow := make(map[int]bool, len(stories))
 	for _, s := range stories {
-		var score float64
-		if useRawScore {
-			score = float64(s.Score)
-		} else {
-			ageHours := float64(now-s.Time) / 3600.0
-			score = float64(s.Score-1) / math.Pow(ageHours+2, 1.5)
+		inWindow[s.ID] = true
+		for _, strategy := range r.strategies {
+			key := rankingKey{s.ID, strategy.Name()}
+			score := strategy.Score(s, scoredAt)
+			if old, ok := stored[key]; ok && old == score {
+				continue
+			}
+			changed = append(changed, store.RankingScore{StoryID: s.ID, Algo: key.algo, Score: score})
 		}
-
-		if err := r.q.InsertRanking(ctx, tx, store.InsertRankingParams{
-			StoryID: s.ID, Period: period,
-			Score: score, ComputedAt: now,
-		}); err != nil {
-			slog.Error("ranker: error inserting ranking", "period", period, "story_id", s.ID, "error", err)
-			return
+	}
+	var removed []int
+	seen := make(map[int]bool)
+	for _, row := range existing {
+		if !inWindow[row.StoryID] && !seen[row.StoryID] {
+			seen[row.StoryID] = true
+			removed = append(removed, row.StoryID)
 		}
 	}
+	if len(changed) == 0 && len(removed) == 0 {
+		return true
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.Error("ranker: error starting transaction", "period", period, "error", err)
+		return false
+	}
+	defer tx.Rollback()
+
+	if err := store.DeleteRankings(ctx, tx, r.q, period, removed); err != nil {
+		slog.Error("ranker: error deleting rankings", "period", period, "error", err)
+		return false
+	}
+	if err := store.UpsertRankings(ctx, tx, period, now, changed); err != nil {
+		slog.Error("ranker: error writing rankings", "period", period, "error", err)
+		return false
+	}
 
 	if err := tx.Commit(); err != nil {
 		slog.Error("ranker: error committing rankings", "period", period, "error", err)
-		return
+		return false
 	}
 
-	slog.Info("ranker: computed rankings", "period", period, "count", len(stories))
+	slog.Info("ranker: updated rankings", "period", period, "stories", len(stories), "changed", len(changed), "removed", len(removed))
+	return true
 }
@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// RankingStrategy scores stories for a ranking. Higher scores rank first.
+type RankingStrategy interface {
+	// Name identifies the strategy in the rankings table and the API.
+	Name() string
+	// Score returns the story's score as of now (unix seconds).
+	Score(s *store.Story, now int64) float64
+}
+
+// GravityStrategy is the HN front-page formula: points decay with age.
+type GravityStrategy struct{}
+
+func (GravityStrategy) Name() string { return "gravity" }
+
+func (GravityStrategy) Score(s *store.Story, now int64) float64 {
+	return float64(s.Score-1) / math.Pow(ageHours(s, now)+2, 1.5)
+}
+
+// ScoreStrategy ranks by raw points.
+type ScoreStrategy struct{}
+
+func (ScoreStrategy) Name() string { return "score" }
+
+func (ScoreStrategy) Score(s *store.Story, _ int64) float64 {
+	return float64(s.Score)
+}
+
+// CommentsPerHourStrategy ranks by discussion velocity since posting.
+type CommentsPerHourStrategy struct{}
+
+func (CommentsPerHourStrategy) Name() string { return "comments_per_hour" }
+
+func (CommentsPerHourStrategy) Score(s *store.Story, now int64) float64 {
+	return float64(s.Descendants) / max(ageHours(s, now), 1)
+}
+
+// ControversyStrategy ranks by comments per point: stories that drew far more
+// argument than upvotes.
+type ControversyStrategy struct{}
+
+func (ControversyStrategy) Name() string { return "controversy" }
+
+func (ControversyStrategy) Score(s *store.Story, _ int64) float64 {
+	return float64(s.Descendants) / float64(max(s.Score, 1))
+}
+
+func ageHours(s *store.Story, now int64) float64 {
+	return max(float64(now-s.Time)/3600.0, 0)
+}
+
+// DefaultStrategies returns the built-in ranking strategies.
+func DefaultStrategies() []RankingStrategy {
+	return []RankingStrategy{
+		GravityStrategy{},
+		ScoreStrategy{},
+		CommentsPerHourStrategy{},
+		ControversyStrategy{},
+	}
+}
+
+// Ranking periods. Materialized periods are kept in the rankings table by the
+// Ranker; the rest are scored on demand from stored stories.
+const (
+	PeriodDay       = "day"
+	PeriodYesterday = "yesterday"
+	PeriodWeek      = "week"
+	PeriodMonth     = "month"
+	PeriodYear      = "year"
+	PeriodCustom    = "custom"
+)
+
+// MaxRankingAge is how far back rankings reach, covering the year period and
+// custom ranges. The Cleaner keeps every story posted since then.
+const MaxRankingAge = 366 * 24 * time.Hour
+
+// materializedPeriods are precomputed by the Ranker. Each is scored as of the
+// start of its current bucket, so within a bucket only stories whose points
+// or comments changed get new scores, and a window that has already closed
+// ("yesterday") is only recomputed once per bucket.
+var materializedPeriods = []struct {
+	name   string
+	bucket time.Duration
+}{
+	{PeriodDay, 10 * time.Minute},
+	{PeriodYesterday, time.Hour},
+	{PeriodWeek, time.Hour},
+}
+
+// IsMaterializedPeriod reports whether rankings for period are precomputed.
+func IsMaterializedPeriod(period string) bool {
+	for _, p := range materializedPeriods {
+		if p.name == period {
+			return true
+		}
+	}
+	return false
+}
+
+// PeriodRange returns the [from, to) story time range for a named period
+// ending at now, or false if the name isn't a known fixed period.
+func PeriodRange(period string, now time.Time) (from, to int64, ok bool) {
+	const day = 24 * time.Hour
+	switch period {
+	case PeriodDay:
+		return now.Add(-day).Unix(), now.Unix(), true
+	case PeriodYesterday:
+		return now.Add(-2 * day).Unix(), now.Add(-day).Unix(), true
+	case PeriodWeek:
+		return now.Add(-7 * day).Unix(), now.Unix(), true
+	case PeriodMonth:
+		return now.Add(-30 * day).Unix(), now.Unix(), true
+	case PeriodYear:
+		return now.Add(-365 * day).Unix(), now.Unix(), true
+	}
+	return 0, 0, false
+}
+
+// DefaultAlgo is the strategy used for a period when none is requested.
+// "yesterday" is a closed window, so it ranks by final points.
+func DefaultAlgo(period string) string {
+	if period == PeriodYesterday {
+		return ScoreStrategy{}.Name()
+	}
+	return GravityStrategy{}.Name()
+}
+
+// rankStories sorts stories by strategy score, highest first, breaking ties
+// by ID for stable pagination.
+func rankStories(stories []*store.Story, strategy RankingStrategy, now int64) {
+	scores := make(map[int]float64, len(stories))
+	for _, s := range stories {
+		scores[s.ID] = strategy.Score(s, now)
+	}
+	sort.Slice(stories, func(i, j int) bool {
+		a, b := stories[i], stories[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		return a.ID > b.ID
+	})
+}
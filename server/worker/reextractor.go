@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/sse"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+const (
+	// reextractInterval is how often front-page articles are re-fetched to
+	// catch edits made after a story was posted.
+	reextractInterval = 30 * time.Minute
+	// reextractMaxRank limits re-extraction to stories on the first page.
+	reextractMaxRank = 30
+)
+
+// JobReextractArticle re-fetches an already extracted article to look for edits.
+const JobReextractArticle = "reextract_article"
+
+type Reextractor struct {
+	fetcher *Fetcher
+	jobs    *jobs.Queue
+	db      *sql.DB
+	q       *store.Queries
+	broker  *sse.Broker
+}
+
+// NewReextractor creates a Reextractor and registers its job handler with queue.
+func NewReextractor(fetcher *Fetcher, queue *jobs.Queue, db *sql.DB, q *store.Queries, broker *sse.Broker) *Reextractor {
+	r := &Reextractor{fetcher: fetcher, jobs: queue, db: db, q: q, broker: broker}
+	queue.Handle(JobReextractArticle, r.handle)
+	return r
+}
+
+// Start begins the re-extraction cycle. It runs until the context is cancelled.
+func (r *Reextractor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reextractInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("reextractor: shutting down")
+				return
+			case <-ticker.C:
+				r.schedule(ctx)
+			}
+		}
+	}()
+}
+
+// schedule queues re-extraction for front-page articles that haven't been
+// fetched for a full interval.
+func (r *Reextractor) schedule(ctx context.Context) {
+	candidates, err := r.q.ListReextractCandidates(ctx, r.db, store.ListReextractCandidatesParams{
+		MaxRank:       reextractMaxRank,
+		FetchedBefore: time.Now().Add(-reextractInterval).Unix(),
+	})
+	if err != nil {
+		slog.Error("reextractor: error listing candidates", "error", err)
+		return
+	}
+
+	queued := 0
+	for _, c := range candidates {
+		if c.URL == nil {
+			continue
+		}
+		p := storyJob{StoryID: c.ID, URL: *c.URL}
+		if _, err := r.jobs.Enqueue(ctx, JobReextractArticle, JobKey(JobReextractArticle, c.ID), p, jobs.PriorityLazy); err != nil {
+			slog.Error("reextractor: error queueing job", "story_id", c.ID, "error", err)
+			continue
+		}
+		queued++
+	}
+	slog.Info("reextractor: queued re-extraction", "count", queued)
+}
+
+func (r *Reextractor) handle(ctx context.Context, payload json.RawMessage) error {
+	p, err := decodeStoryJob(payload)
+	if err != nil {
+		return err
+	}
+	changed, err := r.fetcher.ReextractArticle(ctx, p.StoryID, p.URL)
+	if err != nil {
+		// The stored copy is still good; try again next cycle rather than retrying.
+		return jobs.Permanent(err)
+	}
+	if changed {
+		slog.Info("reextractor: article changed", "story_id", p.StoryID)
+		data, _ := json.Marshal(map[string]interface{}{
+			"story_id":  p.StoryID,
+			"timestamp": time.Now().Unix(),
+		})
+		r.broker.Publish("article_updated", string(data))
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/jobs"
+	"github.com/danielmmetz/hn-client/server/sanitize"
+	"github.com/danielmmetz/hn-client/server/sse"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// JobCheckReplies scans linked HN accounts for new replies.
+const JobCheckReplies = "check_replies"
+
+const (
+	replyCheckInterval = 5 * time.Minute
+	// replyTrackedItems is how many of an account's most recent submissions
+	// are watched for replies.
+	replyTrackedItems = 30
+)
+
+// ReplyTracker fills users' inboxes with replies to the recent comments of
+// their linked HN accounts. The first scan of an account records existing
+// replies as read, so only replies that arrive afterwards are announced, over
+// SSE and by push.
+type ReplyTracker struct {
+	client *hn.Client
+	jobs   *jobs.Queue
+	db     *sql.DB
+	q      *store.Queries
+	broker *sse.Broker
+	pusher *Pusher
+}
+
+// NewReplyTracker creates a ReplyTracker and registers its job handler with queue.
+func NewReplyTracker(client *hn.Client, queue *jobs.Queue, db *sql.DB, q *store.Queries, broker *sse.Broker, pusher *Pusher) *ReplyTracker {
+	t := &ReplyTracker{client: client, jobs: queue, db: db, q: q, broker: broker, pusher: pusher}
+	queue.Handle(JobCheckReplies, t.handle)
+	return t
+}
+
+// Start begins the check cycle. It runs until the context is cancelled.
+func (t *ReplyTracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(replyCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("reply tracker: shutting down")
+				return
+			case <-ticker.C:
+				if err := t.enqueue(ctx, jobs.PriorityLazy); err != nil {
+					slog.Error("reply tracker: error queueing job", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Trigger queues a check ahead of the next cycle, e.g. after an account is linked.
+func (t *ReplyTracker) Trigger(ctx context.Context) error {
+	return t.enqueue(ctx, jobs.PriorityUser)
+}
+
+func (t *ReplyTracker) enqueue(ctx context.Context, priority jobs.Priority) error {
+	// A single key means a slow cycle is never queued twice.
+	_, err := t.jobs.Enqueue(ctx, JobCheckReplies, JobCheckReplies, struct{}{}, priority)
+	return err
+}
+
+func (t *ReplyTracker) handle(ctx context.Context, _ json.RawMessage) error {
+	accounts, err := t.q.ListHNAccounts(ctx, t.db)
+	if err != nil {
+		return err
+	}
+	for _, acct := range accounts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := t.check(ctx, acct)
+		if err != nil {
+			slog.Error("reply tracker: error checking account", "user_sub", acct.UserSub, "username", acct.Username, "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("reply tracker: new replies", "username", acct.Username, "count", n)
+		}
+	}
+	return nil
+}
+
+// check stores replies to the account's recent comments that aren't in the
+// inbox yet, returning how many were announced.
+func (t *ReplyTracker) check(ctx context.Context, acct *store.HnAccount) (int, error) {
+	user, err := t.client.GetUser(ctx, acct.Username)
+	if err != nil {
+		return 0, err
+	}
+	if user == nil {
+		slog.Warn("reply tracker: HN user not found", "username", acct.Username)
+		return 0, nil
+	}
+
+	recent := user.Submitted[:min(len(user.Submitted), replyTrackedItems)]
+	parentOf := make(map[int]int)
+	var kids []int
+	for _, item := range t.client.GetItems(ctx, recent) {
+		if item == nil || item.Type != "comment" || item.Deleted || item.Dead {
+			continue
+		}
+		for _, kid := range item.Kids {
+			parentOf[kid] = item.ID
+			kids = append(kids, kid)
+		}
+	}
+
+	var unseen []int
+	if len(kids) > 0 {
+		known, err := t.q.ListInboxReplyIDs(ctx, t.db, store.ListInboxReplyIDsParams{UserSub: acct.UserSub, ReplyIds: kids})
+		if err != nil {
+			return 0, err
+		}
+		seen := make(map[int]bool, len(known))
+		for _, id := range known {
+			seen[id] = true
+		}
+		for _, id := range kids {
+			if !seen[id] {
+				unseen = append(unseen, id)
+			}
+		}
+	}
+
+	now := time.Now().Unix()
+	baseline := acct.CheckedAt == nil
+	var readAt *int64
+	if baseline {
+		readAt = &now
+	}
+	announced := 0
+	for _, item := range t.client.GetItems(ctx, unseen) {
+		// Deleted and dead replies, and the account's own, are skipped and
+		// looked at again next cycle.
+		if item == nil || item.ID == 0 || item.Deleted || item.Dead || item.By == "" || item.By == acct.Username {
+			continue
+		}
+		var text *string
+		if item.Text != "" {
+			text = sanitize.Text(&item.Text)
+		}
+		id, err := t.q.CreateInboxReply(ctx, t.db, store.CreateInboxReplyParams{
+			UserSub: acct.UserSub, ReplyID: item.ID, ParentID: parentOf[item.ID],
+			By: item.By, Text: text, Time: item.Time,
+			CreatedAt: now, ReadAt: readAt,
+		})
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return announced, err
+		}
+		if baseline {
+			continue
+		}
+
+		reply, err := t.q.GetInboxReply(ctx, t.db, id)
+		if err != nil {
+			return announced, err
+		}
+		data, _ := json.Marshal(reply)
+		t.broker.PublishTo(acct.UserSub, "reply", string(data))
+		t.pusher.Notify(ctx, acct.UserSub, replyMessage(reply))
+		announced++
+	}
+
+	return announced, t.q.SetHNAccountChecked(ctx, t.db, store.SetHNAccountCheckedParams{
+		CheckedAt: &now, UserSub: acct.UserSub, Username: acct.Username,
+	})
+}
+
+// replyMessage opens the story a reply is on if it's stored, and the reply on
+// HN otherwise.
+func replyMessage(r *store.GetInboxReplyRow) PushMessage {
+	title := r.By + " replied"
+	var body string
+	if r.Text != nil {
+		body = sanitize.PlainText(*r.Text)
+	}
+	tag := fmt.Sprintf("reply-%d", r.ID)
+	if r.StoryID != nil {
+		return storyMessage("reply", tag, title, body, *r.StoryID)
+	}
+	msg := storyMessage("reply", tag, title, body, 0)
+	msg.URL = fmt.Sprintf("https://news.ycombinator.com/item?id=%d", r.ReplyID)
+	return msg
+}
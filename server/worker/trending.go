@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/sse"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+const (
+	// DefaultTrendingThreshold is the points per hour at which a story is
+	// considered to be trending.
+	DefaultTrendingThreshold = 60.0
+
+	// trendSpan is the minimum time between the samples velocity is measured
+	// over, smoothing out single-poll jumps.
+	trendSpan = 10 * time.Minute
+	// trendHistory is how long samples are kept. Stories not observed for
+	// this long (off the top list) are forgotten.
+	trendHistory = time.Hour
+	// trendCoolDown is the fraction of the threshold a trending story's
+	// velocity must fall below before it stops trending, so stories near the
+	// threshold don't flap in and out.
+	trendCoolDown = 0.5
+)
+
+// TrendingStory is a story's current growth, measured between polls.
+type TrendingStory struct {
+	StoryID int `json:"story_id"`
+	// Velocity is points gained per hour; CommentVelocity is comments per hour.
+	Velocity        float64 `json:"velocity"`
+	CommentVelocity float64 `json:"comment_velocity"`
+	// Acceleration is the change in Velocity per hour.
+	Acceleration float64 `json:"acceleration"`
+	// Since is when the story crossed the threshold (unix seconds).
+	Since int64 `json:"since"`
+}
+
+type trendSample struct {
+	at          int64
+	score       int
+	descendants int
+	velocity    float64
+	hasVelocity bool
+}
+
+type trendState struct {
+	samples  []trendSample
+	trending *TrendingStory
+}
+
+// Trending tracks how quickly stories gain points and comments from
+// poll-to-poll observations, and publishes story_trending when a story's
+// velocity crosses the threshold, also pushing it to every subscribed
+// browser. State is kept in memory only.
+type Trending struct {
+	broker    *sse.Broker
+	pusher    *Pusher
+	threshold float64
+
+	mu      sync.Mutex
+	stories map[int]*trendState
+}
+
+// NewTrending creates a detector that flags stories gaining at least
+// threshold points per hour.
+func NewTrending(broker *sse.Broker, pusher *Pusher, threshold float64) *Trending {
+	return &Trending{broker: broker, pusher: pusher, threshold: threshold, stories: make(map[int]*trendState)}
+}
+
+// Observe records freshly fetched stories as of now, and publishes
+// story_trending for each that newly crossed the threshold.
+func (t *Trending) Observe(ctx context.Context, stories []*store.Story, now time.Time) {
+	ts := now.Unix()
+
+	t.mu.Lock()
+	var crossed []TrendingStory
+	titles := make(map[int]string)
+	for _, s := range stories {
+		st, ok := t.stories[s.ID]
+		if !ok {
+			st = &trendState{}
+			t.stories[s.ID] = st
+		}
+		if t.observe(st, s, ts) {
+			crossed = append(crossed, *st.trending)
+			titles[s.ID] = s.Title
+		}
+	}
+	for id, st := range t.stories {
+		if last := st.samples[len(st.samples)-1]; ts-last.at > int64(trendHistory/time.Second) {
+			delete(t.stories, id)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, c := range crossed {
+		slog.Info("trending: story crossed threshold", "story_id", c.StoryID, "velocity", c.Velocity)
+		data, _ := json.Marshal(map[string]interface{}{
+			"story_id":         c.StoryID,
+			"velocity":         c.Velocity,
+			"comment_velocity": c.CommentVelocity,
+			"acceleration":     c.Acceleration,
+			"timestamp":        ts,
+		})
+		t.broker.Publish("story_trending", string(data))
+		t.pusher.Notify(ctx, "", storyMessage("story_trending", fmt.Sprintf("trending-%d", c.StoryID), "Trending on HN", titles[c.StoryID], c.StoryID))
+	}
+}
+
+// observe appends a sample to st and updates its trending state, reporting
+// whether the story newly started trending. t.mu must be held.
+func (t *Trending) observe(st *trendState, s *store.Story, now int64) bool {
+	cutoff := now - int64(trendHistory/time.Second)
+	for len(st.samples) > 0 && st.samples[0].at < cutoff {
+		st.samples = st.samples[1:]
+	}
+
+	sample := trendSample{at: now, score: s.Score, descendants: s.Descendants}
+	ref, ok := referenceSample(st.samples, now)
+	var commentVelocity, acceleration float64
+	if ok {
+		hours := float64(now-ref.at) / 3600
+		sample.velocity = float64(s.Score-ref.score) / hours
+		sample.hasVelocity = true
+		commentVelocity = float64(s.Descendants-ref.descendants) / hours
+		if ref.hasVelocity {
+			acceleration = (sample.velocity - ref.velocity) / hours
+		}
+	}
+	st.samples = append(st.samples, sample)
+	if !ok {
+		return false
+	}
+
+	if st.trending != nil {
+		if sample.velocity < t.threshold*trendCoolDown {
+			st.trending = nil
+			return false
+		}
+		st.trending.Velocity = sample.velocity
+		st.trending.CommentVelocity = commentVelocity
+		st.trending.Acceleration = acceleration
+		return false
+	}
+	if sample.velocity < t.threshold {
+		return false
+	}
+	st.trending = &TrendingStory{
+		StoryID:         s.ID,
+		Velocity:        sample.velocity,
+		CommentVelocity: commentVelocity,
+		Acceleration:    acceleration,
+		Since:           now,
+	}
+	return true
+}
+
+// referenceSample returns the newest sample at least trendSpan before now.
+func referenceSample(samples []trendSample, now int64) (trendSample, bool) {
+	for i := len(samples) - 1; i >= 0; i-- {
+		if now-samples[i].at >= int64(trendSpan/time.Second) {
+			return samples[i], true
+		}
+	}
+	return trendSample{}, false
+}
+
+// Stories returns the currently trending stories, fastest first.
+func (t *Trending) Stories() []TrendingStory {
+	t.mu.Lock()
+	var out []TrendingStory
+	for _, st := range t.stories {
+		if st.trending != nil {
+			out = append(out, *st.trending)
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Velocity != out[j].Velocity {
+			return out[i].Velocity > out[j].Velocity
+		}
+		return out[i].StoryID > out[j].StoryID
+	})
+	return out
+}
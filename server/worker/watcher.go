@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielmmetz/hn-client/server/hn"
+	"github.com/danielmmetz/hn-client/server/sse"
+	"github.com/danielmmetz/hn-client/server/store"
+)
+
+// watchMaxAge bounds how old an item may be and still raise alerts, so that
+// backfilling an old thread doesn't flood users with stale matches.
+const watchMaxAge = 24 * time.Hour
+
+// Watcher matches newly stored stories and comments against users' watches,
+// recording an alert per match and publishing it to the watch's owner.
+//
+// Stories match keyword watches on whole words of their title, domain watches
+// on their registrable domain, and user watches on their submitter. Comments
+// match user watches on their author. Alerts are also pushed to the owner's
+// subscribed browsers.
+type Watcher struct {
+	db     *sql.DB
+	q      *store.Queries
+	broker *sse.Broker
+	pusher *Pusher
+
+	mu      sync.Mutex
+	watches []*store.Watch
+	loaded  bool
+}
+
+// NewWatcher creates a Watcher. Register it with Fetcher.AddObserver.
+func NewWatcher(db *sql.DB, q *store.Queries, broker *sse.Broker, pusher *Pusher) *Watcher {
+	return &Watcher{db: db, q: q, broker: broker, pusher: pusher}
+}
+
+// Invalidate drops the cached watches; call it after watches change.
+func (w *Watcher) Invalidate() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watches, w.loaded = nil, false
+}
+
+func (w *Watcher) load(ctx context.Context) ([]*store.Watch, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.loaded {
+		watches, err := w.q.ListAllWatches(ctx, w.db)
+		if err != nil {
+			return nil, err
+		}
+		w.watches, w.loaded = watches, true
+	}
+	return w.watches, nil
+}
+
+// NewStory implements ItemObserver.
+func (w *Watcher) NewStory(ctx context.Context, s *store.Story) {
+	if s.Dead || stale(s.Time) {
+		return
+	}
+	watches, err := w.load(ctx)
+	if err != nil {
+		slog.Error("error loading watches", "error", err)
+		return
+	}
+
+	title := strings.ToLower(s.Title)
+	by := strings.ToLower(s.By)
+	for _, watch := range watches {
+		var match bool
+		switch watch.Kind {
+		case store.FilterKeyword:
+			match = store.ContainsWords(title, watch.Value)
+		case store.FilterDomain:
+			match = s.Domain != nil && *s.Domain == watch.Value
+		case store.FilterUser:
+			match = by == watch.Value
+		}
+		if match {
+			w.alert(ctx, watch, s.ID, nil)
+		}
+	}
+}
+
+// NewComment implements ItemObserver.
+func (w *Watcher) NewComment(ctx context.Context, storyID int, item *hn.Item) {
+	if item.Dead || item.Deleted || item.By == "" || stale(item.Time) {
+		return
+	}
+	watches, err := w.load(ctx)
+	if err != nil {
+		slog.Error("error loading watches", "error", err)
+		return
+	}
+
+	by := strings.ToLower(item.By)
+	for _, watch := range watches {
+		if watch.Kind == store.FilterUser && watch.Value == by {
+			w.alert(ctx, watch, storyID, &item.ID)
+		}
+	}
+}
+
+// alert records a match and publishes it, unless the watch already alerted
+// on the item.
+func (w *Watcher) alert(ctx context.Context, watch *store.Watch, storyID int, commentID *int) {
+	itemID := storyID
+	if commentID != nil {
+		itemID = *commentID
+	}
+	id, err := w.q.CreateAlert(ctx, w.db, store.CreateAlertParams{
+		UserSub: watch.UserSub, WatchID: watch.ID, StoryID: storyID,
+		CommentID: commentID, ItemID: itemID, CreatedAt: time.Now().Unix(),
+	})
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		slog.Error("error recording alert", "watch_id", watch.ID, "item_id", itemID, "error", err)
+		return
+	}
+
+	a, err := w.q.GetAlert(ctx, w.db, id)
+	if err != nil {
+		slog.Error("error loading alert", "alert_id", id, "error", err)
+		return
+	}
+	data, _ := json.Marshal(a)
+	w.broker.PublishTo(watch.UserSub, "alert", string(data))
+
+	body := a.StoryTitle
+	if a.CommentBy != nil {
+		body = fmt.Sprintf("%s commented on %s", *a.CommentBy, a.StoryTitle)
+	}
+	w.pusher.Notify(ctx, watch.UserSub, storyMessage("alert", fmt.Sprintf("alert-%d", a.ID), "Watch: "+watch.Value, body, a.StoryID))
+}
+
+func stale(itemTime int64) bool {
+	return time.Since(time.Unix(itemTime, 0)) > watchMaxAge
+}